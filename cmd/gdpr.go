@@ -0,0 +1,129 @@
+// Package cmd is the command surface of mongodbtest cli tool provided by kubuskotak.
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+	"github.com/kubuskotak/ymir-test/pkg/usecase"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/groups"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/idempotency"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+type gdprOptions struct {
+	Output string
+	Tenant string
+}
+
+func newGdprCmd() *cobra.Command {
+	g := &gdprOptions{}
+	cmd := &cobra.Command{
+		Use:   `gdpr`,
+		Short: "Data-subject export and erasure",
+	}
+	cmd.PersistentFlags().StringVarP(&g.Tenant, "tenant", "t", "", "gdpr export -t acme [user id], defaults to the default tenant")
+	export := &cobra.Command{
+		Use:   `export [user id]`,
+		Short: "Export everything held about a user as json",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return g.Export(cmd, args)
+		},
+	}
+	export.Flags().StringVarP(&g.Output, "output", "o", "", "gdpr export -o user.json")
+	erase := &cobra.Command{
+		Use:   `erase [user id]`,
+		Short: "Erase the personal data of a user and print the receipt",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return g.Erase(cmd, args)
+		},
+	}
+	cmd.AddCommand(export, erase)
+
+	return cmd
+}
+
+// Export writes the user bundle to the output file or stdout.
+func (g *gdprOptions) Export(cmd *cobra.Command, args []string) error {
+	return g.withUsecase(func(usc users.T) error {
+		export, err := usc.Export(withTenant(cmd.Context(), g.Tenant), args[0])
+		if err != nil {
+			return err
+		}
+		return g.write(export)
+	})
+}
+
+// Erase removes the personal data of the user and prints the receipt.
+func (g *gdprOptions) Erase(cmd *cobra.Command, args []string) error {
+	return g.withUsecase(func(usc users.T) error {
+		receipt, err := usc.Erase(withTenant(cmd.Context(), g.Tenant), args[0])
+		if err != nil {
+			return err
+		}
+		return g.write(receipt)
+	})
+}
+
+func (g *gdprOptions) withUsecase(fn func(usc users.T) error) error {
+	adaptor := syncAdapters()
+	defer func(a *adapters.Adapter) {
+		if err := a.UnSync(); err != nil {
+			log.Error().Err(err).Msg("there is failed on UnSync adapter")
+		}
+	}(adaptor)
+
+	usc, err := usecase.Get[users.T](adaptor)
+	if err != nil {
+		return err
+	}
+	// an erased user leaves its groups as well.
+	grp, err := usecase.Get[groups.T](adaptor)
+	if err != nil {
+		return err
+	}
+	grp.Cascade(usc)
+	idem, err := usecase.Get[idempotency.T](adaptor)
+	if err != nil {
+		return err
+	}
+	usc.OnErase(idem.Forget)
+	return fn(usc)
+}
+
+func (g *gdprOptions) write(v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if g.Output == "" {
+		fmt.Println(string(b))
+		return nil
+	}
+	return os.WriteFile(g.Output, b, 0o600)
+}
+
+// withTenant puts the tenant, or the default one, in ctx when tenancy is enabled.
+func withTenant(ctx context.Context, id string) context.Context {
+	tenancy := infrastructure.Envs.Tenancy
+	if !tenancy.Enable {
+		return ctx
+	}
+	if id == "" {
+		id = tenancy.Default
+	}
+	if id == "" {
+		return ctx
+	}
+	return tenant.WithID(ctx, id)
+}
@@ -0,0 +1,60 @@
+// Package cmd is the command surface of mongodbtest cli tool provided by kubuskotak.
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+	"github.com/kubuskotak/ymir-test/pkg/usecase"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+type reEncryptOptions struct {
+	Tenant string
+}
+
+func newReEncryptCmd() *cobra.Command {
+	r := &reEncryptOptions{}
+	cmd := &cobra.Command{
+		Use:   `reencrypt`,
+		Short: "Re-encrypt user personal data with the active key",
+		Long: "Re-encrypt user personal data with the active key.\n" +
+			"Run it after adding a new key to CRYPTO_KEYS and switching CRYPTO_ACTIVE_KEY, " +
+			"the previous key can be removed once it finished.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return r.Run(cmd, args)
+		},
+	}
+	cmd.Flags().StringVarP(&r.Tenant, "tenant", "t", "",
+		"reencrypt -t acme, required when every tenant has its own database")
+	return cmd
+}
+
+// Run re-encrypts every stored email that is not sealed with the active key.
+func (r *reEncryptOptions) Run(cmd *cobra.Command, _ []string) error {
+	adaptor := syncAdapters()
+	defer func() {
+		if err := adaptor.UnSync(); err != nil {
+			log.Error().Err(err).Msg("there is failed on UnSync adapter")
+		}
+	}()
+
+	usc, err := usecase.Get[users.T](adaptor)
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	if r.Tenant != "" {
+		ctx = tenant.WithID(ctx, r.Tenant)
+	}
+	result, err := usc.ReEncrypt(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Re-encrypted users: %d\n", result.Users)
+	fmt.Printf("Re-encrypted revisions: %d\n", result.Revisions)
+	return nil
+}
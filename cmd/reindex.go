@@ -0,0 +1,59 @@
+// Package cmd is the command surface of mongodbtest cli tool provided by kubuskotak.
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+	"github.com/kubuskotak/ymir-test/pkg/usecase"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+type reindexOptions struct {
+	Tenant string
+}
+
+func newReindexCmd() *cobra.Command {
+	r := &reindexOptions{}
+	cmd := &cobra.Command{
+		Use:   `reindex`,
+		Short: "Rebuild the search tokens and email domains of the users",
+		Long: "Rebuild the search tokens and email domains of the users.\n" +
+			"Run it once for the users stored before search and stats were released, " +
+			"and after the index key of CRYPTO_INDEX_KEY changed.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return r.Run(cmd, args)
+		},
+	}
+	cmd.Flags().StringVarP(&r.Tenant, "tenant", "t", "",
+		"reindex -t acme, required when every tenant has its own database")
+	return cmd
+}
+
+// Run rebuilds the search tokens and the email domain of every stored user.
+func (r *reindexOptions) Run(cmd *cobra.Command, _ []string) error {
+	adaptor := syncAdapters()
+	defer func() {
+		if err := adaptor.UnSync(); err != nil {
+			log.Error().Err(err).Msg("there is failed on UnSync adapter")
+		}
+	}()
+
+	usc, err := usecase.Get[users.T](adaptor)
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	if r.Tenant != "" {
+		ctx = tenant.WithID(ctx, r.Tenant)
+	}
+	updated, err := usc.Reindex(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Reindexed users: %d\n", updated)
+	return nil
+}
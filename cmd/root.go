@@ -4,9 +4,11 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/kubuskotak/asgard/common"
@@ -14,9 +16,17 @@ import (
 	pkgRest "github.com/kubuskotak/asgard/rest"
 	"github.com/kubuskotak/asgard/signal"
 	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/api/graphql"
+	"github.com/kubuskotak/ymir-test/pkg/api/grpc"
 	"github.com/kubuskotak/ymir-test/pkg/api/rest"
 	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/shared/metrics"
+	"github.com/kubuskotak/ymir-test/pkg/shared/ratelimit"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
 	"github.com/kubuskotak/ymir-test/pkg/usecase"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/groups"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/idempotency"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/stats"
 	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
 	"github.com/kubuskotak/ymir-test/pkg/version"
 	"github.com/rs/zerolog/log"
@@ -50,7 +60,7 @@ func NewRootCmd() *cobra.Command {
 		&root.Path, "config-path", "d", "./", "config dir path")
 
 	// subcommands
-	cmds.AddCommand(newVersionCmd(), newMigrateCmd(), newHotReloadCmd())
+	cmds.AddCommand(newVersionCmd(), newMigrateCmd(), newHotReloadCmd(), newReEncryptCmd(), newReindexCmd(), newAssignTenantCmd(), newGdprCmd())
 
 	// initialize configuration
 	infrastructure.Configuration(
@@ -106,21 +116,8 @@ func (r *rootOptions) runServer(_ *cobra.Command, _ []string) error {
 	/**
 	* Initialize Main
 	 */
-	adaptor := &adapters.Adapter{}
-	db := infrastructure.Envs.UserDataMongo //define var for store config
-
-	adapterMongo := adapters.WithUserDataMongo(&adapters.UserDataMongo{
-		NetworkDB: adapters.NetworkDB{
-			Database: db.Database,
-			Host:     db.Host,
-			Port:     db.Port,
-			User:     db.User,
-			Password: db.Password,
-		},
-	})
-
-	adaptor.Sync(adapterMongo) // adapters init
-	var errCh chan error
+	adaptor := syncAdapters() // adapters init
+	var errCh <-chan error
 	/**
 	* Initialize HTTP
 	 */
@@ -130,26 +127,169 @@ func (r *rootOptions) runServer(_ *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
+	grp, err := usecase.Get[groups.T](adaptor)
+	if err != nil {
+		return err
+	}
+	grp.Cascade(usc)
+	sts, err := usecase.Get[stats.T](adaptor)
+	if err != nil {
+		return err
+	}
+	idem, err := usecase.Get[idempotency.T](adaptor)
+	if err != nil {
+		return err
+	}
+	// stored responses about an erased user go with it.
+	usc.OnErase(idem.Forget)
 
 	h := pkgRest.NewServer(
 		pkgRest.WithPort(strconv.Itoa(infrastructure.Envs.Ports.HTTP)),
 	)
 	// http register handlers
-	h.Handler(rest.Routes().Register(
+	var routerOpts []rest.RouterOption
+	if infrastructure.Envs.RateLimit.Enable {
+		limiter, err := rateLimiter(adaptor)
+		if err != nil {
+			return err
+		}
+		routerOpts = append(routerOpts, rest.WithRateLimiter(limiter))
+	}
+	tenancy := infrastructure.Envs.Tenancy
+	tenants, err := tenant.NewResolver(tenancy.JWTSecret, tenancy.JWTClaim, tenancy.Default, tenancy.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	routerOpts = append(routerOpts, rest.WithTenantResolver(tenants))
+	health := rest.NewHealth(
+		rest.WithCheckers(adaptor.Checkers()...),
+		rest.WithCheckers(adapters.NamedCheck("migrations", usc.Migrated)),
+		rest.WithCheckers(adapters.NamedCheck("group_migrations", grp.Migrated)),
+	)
+	mongoRestHandler := rest.NewMongorest(
+		rest.WithUsersUsecase(usc),
+		rest.WithGroupsUsecase(grp),
+		rest.WithStatsUsecase(sts),
+		rest.WithIdempotencyUsecase(idem),
+	)
+	openAPIOpts := []rest.OpenAPIOption{
+		rest.WithOpenAPIInfo(infrastructure.Envs.App.ServiceName, version.GetVersion().VersionNumber()),
+	}
+	if infrastructure.Envs.RateLimit.Enable {
+		openAPIOpts = append(openAPIOpts, rest.WithOpenAPIRateLimit())
+	}
+	if tenancy.Enable {
+		openAPIOpts = append(openAPIOpts, rest.WithOpenAPITenancy())
+		for _, source := range tenancy.Sources {
+			if source == rest.TenantSourceHeader {
+				openAPIOpts = append(openAPIOpts, rest.WithOpenAPITenantHeader(tenancy.Header))
+			}
+		}
+	}
+	openAPI, err := rest.NewOpenAPI(mongoRestHandler, openAPIOpts...)
+	if err != nil {
+		return err
+	}
+	graphQLHandler, err := graphql.NewGraphQL(
+		graphql.WithUsersUsecase(usc),
+		graphql.WithLimits(infrastructure.Envs.GraphQL.MaxDepth, infrastructure.Envs.GraphQL.MaxComplexity),
+	)
+	if err != nil {
+		return err
+	}
+	handler := rest.Routes(routerOpts...).Register(
 		func(c chi.Router) http.Handler {
-			mongoRestHandler := rest.NewMongorest(rest.WithUsersUsecase(usc))
+			health.Register(c)
+			openAPI.Register(c)
 			mongoRestHandler.Register(c)
+			graphQLHandler.Register(c)
 			return c
 		},
-	))
+	)
+	if infrastructure.Envs.TLS.Enable && infrastructure.Envs.TLS.Redirect {
+		// probes stay on http, everything else moves to https.
+		redirect := chi.NewRouter()
+		health.Register(redirect)
+		redirect.NotFound(rest.RedirectHTTPS(infrastructure.Envs.Ports.HTTPS).ServeHTTP)
+		h.Handler(redirect)
+	} else {
+		h.Handler(handler)
+	}
 	if err := h.ListenAndServe(); err != nil {
 		return err
 	}
-	errCh = h.Error()
+	errChs := []chan error{h.Error()}
 	// end http
+	/**
+	* Initialize HTTPS
+	 */
+	var hs *rest.TLSServer
+	if infrastructure.Envs.TLS.Enable {
+		hs, err = rest.NewTLSServer(
+			rest.WithTLSPort(strconv.Itoa(infrastructure.Envs.Ports.HTTPS)),
+			rest.WithCertificate(infrastructure.Envs.TLS.CertFile, infrastructure.Envs.TLS.KeyFile),
+			rest.WithClientCA(infrastructure.Envs.TLS.ClientCAFile, infrastructure.Envs.TLS.ClientAuth),
+		)
+		if err != nil {
+			return err
+		}
+		hs.Handler(handler)
+		if err := hs.ListenAndServe(); err != nil {
+			return err
+		}
+		errChs = append(errChs, hs.Error())
+	}
+	// end https
+	/**
+	* Initialize gRPC
+	 */
+	var g *grpc.Server
+	if infrastructure.Envs.Ports.Grpc > 0 {
+		opts := []grpc.ServerOption{grpc.WithPort(strconv.Itoa(infrastructure.Envs.Ports.Grpc))}
+		if tenancy.Enable {
+			opts = append(opts, grpc.WithTenant(tenancy.Header, tenants))
+		}
+		if infrastructure.Envs.Reads.Causal {
+			opts = append(opts, grpc.WithCausalReads())
+		}
+		g = grpc.NewServer(opts...)
+		g.Register(grpc.NewUsers(grpc.WithUsersUsecase(usc)))
+		if err := g.ListenAndServe(); err != nil {
+			return err
+		}
+		errChs = append(errChs, g.Error())
+	}
+	// end grpc
+	/**
+	* Initialize Metric
+	 */
+	var m *pkgRest.Server
+	if infrastructure.Envs.Ports.Metric > 0 {
+		m = pkgRest.NewServer(
+			pkgRest.WithPort(strconv.Itoa(infrastructure.Envs.Ports.Metric)),
+		)
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		m.Handler(mux)
+		if err := m.ListenAndServe(); err != nil {
+			return err
+		}
+		errChs = append(errChs, m.Error())
+	}
+	// end metric
+	errCh = mergeErrors(errChs...)
 	stopCh := signal.SetupSignalHandler()
-	return signal.Graceful(infrastructure.Envs.Server.Timeout, stopCh, errCh, func(ctx context.Context) {
+	return signal.Graceful(infrastructure.Envs.Server.Timeout, stopCh, errCh, func(context.Context) {
 		log.Info().Dur("timeout", infrastructure.Envs.Server.Timeout).Msg("Shutting down HTTP/HTTPS server")
+		health.Shutdown() // readiness first, so no new traffic is routed here
+		// the load balancers only stop routing here after their next probes.
+		if drain := infrastructure.Envs.Server.Drain; drain > 0 {
+			log.Info().Dur("drain", drain).Msg("Draining traffic before stopping the servers")
+			time.Sleep(drain)
+		}
+		// the in-flight requests get the timeout once the traffic is drained.
+		ctx, cancel := context.WithTimeout(context.Background(), infrastructure.Envs.Server.Timeout)
+		defer cancel()
 		// open-telemetry
 		if infrastructure.Envs.Telemetry.CollectorEnable {
 			if err := cleanupTracer(context.Background()); err != nil {
@@ -159,11 +299,28 @@ func (r *rootOptions) runServer(_ *cobra.Command, _ []string) error {
 				log.Error().Err(err).Msg("metric provider server is failed shutdown")
 			}
 		}
+		// grpc
+		if g != nil {
+			if err := g.Quite(ctx); err != nil {
+				log.Error().Err(err).Msg("grpc server is failed shutdown")
+			}
+		}
 		// rest
+		if hs != nil {
+			if err := hs.Quite(ctx); err != nil {
+				log.Error().Err(err).Msg("https server is failed shutdown")
+			}
+		}
 		if err := h.Quite(context.Background()); err != nil {
 			log.Error().Err(err).Msg("http server is failed shutdown")
 		}
 		h.Stop()
+		if m != nil {
+			if err := m.Quite(context.Background()); err != nil {
+				log.Error().Err(err).Msg("metric server is failed shutdown")
+			}
+			m.Stop()
+		}
 		// adapters
 		if err := adaptor.UnSync(); err != nil {
 			log.Error().Err(err).Msg("there is failed on UnSync adapter")
@@ -171,6 +328,103 @@ func (r *rootOptions) runServer(_ *cobra.Command, _ []string) error {
 	}) // graceful shutdown
 }
 
+// syncAdapters connects all adapters from the configuration.
+func syncAdapters() *adapters.Adapter {
+	adaptor := &adapters.Adapter{}
+
+	adapterMongo := adapters.WithUserDataMongo(mongoDriver(infrastructure.Envs.UserDataMongo))
+	adaptor.Sync(adapterMongo)
+	for name, db := range infrastructure.Envs.MongoConnections {
+		adaptor.Sync(adapters.WithMongo(name, mongoDriver(db)))
+	}
+	adaptor.Sync(adapters.WithCollections(infrastructure.Envs.Collections))
+	if tenancy := infrastructure.Envs.Tenancy; tenancy.Enable && tenancy.DatabasePerTenant {
+		adaptor.Sync(adapters.WithDatabasePerTenant(tenancy.DatabasePrefix))
+	}
+
+	if rds := infrastructure.Envs.Redis; rds.Host != "" {
+		adaptor.Sync(adapters.WithRedis(&adapters.Redis{
+			NetworkDB: adapters.NetworkDB{
+				Host:     rds.Host,
+				Port:     rds.Port,
+				User:     rds.User,
+				Password: rds.Password,
+			},
+			DB: rds.DB,
+		}))
+	}
+	return adaptor
+}
+
+// mongoDriver maps a mongo config on its adapter.
+func mongoDriver(db infrastructure.Mongo) *adapters.UserDataMongo {
+	return &adapters.UserDataMongo{
+		NetworkDB: adapters.NetworkDB{
+			Protocol: db.Protocol,
+			Database: db.Database,
+			Host:     db.Host,
+			Port:     db.Port,
+			User:     db.User,
+			Password: db.Password,
+		},
+		Options: adapters.MongoOptions{
+			URI:                    db.URI,
+			Auth:                   db.Auth,
+			AppName:                db.AppName,
+			ReplicaSet:             db.ReplicaSet,
+			AuthSource:             db.AuthSource,
+			AuthMechanism:          db.AuthMechanism,
+			TLS:                    db.TLS,
+			TLSCAFile:              db.TLSCAFile,
+			TLSCertKeyFile:         db.TLSCertKeyFile,
+			TLSInsecure:            db.TLSInsecure,
+			MinPoolSize:            db.MinPoolSize,
+			MaxPoolSize:            db.MaxPoolSize,
+			MaxConnIdleTime:        db.MaxConnIdleTime,
+			ConnectTimeout:         db.ConnectTimeout,
+			ServerSelectionTimeout: db.ServerSelectionTimeout,
+			SocketTimeout:          db.SocketTimeout,
+			ReadPreference:         db.ReadPreference,
+			ReadConcern:            db.ReadConcern,
+			WriteConcern:           db.WriteConcern,
+			WriteTimeout:           db.WriteTimeout,
+			Journal:                db.Journal,
+			Compressors:            db.Compressors,
+		},
+		Retry:           adapters.ExponentialRetry(db.StartupRetries, db.StartupBackoff, db.StartupMaxBackoff),
+		Degraded:        db.StartDegraded,
+		MonitorInterval: db.MonitorInterval,
+	}
+}
+
+// rateLimiter returns the limiter of the configured backend.
+func rateLimiter(adaptor *adapters.Adapter) (ratelimit.Limiter, error) {
+	switch backend := infrastructure.Envs.RateLimit.Backend; backend {
+	case "", "memory":
+		return ratelimit.NewMemory(), nil
+	case "redis":
+		if adaptor.Redis == nil {
+			return nil, errors.New("rate limit backend redis requires the Redis config")
+		}
+		return ratelimit.NewRedis(adaptor.Redis.Client, infrastructure.Envs.App.ServiceName+":ratelimit:"), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", backend)
+	}
+}
+
+// mergeErrors fans in the error channels of every server.
+func mergeErrors(chs ...chan error) <-chan error {
+	out := make(chan error, len(chs))
+	for _, ch := range chs {
+		go func(ch chan error) {
+			for err := range ch {
+				out <- err
+			}
+		}(ch)
+	}
+	return out
+}
+
 // Execute is the execute command for root command.
 func Execute() error {
 	return NewRootCmd().Execute()
@@ -0,0 +1,80 @@
+// Package cmd is the command surface of mongodbtest cli tool provided by kubuskotak.
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/usecase"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/groups"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+type assignTenantOptions struct {
+	Tenant string
+}
+
+func newAssignTenantCmd() *cobra.Command {
+	a := &assignTenantOptions{}
+	cmd := &cobra.Command{
+		Use:   `assign-tenant`,
+		Short: "Give the users and groups stored before tenancy to a tenant",
+		Long: "Give the users and groups stored before tenancy to a tenant.\n" +
+			"Documents without a tenant_id are invisible once Tenancy is enabled, run it " +
+			"once before enabling it, or before other tenants are served.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.Run(cmd, args)
+		},
+	}
+	cmd.Flags().StringVarP(&a.Tenant, "tenant", "t", "",
+		"assign-tenant -t acme, defaults to the default tenant")
+	return cmd
+}
+
+// Run sets the tenant of every user, group and what is held about them without one.
+func (a *assignTenantOptions) Run(cmd *cobra.Command, _ []string) error {
+	id := a.Tenant
+	if id == "" {
+		id = infrastructure.Envs.Tenancy.Default
+	}
+	if id == "" {
+		return errors.New("no tenant given and Tenancy.default is not set")
+	}
+	adaptor := syncAdapters()
+	defer func() {
+		if err := adaptor.UnSync(); err != nil {
+			log.Error().Err(err).Msg("there is failed on UnSync adapter")
+		}
+	}()
+
+	usc, err := usecase.Get[users.T](adaptor)
+	if err != nil {
+		return err
+	}
+	grp, err := usecase.Get[groups.T](adaptor)
+	if err != nil {
+		return err
+	}
+	assigned, err := usc.AssignTenant(cmd.Context(), id)
+	if err == nil {
+		var grouped map[string]int64
+		grouped, err = grp.AssignTenant(cmd.Context(), id)
+		for name, n := range grouped {
+			assigned[name] = n
+		}
+	}
+	names := make([]string, 0, len(assigned))
+	for name := range assigned {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("Assigned %s to %s: %d\n", name, id, assigned[name])
+	}
+	return err
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
 )
 
 type client interface {
@@ -29,6 +31,12 @@ type Driver[T client] interface {
 type Adapter struct {
 	UserDataMongo *UserDataMongo
 	PersistUsers  *mongo.Database
+	Redis         *Redis
+
+	mongos      map[string]*UserDataMongo // named connections, see Mongo
+	collections map[string]infrastructure.MongoCollection
+	txn         transactions // see WithTransaction
+	tenantDBs   string       // database name prefix of tenants, see WithDatabasePerTenant
 }
 
 // Option is Adapter type return func.
@@ -51,6 +59,15 @@ func (a *Adapter) UnSync() error {
 			errs = append(errs, err.Error())
 		}
 	}
+	for _, err := range a.disconnectMongos() {
+		errs = append(errs, err.Error())
+	}
+	if a.Redis != nil {
+		log.Info().Msg("Redis is closed")
+		if err := a.Redis.Disconnect(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
 	if len(errs) > 0 {
 		err := fmt.Errorf(strings.Join(errs, "\n"))
 		log.Error().Err(err).Msg("UnSync adapter error")
@@ -0,0 +1,65 @@
+// Package adapters are the glue between components and external sources.
+package adapters
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Checker is implemented by the adapters able to report their health.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type namedCheck struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (c namedCheck) Name() string                    { return c.name }
+func (c namedCheck) Check(ctx context.Context) error { return c.fn(ctx) }
+
+// NamedCheck adapts a function into a Checker.
+func NamedCheck(name string, fn func(ctx context.Context) error) Checker {
+	return namedCheck{name: name, fn: fn}
+}
+
+// Checkers returns the connected adapters implementing Checker.
+func (a *Adapter) Checkers() []Checker {
+	var checkers []Checker
+	if a.UserDataMongo != nil {
+		checkers = append(checkers, a.UserDataMongo)
+	}
+	for _, usda := range a.mongos {
+		checkers = append(checkers, usda)
+	}
+	if a.Redis != nil {
+		checkers = append(checkers, a.Redis)
+	}
+	return checkers
+}
+
+// Name is the dependency name of UserDataMongo, named connections are "mongo.<name>".
+func (usda *UserDataMongo) Name() string {
+	if usda.name == "" {
+		return "mongo"
+	}
+	return "mongo." + usda.name
+}
+
+// Check pings the primary of UserDataMongo.
+func (usda *UserDataMongo) Check(ctx context.Context) error {
+	return usda.Client.Ping(ctx, readpref.Primary())
+}
+
+// Name is the dependency name of Redis.
+func (rds *Redis) Name() string {
+	return "redis"
+}
+
+// Check pings Redis.
+func (rds *Redis) Check(ctx context.Context) error {
+	return rds.Client.Ping(ctx).Err()
+}
@@ -0,0 +1,174 @@
+// Package adapters are the glue between components and external sources.
+package adapters
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// MongoOptions are the client settings of a mongodb connection, zero values keep
+// the settings of the connection string or the driver defaults.
+type MongoOptions struct {
+	URI  string // full connection string, replaces protocol, host and port
+	Auth bool   // authenticate with the user and password of NetworkDB
+
+	AppName       string
+	ReplicaSet    string
+	AuthSource    string
+	AuthMechanism string
+
+	TLS            bool
+	TLSCAFile      string // pem bundle verifying the server
+	TLSCertKeyFile string // pem holding the client certificate and its key
+	TLSInsecure    bool
+
+	MinPoolSize     uint64
+	MaxPoolSize     uint64
+	MaxConnIdleTime time.Duration
+
+	ConnectTimeout         time.Duration
+	ServerSelectionTimeout time.Duration
+	SocketTimeout          time.Duration
+
+	ReadPreference string // primary, primaryPreferred, secondary, secondaryPreferred or nearest
+	ReadConcern    string // local, available, majority, linearizable or snapshot
+	WriteConcern   string // majority, a number of nodes or a tag set
+	WriteTimeout   time.Duration
+	Journal        bool
+
+	Compressors []string // snappy, zlib or zstd
+}
+
+// apply sets the options on the client options.
+func (o MongoOptions) apply(opts *options.ClientOptions) error {
+	if o.AuthSource != "" || o.AuthMechanism != "" {
+		var cred options.Credential
+		if opts.Auth != nil {
+			cred = *opts.Auth
+		}
+		cred = o.credential(cred)
+		// the certificate or the environment names the user of these mechanisms.
+		if cred.Username == "" && cred.AuthMechanism != "MONGODB-X509" && cred.AuthMechanism != "MONGODB-AWS" {
+			return errors.New("auth source and mechanism need a user, enable auth or set it in the uri")
+		}
+		opts.SetAuth(cred)
+	}
+	if o.AppName != "" {
+		opts.SetAppName(o.AppName)
+	}
+	if o.ReplicaSet != "" {
+		opts.SetReplicaSet(o.ReplicaSet)
+	}
+	if o.TLS || o.TLSCAFile != "" || o.TLSCertKeyFile != "" {
+		conf, err := o.tlsConfig()
+		if err != nil {
+			return err
+		}
+		opts.SetTLSConfig(conf)
+	}
+	if o.MinPoolSize > 0 {
+		opts.SetMinPoolSize(o.MinPoolSize)
+	}
+	if o.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(o.MaxPoolSize)
+	}
+	if o.MaxConnIdleTime > 0 {
+		opts.SetMaxConnIdleTime(o.MaxConnIdleTime)
+	}
+	if o.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(o.ConnectTimeout)
+	}
+	if o.ServerSelectionTimeout > 0 {
+		opts.SetServerSelectionTimeout(o.ServerSelectionTimeout)
+	}
+	if o.SocketTimeout > 0 {
+		opts.SetSocketTimeout(o.SocketTimeout)
+	}
+	if o.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(o.ReadPreference)
+		if err != nil {
+			return err
+		}
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return err
+		}
+		opts.SetReadPreference(rp)
+	}
+	if o.ReadConcern != "" {
+		opts.SetReadConcern(&readconcern.ReadConcern{Level: o.ReadConcern})
+	}
+	if o.WriteConcern != "" || o.Journal || o.WriteTimeout > 0 {
+		// only the fields set here override the write concern of the connection string.
+		wc := &writeconcern.WriteConcern{}
+		if opts.WriteConcern != nil {
+			*wc = *opts.WriteConcern
+		}
+		if n, err := strconv.Atoi(o.WriteConcern); err == nil {
+			wc.W = n
+		} else if o.WriteConcern != "" {
+			wc.W = o.WriteConcern
+		}
+		if o.WriteTimeout > 0 {
+			wc.WTimeout = o.WriteTimeout
+		}
+		if o.Journal {
+			wc.Journal = &o.Journal
+		}
+		opts.SetWriteConcern(wc)
+	}
+	if len(o.Compressors) > 0 {
+		opts.SetCompressors(o.Compressors)
+	}
+	return nil
+}
+
+// credential extends the credential of the connection string or of auth.
+func (o MongoOptions) credential(cred options.Credential) options.Credential {
+	if o.AuthSource != "" {
+		cred.AuthSource = o.AuthSource
+	}
+	if o.AuthMechanism != "" {
+		cred.AuthMechanism = o.AuthMechanism
+	}
+	return cred
+}
+
+func (o MongoOptions) tlsConfig() (*tls.Config, error) {
+	conf := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: o.TLSInsecure, //nolint:gosec // opt-in for development clusters
+	}
+	if o.TLSCAFile != "" {
+		pem, err := os.ReadFile(o.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read mongo tls ca file: %w", err)
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("mongo tls ca file has no certificate")
+		}
+	}
+	if o.TLSCertKeyFile != "" {
+		pem, err := os.ReadFile(o.TLSCertKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read mongo tls certificate key file: %w", err)
+		}
+		cert, err := tls.X509KeyPair(pem, pem)
+		if err != nil {
+			return nil, fmt.Errorf("load mongo tls certificate key file: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
@@ -0,0 +1,151 @@
+// Package adapters are the glue between components and external sources.
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+// DefaultMongo is the registry name of UserDataMongo.
+const DefaultMongo = "userdata"
+
+// SessionCollections are used in the transactions of WithTransaction and in causally
+// consistent sessions, both started on PersistUsers. A session only works on the
+// client it was started on, they cannot be mapped to another connection.
+var SessionCollections = []string{"users", "user_revisions", "user_attributes", "erasure_receipts", "groups", "group_members"}
+
+// Mongo returns the named connection, DefaultMongo is UserDataMongo.
+func (a *Adapter) Mongo(name string) (*UserDataMongo, error) {
+	if name == "" || name == DefaultMongo {
+		if a.UserDataMongo == nil {
+			return nil, fmt.Errorf("mongo connection %q is not configured", DefaultMongo)
+		}
+		return a.UserDataMongo, nil
+	}
+	usda, ok := a.mongos[name]
+	if !ok {
+		return nil, fmt.Errorf("mongo connection %q is not configured", name)
+	}
+	return usda, nil
+}
+
+// Collection returns the collection the name is mapped to, unmapped names are
+// collections of PersistUsers.
+func (a *Adapter) Collection(name string) *mongo.Collection {
+	mapping, ok := a.collections[name]
+	if !ok {
+		return a.PersistUsers.Collection(name)
+	}
+	if mapping.Name == "" {
+		mapping.Name = name
+	}
+	if mapping.Connection == "" || mapping.Connection == DefaultMongo {
+		return a.PersistUsers.Collection(mapping.Name)
+	}
+	// mappings are checked by WithCollections, the connection exists.
+	usda := a.mongos[mapping.Connection]
+	return usda.Client.Database(usda.Database).Collection(mapping.Name)
+}
+
+// TenantCollection returns Collection, moved into the database of the tenant of ctx
+// when every tenant has its own database.
+func (a *Adapter) TenantCollection(ctx context.Context, name string) *mongo.Collection {
+	coll := a.Collection(name)
+	id := tenant.FromContext(ctx)
+	if a.tenantDBs == "" || id == "" {
+		return coll
+	}
+	return coll.Database().Client().Database(a.tenantDBs + id).Collection(coll.Name())
+}
+
+// AssignTenant gives the documents stored before tenancy, those without a tenant_id, to
+// the tenant id. It returns how many documents of each collection were assigned.
+func (a *Adapter) AssignTenant(ctx context.Context, id string, names ...string) (map[string]int64, error) {
+	if !tenant.Valid(id) {
+		return nil, tenant.ErrInvalid
+	}
+	if a.tenantDBs != "" {
+		return nil, errors.New("documents cannot be assigned to a tenant with a database per tenant, move them into its database")
+	}
+	assigned := make(map[string]int64, len(names))
+	for _, name := range names {
+		result, err := a.Collection(name).UpdateMany(ctx,
+			bson.D{{Key: "tenant_id", Value: bson.D{{Key: "$exists", Value: false}}}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "tenant_id", Value: id}}}},
+		)
+		if err != nil {
+			return assigned, fmt.Errorf("assign %s to tenant %s: %w", name, id, err)
+		}
+		assigned[name] = result.ModifiedCount
+	}
+	return assigned, nil
+}
+
+// WithDatabasePerTenant option function to keep every tenant in a database named
+// prefix followed by the tenant id, on the connection of each collection.
+func WithDatabasePerTenant(prefix string) Option {
+	return func(a *Adapter) {
+		if prefix == "" {
+			panic("database prefix of tenants is required")
+		}
+		a.tenantDBs = prefix
+	}
+}
+
+// WithMongo option function to assign a named connection on adapters.
+func WithMongo(name string, driver Driver[*mongo.Client]) Option {
+	return func(a *Adapter) {
+		if name == "" || name == DefaultMongo {
+			panic(fmt.Sprintf("mongo connection name %q is reserved for UserDataMongo", name))
+		}
+		usda := driver.(*UserDataMongo)
+		usda.name = name
+		connect(usda)
+		if a.mongos == nil {
+			a.mongos = map[string]*UserDataMongo{}
+		}
+		a.mongos[name] = usda
+	}
+}
+
+// WithCollections option function to assign the collection mapping on adapters,
+// the connections must be synced first. SessionCollections may only be renamed.
+func WithCollections(collections map[string]infrastructure.MongoCollection) Option {
+	return func(a *Adapter) {
+		for name, mapping := range collections {
+			if _, err := a.Mongo(mapping.Connection); err != nil {
+				panic(fmt.Errorf("collection %s: %w", name, err))
+			}
+			if mapping.Connection == "" || mapping.Connection == DefaultMongo {
+				continue
+			}
+			for _, session := range SessionCollections {
+				if name == session {
+					panic(fmt.Errorf("collection %s is written in transactions on %s, it cannot move to %s",
+						name, DefaultMongo, mapping.Connection))
+				}
+			}
+		}
+		a.collections = collections
+	}
+}
+
+// disconnectMongos closes the named connections.
+func (a *Adapter) disconnectMongos() []error {
+	var errs []error
+	for name, usda := range a.mongos {
+		log.Info().Str("adapter", usda.Name()).Msg("Mongo is closed")
+		if err := usda.Disconnect(); err != nil {
+			errs = append(errs, fmt.Errorf("mongo %s: %w", name, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,110 @@
+// Package adapters are the glue between components and external sources.
+package adapters
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+func TestAdapterCollection(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("mapping", func(tt *mtest.T) {
+		adapter := &Adapter{
+			UserDataMongo: &UserDataMongo{Client: tt.Client},
+			PersistUsers:  tt.DB,
+			mongos: map[string]*UserDataMongo{
+				"analytics": {NetworkDB: NetworkDB{Database: "analytics"}, Client: tt.Client, name: "analytics"},
+			},
+		}
+		adapter.Sync(WithCollections(map[string]infrastructure.MongoCollection{
+			"users":            {Name: "people"},
+			"idempotency_keys": {Connection: "analytics"},
+		}))
+
+		for name, want := range map[string][2]string{
+			"users":            {tt.DB.Name(), "people"},
+			"idempotency_keys": {"analytics", "idempotency_keys"},
+			"erasure_receipts": {tt.DB.Name(), "erasure_receipts"},
+		} {
+			coll := adapter.Collection(name)
+			if coll.Database().Name() != want[0] || coll.Name() != want[1] {
+				tt.Fatalf("%s: expected %v, got %s.%s", name, want, coll.Database().Name(), coll.Name())
+			}
+		}
+
+		if _, err := adapter.Mongo("reporting"); err == nil {
+			tt.Fatal("expected error for an unknown connection")
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					tt.Fatal("expected a collection of the transactions moved to another connection to panic")
+				}
+			}()
+			adapter.Sync(WithCollections(map[string]infrastructure.MongoCollection{
+				"user_revisions": {Connection: "analytics"},
+			}))
+		}()
+		defer func() {
+			if recover() == nil {
+				tt.Fatal("expected mapping to an unknown connection to panic")
+			}
+		}()
+		adapter.Sync(WithCollections(map[string]infrastructure.MongoCollection{
+			"users": {Connection: "reporting"},
+		}))
+	})
+	mt.Run("database per tenant", func(tt *mtest.T) {
+		adapter := &Adapter{PersistUsers: tt.DB}
+		adapter.Sync(WithDatabasePerTenant("tenant_"))
+
+		if coll := adapter.TenantCollection(context.Background(), "users"); coll.Database().Name() != tt.DB.Name() {
+			tt.Fatalf("expected %s without a tenant, got %s", tt.DB.Name(), coll.Database().Name())
+		}
+		coll := adapter.TenantCollection(tenant.WithID(context.Background(), "acme"), "users")
+		if coll.Database().Name() != "tenant_acme" || coll.Name() != "users" {
+			tt.Fatalf("expected tenant_acme.users, got %s.%s", coll.Database().Name(), coll.Name())
+		}
+	})
+}
+
+func TestAssignTenant(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("documents without a tenant", func(tt *mtest.T) {
+		adapter := &Adapter{PersistUsers: tt.DB}
+		tt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 3}, {Key: "nModified", Value: 3}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}},
+		)
+
+		assigned, err := adapter.AssignTenant(context.Background(), "acme", "users", "user_revisions")
+		if err != nil || assigned["users"] != 3 || assigned["user_revisions"] != 0 {
+			tt.Fatalf("unexpected assignment %v %v", assigned, err)
+		}
+		update := tt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		if update.Lookup("q", "tenant_id", "$exists").Boolean() || update.Lookup("u", "$set", "tenant_id").StringValue() != "acme" {
+			tt.Fatalf("expected the documents without a tenant to get acme, got %v", update)
+		}
+	})
+
+	mt.Run("rejected", func(tt *mtest.T) {
+		if _, err := (&Adapter{PersistUsers: tt.DB}).AssignTenant(context.Background(), "no/tenant", "users"); err == nil {
+			tt.Fatal("expected an invalid tenant to be rejected")
+		}
+		separate := &Adapter{PersistUsers: tt.DB}
+		separate.Sync(WithDatabasePerTenant("tenant_"))
+		if _, err := separate.AssignTenant(context.Background(), "acme", "users"); err == nil {
+			tt.Fatal("expected a database per tenant to be rejected")
+		}
+	})
+}
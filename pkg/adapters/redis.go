@@ -0,0 +1,59 @@
+// Package adapters are the glue between components and external sources.
+package adapters
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var RedisOpen = redis.NewClient // RedisOpen will invoke to test case.
+
+// Redis is data of instances.
+type Redis struct {
+	NetworkDB
+	DB     int
+	Client *redis.Client
+}
+
+// Open is open the connection of Redis.
+func (rds *Redis) Open() (*redis.Client, error) {
+	if rds.Client == nil {
+		return nil, fmt.Errorf("driver was failed to connected")
+	}
+	return rds.Client, nil
+}
+
+// Connect is connected the connection of Redis.
+func (rds *Redis) Connect() error {
+	rds.Client = RedisOpen(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", rds.Host, rds.Port),
+		Username: rds.User,
+		Password: rds.Password,
+		DB:       rds.DB,
+	})
+	return nil
+}
+
+// Disconnect is disconnect the connection of Redis.
+func (rds *Redis) Disconnect() error {
+	return rds.Client.Close()
+}
+
+// WithRedis option function to assign on adapters.
+func WithRedis(driver Driver[*redis.Client]) Option {
+	return func(a *Adapter) {
+		if err := driver.Connect(); err != nil {
+			panic(err)
+		}
+		open, err := driver.Open()
+		if err != nil {
+			panic(err)
+		}
+		if err := open.Ping(context.Background()).Err(); err != nil {
+			panic(err)
+		}
+		a.Redis = driver.(*Redis)
+	}
+}
@@ -0,0 +1,59 @@
+// Package adapters are the glue between components and external sources.
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+// Scope restricts a filter to the tenant of ctx.
+func Scope(ctx context.Context, filter bson.D) bson.D {
+	if id := tenant.FromContext(ctx); id != "" {
+		return append(filter, bson.E{Key: "tenant_id", Value: id})
+	}
+	return filter
+}
+
+// HasIndex reports whether an index of specs is named name.
+func HasIndex(specs []*mongo.IndexSpecification, name string) bool {
+	for _, spec := range specs {
+		if spec.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadPreference parses the read preference of a mode name such as secondaryPreferred,
+// a positive maxStaleness bounds the lag of the secondaries read. An empty mode is nil.
+func ReadPreference(mode string, maxStaleness time.Duration) (*readpref.ReadPref, error) {
+	if mode == "" {
+		return nil, nil
+	}
+	m, err := readpref.ModeFromString(mode)
+	if err != nil {
+		return nil, err
+	}
+	var opts []readpref.Option
+	if maxStaleness > 0 {
+		opts = append(opts, readpref.WithMaxStaleness(maxStaleness))
+	}
+	return readpref.New(m, opts...)
+}
+
+// ReadCollection returns TenantCollection read with the read preference, nil keeps the
+// preference of its connection.
+func (a *Adapter) ReadCollection(ctx context.Context, name string, pref *readpref.ReadPref) *mongo.Collection {
+	coll := a.TenantCollection(ctx, name)
+	if pref == nil {
+		return coll
+	}
+	return coll.Database().Collection(coll.Name(), options.Collection().SetReadPreference(pref))
+}
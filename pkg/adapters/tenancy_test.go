@@ -0,0 +1,50 @@
+// Package adapters are the glue between components and external sources.
+package adapters
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+func TestScope(t *testing.T) {
+	filter := Scope(tenant.WithID(context.Background(), "acme"), bson.D{{Key: "user_id", Value: "1"}})
+	if len(filter) != 2 || filter[1].Key != "tenant_id" || filter[1].Value != "acme" {
+		t.Fatalf("expected the filter to be scoped to acme, got %v", filter)
+	}
+	if filter := Scope(context.Background(), bson.D{}); len(filter) != 0 {
+		t.Fatalf("expected no scope without a tenant, got %v", filter)
+	}
+}
+
+func TestHasIndex(t *testing.T) {
+	specs := []*mongo.IndexSpecification{{Name: "_id_"}, {Name: "user_id"}}
+	if !HasIndex(specs, "user_id") {
+		t.Fatal("expected user_id to be found")
+	}
+	if HasIndex(specs, "email") {
+		t.Fatal("expected email not to be found")
+	}
+}
+
+func TestReadPreference(t *testing.T) {
+	if pref, err := ReadPreference("", time.Minute); pref != nil || err != nil {
+		t.Fatalf("expected no preference, got %v %v", pref, err)
+	}
+	if _, err := ReadPreference("anywhere", 0); err == nil {
+		t.Fatal("expected error for an unknown read preference")
+	}
+	pref, err := ReadPreference("secondaryPreferred", 90*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if staleness, _ := pref.MaxStaleness(); pref.Mode() != readpref.SecondaryPreferredMode || staleness != 90*time.Second {
+		t.Fatalf("unexpected preference %v", pref)
+	}
+}
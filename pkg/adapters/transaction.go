@@ -0,0 +1,130 @@
+// Package adapters are the glue between components and external sources.
+package adapters
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxTransactionAttempts bounds the retries of a transaction and of its commit.
+const maxTransactionAttempts = 5
+
+// ErrTransactionRace is wrapped by fn of WithTransaction when it lost a race the whole
+// transaction has to be retried for, the server aborts a transaction on its first error.
+var ErrTransactionRace = errors.New("transaction lost a race")
+
+type ctxTransactionKey struct{}
+
+// transactions caches whether the server of PersistUsers supports transactions.
+type transactions struct {
+	mu        sync.Mutex
+	known     bool
+	supported bool
+}
+
+// WithTransaction runs fn in a transaction on the PersistUsers client. The whole
+// transaction is retried on TransientTransactionError and the commit on
+// UnknownTransactionCommitResult. A session already in ctx, such as a causally
+// consistent one, is reused. Standalone servers have no transactions, there fn
+// runs in a plain session. Collections used by fn must share the client of the session,
+// see SessionCollections.
+func (a *Adapter) WithTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	client := a.PersistUsers.Client()
+	sess := mongo.SessionFromContext(ctx)
+	if sess == nil {
+		var err error
+		if sess, err = client.StartSession(); err != nil {
+			return err
+		}
+		defer sess.EndSession(ctx)
+	}
+	sc := mongo.NewSessionContext(ctx, sess)
+
+	supported, err := a.transactionsSupported(ctx)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return fn(sc)
+	}
+	return runTransaction(mongo.NewSessionContext(context.WithValue(sc, ctxTransactionKey{}, true), sess), fn)
+}
+
+// InTransaction reports whether ctx runs in a transaction of WithTransaction.
+func InTransaction(ctx context.Context) bool {
+	running, _ := ctx.Value(ctxTransactionKey{}).(bool)
+	return running
+}
+
+func runTransaction(sc mongo.SessionContext, fn func(sessCtx mongo.SessionContext) error) (err error) {
+	for attempt := 1; attempt <= maxTransactionAttempts; attempt++ {
+		if err = sc.StartTransaction(); err != nil {
+			return err
+		}
+		if err = fn(sc); err != nil {
+			_ = sc.AbortTransaction(context.Background())
+			if hasErrorLabel(err, driverTransientTransactionError) || errors.Is(err, ErrTransactionRace) {
+				log.Warn().Err(err).Int("attempt", attempt).Msg("transaction is retried")
+				continue
+			}
+			return err
+		}
+		if err = commit(sc); hasErrorLabel(err, driverTransientTransactionError) {
+			log.Warn().Err(err).Int("attempt", attempt).Msg("transaction is retried")
+			continue
+		}
+		return err
+	}
+	return err
+}
+
+// commit retries the commit while its result is unknown, committing twice is safe.
+func commit(sc mongo.SessionContext) (err error) {
+	for attempt := 1; attempt <= maxTransactionAttempts; attempt++ {
+		if err = sc.CommitTransaction(sc); !hasErrorLabel(err, driverUnknownTransactionCommitResult) {
+			return err
+		}
+		log.Warn().Err(err).Int("attempt", attempt).Msg("transaction commit is retried")
+	}
+	return err
+}
+
+// Error labels of the transaction errors.
+const (
+	driverTransientTransactionError      = "TransientTransactionError"
+	driverUnknownTransactionCommitResult = "UnknownTransactionCommitResult"
+)
+
+func hasErrorLabel(err error, label string) bool {
+	var se mongo.ServerError
+	return errors.As(err, &se) && se.HasErrorLabel(label)
+}
+
+// transactionsSupported asks the server once whether it is a replica set member or
+// a mongos, a standalone server has no transactions.
+func (a *Adapter) transactionsSupported(ctx context.Context) (bool, error) {
+	a.txn.mu.Lock()
+	defer a.txn.mu.Unlock()
+	if a.txn.known {
+		return a.txn.supported, nil
+	}
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := a.PersistUsers.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return false, err
+	}
+	a.txn.known = true
+	a.txn.supported = hello.SetName != "" || hello.Msg == "isdbgrid"
+	if !a.txn.supported {
+		log.Warn().Msg("Mongo is a standalone server, writes run without transactions")
+	}
+	return a.txn.supported, nil
+}
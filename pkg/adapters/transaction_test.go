@@ -0,0 +1,86 @@
+// Package adapters are the glue between components and external sources.
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestWithTransaction(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("standalone", func(tt *mtest.T) {
+		a := &Adapter{PersistUsers: tt.DB}
+		tt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "isWritablePrimary", Value: true}))
+
+		var calls int
+		err := a.WithTransaction(context.Background(), func(sc mongo.SessionContext) error {
+			calls++
+			return nil
+		})
+		if err != nil || calls != 1 {
+			tt.Fatalf("expected one plain run, got %d calls: %v", calls, err)
+		}
+		if a.txn.supported {
+			tt.Fatal("standalone server must not run transactions")
+		}
+	})
+
+	mt.Run("transient error is retried", func(tt *mtest.T) {
+		a := &Adapter{PersistUsers: tt.DB}
+		tt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "setName", Value: "rs0"}))
+
+		var calls int
+		err := a.WithTransaction(context.Background(), func(sc mongo.SessionContext) error {
+			calls++
+			if calls == 1 {
+				return mongo.CommandError{Code: 112, Name: "WriteConflict", Labels: []string{driverTransientTransactionError}}
+			}
+			return nil
+		})
+		if err != nil || calls != 2 {
+			tt.Fatalf("expected a retry, got %d calls: %v", calls, err)
+		}
+	})
+
+	mt.Run("lost race is retried", func(tt *mtest.T) {
+		a := &Adapter{PersistUsers: tt.DB}
+		tt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "setName", Value: "rs0"}))
+
+		var calls int
+		err := a.WithTransaction(context.Background(), func(sc mongo.SessionContext) error {
+			calls++
+			if !InTransaction(sc) {
+				return errors.New("expected a transaction in ctx")
+			}
+			if calls == 1 {
+				return fmt.Errorf("revision 2 is taken: %w", ErrTransactionRace)
+			}
+			return nil
+		})
+		if err != nil || calls != 2 {
+			tt.Fatalf("expected a retry, got %d calls: %v", calls, err)
+		}
+	})
+
+	mt.Run("other errors are returned", func(tt *mtest.T) {
+		a := &Adapter{PersistUsers: tt.DB}
+		tt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "setName", Value: "rs0"}))
+
+		var calls int
+		err := a.WithTransaction(context.Background(), func(sc mongo.SessionContext) error {
+			calls++
+			return mongo.ErrNoDocuments
+		})
+		if !errors.Is(err, mongo.ErrNoDocuments) || calls != 1 {
+			tt.Fatalf("expected ErrNoDocuments after one call, got %d calls: %v", calls, err)
+		}
+	})
+}
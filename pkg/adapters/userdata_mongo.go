@@ -6,11 +6,19 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/cenkalti/backoff/v4"
+	"github.com/rs/zerolog/log"
+
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
-	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/shared/metrics"
+	"github.com/kubuskotak/ymir-test/pkg/shared/mongotrace"
 )
 
 var UserDataMongoOpen = mongo.Connect // UserDataMongoOpen will invoke to test case.
@@ -18,7 +26,19 @@ var UserDataMongoOpen = mongo.Connect // UserDataMongoOpen will invoke to test c
 // UserDataMongo is data of instances.
 type UserDataMongo struct {
 	NetworkDB
-	Client *mongo.Client
+	Options MongoOptions
+	Client  *mongo.Client
+
+	Retry           backoff.BackOff // retries of the startup ping, nil pings once
+	Degraded        bool            // keep starting when every startup ping failed
+	MonitorInterval time.Duration   // ping interval of the reconnect monitor, zero disables it
+
+	name      string // registry name, empty for UserDataMongo
+	connected atomic.Bool
+	done      chan struct{}
+	closeDone sync.Once
+	mu        sync.Mutex
+	pending   []func(ctx context.Context) error
 }
 
 // Open is open the connection of UserDataMongo.
@@ -31,14 +51,19 @@ func (usda *UserDataMongo) Open() (*mongo.Client, error) {
 
 // Connect is connected the connection of UserDataMongo.
 func (usda *UserDataMongo) Connect() (err error) {
-	var clientOptions = options.Client().ApplyURI(usda.dsn())
+	var clientOptions = options.Client().ApplyURI(usda.dsn()).
+		SetMonitor(commandMonitors(metrics.CommandMonitor(), mongotrace.Monitor())).
+		SetPoolMonitor(metrics.PoolMonitor())
 
-	if infrastructure.Envs.UserDataMongo.Auth {
+	if usda.Options.Auth {
 		clientOptions.SetAuth(options.Credential{
 			Username: usda.User,
 			Password: usda.Password,
 		})
 	}
+	if err = usda.Options.apply(clientOptions); err != nil {
+		return fmt.Errorf("invalid MongoDB options: %w", err)
+	}
 
 	usda.Client, err = UserDataMongoOpen(context.Background(), clientOptions)
 	if err != nil {
@@ -50,31 +75,84 @@ func (usda *UserDataMongo) Connect() (err error) {
 
 // Disconnect is disconnect the connection of UserDataMongo.
 func (usda *UserDataMongo) Disconnect() error {
+	// a second Disconnect must not close the monitor again.
+	usda.closeDone.Do(func() {
+		if usda.done != nil {
+			close(usda.done)
+		}
+	})
 	return usda.Client.Disconnect(context.Background())
 }
 
 // dsn returns the connection string for the UserDataMongo caches.
 func (usda *UserDataMongo) dsn() string {
+	if usda.Options.URI != "" {
+		return usda.Options.URI
+	}
 	if strings.Contains(usda.Protocol, "+srv") {
 		return fmt.Sprintf("%s://%s", usda.Protocol, usda.Host)
 	}
 	return fmt.Sprintf("mongodb://%s:%d", usda.Host, usda.Port)
 }
 
+// commandMonitors fans the command events out, the driver takes a single monitor.
+func commandMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
+
 // WithUserDataMongo option function to assign on adapters.
 func WithUserDataMongo(driver Driver[*mongo.Client]) Option {
 	return func(a *Adapter) {
-		if err := driver.Connect(); err != nil {
-			panic(err)
-		}
-		open, err := driver.Open()
-		if err != nil {
-			panic(err)
-		}
-		if err := open.Ping(context.Background(), nil); err != nil {
-			panic(err)
-		}
-		a.UserDataMongo = driver.(*UserDataMongo)
-		a.PersistUsers = open.Database(driver.(*UserDataMongo).Database)
+		usda := driver.(*UserDataMongo)
+		connect(usda)
+		a.UserDataMongo = usda
+		a.PersistUsers = usda.Client.Database(usda.Database)
+	}
+}
+
+// connect opens the connection, retrying and starting degraded as configured.
+func connect(usda *UserDataMongo) {
+	if err := usda.Connect(); err != nil {
+		panic(err)
+	}
+	if _, err := usda.Open(); err != nil {
+		panic(err)
+	}
+	err := usda.ping()
+	if err != nil && !usda.Degraded {
+		panic(err)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("adapter", usda.Name()).Msg("Mongo is down, starting degraded")
+	}
+	usda.connected.Store(err == nil)
+	if usda.Degraded && usda.MonitorInterval <= 0 {
+		usda.MonitorInterval = defaultMonitorInterval
+	}
+	if usda.MonitorInterval > 0 {
+		usda.done = make(chan struct{})
+		go usda.monitor(usda.MonitorInterval)
 	}
 }
@@ -0,0 +1,109 @@
+// Package adapters are the glue between components and external sources.
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// defaultMonitorInterval is used when the service starts degraded without a monitor interval,
+// something has to notice Mongo coming up.
+const defaultMonitorInterval = 10 * time.Second
+
+// ExponentialRetry returns a backoff of retries attempts, doubling from initial up to max.
+// Zero durations keep the backoff defaults.
+func ExponentialRetry(retries uint64, initial, max time.Duration) backoff.BackOff {
+	if retries == 0 {
+		return nil
+	}
+	exp := backoff.NewExponentialBackOff()
+	if initial > 0 {
+		exp.InitialInterval = initial
+	}
+	if max > 0 {
+		exp.MaxInterval = max
+	}
+	exp.MaxElapsedTime = 0 // bounded by the retries
+	return backoff.WithMaxRetries(exp, retries)
+}
+
+// ping checks the server like the initial connection always did, retrying with
+// the Retry backoff when one is set.
+func (usda *UserDataMongo) ping() error {
+	var b backoff.BackOff = &backoff.StopBackOff{}
+	if usda.Retry != nil {
+		b = usda.Retry
+	}
+	return backoff.RetryNotify(func() error {
+		return usda.Client.Ping(context.Background(), nil)
+	}, b, func(err error, next time.Duration) {
+		log.Warn().Err(err).Str("adapter", usda.Name()).Dur("retry_in", next).Msg("Mongo is unreachable")
+	})
+}
+
+// Connected reports whether the last ping of UserDataMongo succeeded.
+func (usda *UserDataMongo) Connected() bool {
+	return usda.connected.Load()
+}
+
+// WhenConnected runs fn now when UserDataMongo is connected, otherwise once the
+// monitor sees it come up. Setup such as index creation is deferred this way
+// while the service starts degraded.
+func (usda *UserDataMongo) WhenConnected(fn func(ctx context.Context) error) error {
+	if usda == nil || usda.Connected() {
+		return fn(context.Background())
+	}
+	usda.mu.Lock()
+	defer usda.mu.Unlock()
+	usda.pending = append(usda.pending, fn)
+	return nil
+}
+
+// monitor pings UserDataMongo every interval and logs the state transitions.
+func (usda *UserDataMongo) monitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-usda.done:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := usda.Client.Ping(ctx, readpref.Primary())
+		cancel()
+		usda.setConnected(err)
+	}
+}
+
+func (usda *UserDataMongo) setConnected(err error) {
+	up := err == nil
+	if usda.connected.Swap(up) != up {
+		if up {
+			log.Info().Str("adapter", usda.Name()).Msg("Mongo is connected")
+		} else {
+			log.Warn().Err(err).Str("adapter", usda.Name()).Msg("Mongo is disconnected")
+		}
+	}
+	if up {
+		usda.runPending()
+	}
+}
+
+// runPending runs the deferred WhenConnected functions, failed ones are retried on the next ping.
+func (usda *UserDataMongo) runPending() {
+	usda.mu.Lock()
+	defer usda.mu.Unlock()
+	var failed []func(ctx context.Context) error
+	for _, fn := range usda.pending {
+		if err := fn(context.Background()); err != nil {
+			log.Error().Err(err).Str("adapter", usda.Name()).Msg("Mongo deferred setup is failed")
+			failed = append(failed, fn)
+		}
+	}
+	usda.pending = failed
+}
@@ -0,0 +1,77 @@
+// Package adapters are the glue between components and external sources.
+package adapters
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+)
+
+func TestWithUserDataMongoDegraded(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("starts degraded", func(tt *mtest.T) {
+		UserDataMongoOpen = func(ctx context.Context, opts ...*options.ClientOptions) (*mongo.Client, error) {
+			return tt.Client, nil
+		}
+		infrastructure.Configuration(
+			infrastructure.WithPath("../.."),
+			infrastructure.WithFilename("config.yaml"),
+		).Initialize()
+		tt.AddMockResponses(
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 6, Message: "host unreachable"}),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 6, Message: "host unreachable"}),
+		)
+
+		adapter := &Adapter{}
+		adapter.Sync(WithUserDataMongo(&UserDataMongo{
+			Retry:    ExponentialRetry(1, 1, 1),
+			Degraded: true,
+		}))
+		usda := adapter.UserDataMongo
+		if usda == nil || usda.Connected() {
+			tt.Fatal("expected a disconnected adapter")
+		}
+		close(usda.done) // the monitor is driven by hand below
+
+		var runs int
+		err := usda.WhenConnected(func(context.Context) error {
+			runs++
+			if runs == 1 {
+				return errors.New("index build interrupted")
+			}
+			return nil
+		})
+		if err != nil || runs != 0 {
+			tt.Fatalf("setup must wait for mongo, got %d runs %v", runs, err)
+		}
+
+		usda.setConnected(nil)
+		usda.setConnected(nil)
+		if !usda.Connected() || runs != 2 || len(usda.pending) != 0 {
+			tt.Fatalf("expected the failed setup to be retried once, got %d runs", runs)
+		}
+	})
+}
+
+func TestUserDataMongoDisconnectTwice(t *testing.T) {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	usda := &UserDataMongo{Client: client, done: make(chan struct{})}
+	if err = usda.Disconnect(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the client reports it is already closed, the monitor must not be closed again.
+	if err = usda.Disconnect(); !errors.Is(err, mongo.ErrClientDisconnected) {
+		t.Fatalf("expected ErrClientDisconnected, got %v", err)
+	}
+}
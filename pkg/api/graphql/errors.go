@@ -0,0 +1,107 @@
+// Package graphql is port handler.
+package graphql
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+// errInvalidInput is returned for arguments the schema cannot reject itself.
+var errInvalidInput = errors.New("invalid input")
+
+// limitError rejects a query over the limits before it is executed.
+type limitError struct {
+	code    string
+	message string
+}
+
+func (e limitError) Error() string {
+	return e.message
+}
+
+// formatError formats an error raised outside of the executor.
+func formatError(err error) gqlerrors.FormattedError {
+	formatted := gqlerrors.FormatError(err)
+	formatted.Extensions = extensions(err)
+	return formatted
+}
+
+// extensions holds the code of the error of a resolver, like the grpc status codes.
+// Errors of the query itself have no code.
+func extensions(err error) map[string]any {
+	cause := unwrap(err)
+	var limit limitError
+	var code string
+	switch {
+	case errors.As(cause, &limit):
+		code = limit.code
+	case isQueryError(cause):
+		return nil
+	case errors.Is(cause, mongo.ErrNoDocuments):
+		code = "NOT_FOUND"
+	case errors.Is(cause, errInvalidInput),
+		invalidObjectID(cause),
+		errors.Is(cause, tenant.ErrRequired),
+		errors.Is(cause, tenant.ErrInvalid),
+		errors.Is(cause, users.ErrInvalidAttribute),
+		errors.Is(cause, users.ErrUnknownField),
+		errors.As(cause, new(users.AttributeErrors)):
+		code = "BAD_USER_INPUT"
+	case errors.Is(cause, users.ErrEmailExists),
+		errors.Is(cause, users.ErrAttributeExists),
+		errors.Is(cause, users.ErrAttributeConflict):
+		code = "CONFLICT"
+	case errors.Is(cause, users.ErrUserErased):
+		code = "FAILED_PRECONDITION"
+	case errors.Is(cause, context.Canceled),
+		errors.Is(cause, context.DeadlineExceeded):
+		code = "TIMEOUT"
+	default:
+		code = "INTERNAL_SERVER_ERROR"
+	}
+	return map[string]any{"code": code}
+}
+
+// unwrap digs the error of a resolver out of the errors of the executor.
+func unwrap(err error) error {
+	for {
+		switch e := err.(type) {
+		case gqlerrors.FormattedError:
+			if e.OriginalError() == nil {
+				return err
+			}
+			err = e.OriginalError()
+		case *gqlerrors.Error:
+			if e.OriginalError == nil {
+				return err
+			}
+			err = e.OriginalError
+		default:
+			return err
+		}
+	}
+}
+
+func isQueryError(err error) bool {
+	switch err.(type) {
+	case gqlerrors.FormattedError, *gqlerrors.Error:
+		return true
+	}
+	return false
+}
+
+// invalidObjectID reports whether err comes from parsing an id that is not an ObjectID,
+// a wrong length fails with ErrInvalidHex and a non-hex id of 24 characters with a hex error.
+func invalidObjectID(err error) bool {
+	return errors.Is(err, primitive.ErrInvalidHex) ||
+		errors.Is(err, hex.ErrLength) ||
+		errors.As(err, new(hex.InvalidByteError))
+}
@@ -0,0 +1,137 @@
+// Package graphql is port handler.
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	pkgRest "github.com/kubuskotak/asgard/rest"
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+const (
+	// maxBody bounds the size of a request, the query is parsed as a whole.
+	maxBody = 1 << 20
+	// defaultMaxDepth is the deepest nesting of fields when not configured.
+	defaultMaxDepth = 10
+	// defaultMaxComplexity is the most fields a query may resolve when not configured.
+	defaultMaxComplexity = 1000
+)
+
+// GraphQLOption is a struct holding the handler options.
+type GraphQLOption func(h *GraphQL)
+
+// GraphQL handler instance data, it serves the users usecase as a GraphQL schema.
+type GraphQL struct {
+	UsersUsecase  users.T
+	MaxDepth      int
+	MaxComplexity int
+
+	schema gql.Schema
+}
+
+// NewGraphQL creates a new GraphQL handler instance.
+func NewGraphQL(opts ...GraphQLOption) (*GraphQL, error) {
+	handler := &GraphQL{MaxDepth: defaultMaxDepth, MaxComplexity: defaultMaxComplexity}
+	for _, opt := range opts {
+		opt(handler)
+	}
+	var err error
+	if handler.schema, err = handler.newSchema(); err != nil {
+		return nil, err
+	}
+	return handler, nil
+}
+
+// Register is endpoint group for handler.
+func (h *GraphQL) Register(router chi.Router) {
+	router.Post("/graphql", h.Serve)
+}
+
+// Request is the body of a GraphQL request.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Serve [POST /graphql] executes a query or a mutation. Like every GraphQL server the
+// failures of a well formed request are answered with 200 and the errors of the result.
+func (h *GraphQL) Serve(w http.ResponseWriter, r *http.Request) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "GraphQL")
+	defer span.End()
+
+	var request Request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(&request); err != nil {
+		l.Info().Msg(err.Error())
+		write(w, http.StatusBadRequest, &gql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	document, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(request.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		write(w, http.StatusOK, &gql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+	if validation := gql.ValidateDocument(&h.schema, document, nil); !validation.IsValid {
+		write(w, http.StatusOK, &gql.Result{Errors: validation.Errors})
+		return
+	}
+	// measured on a valid document, its fragments are known and have no cycle.
+	if err = h.limit(document, request.OperationName, request.Variables); err != nil {
+		l.Info().Msg(err.Error())
+		write(w, http.StatusOK, &gql.Result{Errors: []gqlerrors.FormattedError{formatError(err)}})
+		return
+	}
+
+	result := gql.Execute(gql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       withLoader(ctx, h.UsersUsecase),
+	})
+	for index := range result.Errors {
+		if result.Errors[index].Extensions == nil {
+			result.Errors[index].Extensions = extensions(result.Errors[index])
+		}
+	}
+
+	l.Info().Msg("GraphQL")
+	write(w, http.StatusOK, result)
+}
+
+func write(w http.ResponseWriter, code int, result *gql.Result) {
+	w.Header().Set(pkgRest.HeaderContentType.String(), pkgRest.MIMEApplicationJSON.String())
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// WithUsersUsecase allows setting the UsersUsecase during initialisation.
+func WithUsersUsecase(uc users.T) GraphQLOption {
+	return func(h *GraphQL) {
+		h.UsersUsecase = uc
+	}
+}
+
+// WithLimits option function to assign the deepest nesting and the most fields of a
+// query, zero keeps the default.
+func WithLimits(depth, complexity int) GraphQLOption {
+	return func(h *GraphQL) {
+		if depth > 0 {
+			h.MaxDepth = depth
+		}
+		if complexity > 0 {
+			h.MaxComplexity = complexity
+		}
+	}
+}
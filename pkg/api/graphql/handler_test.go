@@ -0,0 +1,136 @@
+// Package graphql is port handler.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+// fakeUsers serves the users of a map and counts the batches read.
+type fakeUsers struct {
+	users.T
+	users   map[string]entity.User
+	batches [][]string
+}
+
+func (f *fakeUsers) GetByIDs(_ context.Context, ids []string) ([]entity.User, error) {
+	f.batches = append(f.batches, ids)
+	var found []entity.User
+	for _, id := range ids {
+		if user, ok := f.users[id]; ok {
+			found = append(found, user)
+		}
+	}
+	return found, nil
+}
+
+func (f *fakeUsers) GetAll(_ context.Context, request entity.RequestGetUsers) (entity.ResponseGetUsers, error) {
+	return entity.ResponseGetUsers{Users: []entity.User{}, Pagination: request.Pagination}, nil
+}
+
+func (f *fakeUsers) Create(context.Context, entity.User) (entity.User, error) {
+	return entity.User{}, users.ErrEmailExists
+}
+
+type response struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func serve(t *testing.T, h *GraphQL, query string) response {
+	t.Helper()
+	router := chi.NewRouter()
+	h.Register(router)
+	body, _ := json.Marshal(Request{Query: query})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var res response
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatalf("unexpected body: %v", err)
+	}
+	return res
+}
+
+func TestBatchedUsers(t *testing.T) {
+	usc := &fakeUsers{users: map[string]entity.User{
+		"64a7f1f2c2a4b1e0d4b3c2a1": {ID: "64a7f1f2c2a4b1e0d4b3c2a1", Name: "john"},
+		"64a7f1f2c2a4b1e0d4b3c2a2": {ID: "64a7f1f2c2a4b1e0d4b3c2a2", Name: "jane"},
+	}}
+	h, err := NewGraphQL(WithUsersUsecase(usc))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	res := serve(t, h, `{
+		a: user(id: "64a7f1f2c2a4b1e0d4b3c2a1") { name }
+		b: user(id: "64a7f1f2c2a4b1e0d4b3c2a2") { name }
+		c: user(id: "64a7f1f2c2a4b1e0d4b3c2a3") { name }
+	}`)
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors %+v", res.Errors)
+	}
+	if res.Data["a"].(map[string]any)["name"] != "john" || res.Data["b"].(map[string]any)["name"] != "jane" || res.Data["c"] != nil {
+		t.Fatalf("unexpected data %v", res.Data)
+	}
+	if len(usc.batches) != 1 || len(usc.batches[0]) != 3 {
+		t.Fatalf("expected one batch of 3 ids, got %v", usc.batches)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	for name, tc := range map[string]struct {
+		depth, complexity int
+		query, code       string
+	}{
+		"deep":     {depth: 2, query: `{ users { items { name } } }`, code: "QUERY_TOO_DEEP"},
+		"complex":  {complexity: 100, query: `{ ...page } fragment page on Query { users(limit: 50) { items { id name } } }`, code: "QUERY_TOO_COMPLEX"},
+		"variable": {complexity: 100, query: `query($limit: Int = 99) { users(limit: $limit) { page items { id } } }`, code: "QUERY_TOO_COMPLEX"},
+		"page":     {complexity: 100, query: `{ users(limit: 100) { page limit } }`},
+	} {
+		h, err := NewGraphQL(WithUsersUsecase(&fakeUsers{}), WithLimits(tc.depth, tc.complexity))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res := serve(t, h, tc.query)
+		var code any
+		if len(res.Errors) > 0 {
+			code = res.Errors[0].Extensions["code"]
+		}
+		if tc.code == "" && len(res.Errors) > 0 || tc.code != "" && code != tc.code {
+			t.Fatalf("%s: expected %q, got %+v", name, tc.code, res.Errors)
+		}
+	}
+}
+
+func TestResolverErrors(t *testing.T) {
+	h, err := NewGraphQL(WithUsersUsecase(&fakeUsers{}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for query, code := range map[string]string{
+		`mutation { createUser(input: {name: "john", email: "john@example.com", age: 20}) { id } }`: "CONFLICT",
+		`mutation { createUser(input: {name: "jo", email: "john", age: 20}) { id } }`:               "BAD_USER_INPUT",
+		`{ user(id: "nope") { id } }`:                     "BAD_USER_INPUT",
+		`{ user(id: "zzzzzzzzzzzzzzzzzzzzzzzz") { id } }`: "BAD_USER_INPUT",
+	} {
+		res := serve(t, h, query)
+		if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != code {
+			t.Fatalf("%s: expected %s, got %+v", query, code, res.Errors)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package graphql is port handler.
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// pages are the fields returning a page by the argument holding its size, every
+// item of the page costs the selection of its items.
+var pages = map[string]string{
+	"users": "limit",
+}
+
+// pageItems is the field of a page listing its items.
+const pageItems = "items"
+
+// limit rejects the operation of document nesting fields deeper than MaxDepth or
+// resolving more than MaxComplexity fields.
+func (h *GraphQL) limit(document *ast.Document, operationName string, variables map[string]any) error {
+	m := measure{fragments: map[string]*ast.FragmentDefinition{}, variables: map[string]any{}}
+	var operation *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		switch d := definition.(type) {
+		case *ast.FragmentDefinition:
+			m.fragments[d.Name.Value] = d
+		case *ast.OperationDefinition:
+			if operationName == "" || d.Name != nil && d.Name.Value == operationName {
+				operation = d
+			}
+		}
+	}
+	if operation == nil {
+		return nil
+	}
+	for _, definition := range operation.VariableDefinitions {
+		if definition.DefaultValue != nil {
+			m.variables[definition.Variable.Name.Value] = definition.DefaultValue.GetValue()
+		}
+	}
+	for name, value := range variables {
+		m.variables[name] = value
+	}
+
+	complexity, depth := m.selections(operation.SelectionSet, 0, 0)
+	if depth > h.MaxDepth {
+		return limitError{code: "QUERY_TOO_DEEP",
+			message: fmt.Sprintf("query nests %d fields deep, at most %d are allowed", depth, h.MaxDepth)}
+	}
+	if complexity > h.MaxComplexity {
+		return limitError{code: "QUERY_TOO_COMPLEX",
+			message: fmt.Sprintf("query resolves up to %d fields, at most %d are allowed", complexity, h.MaxComplexity)}
+	}
+	return nil
+}
+
+// measure holds what the selections of an operation refer to.
+type measure struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+}
+
+// selections is the number of fields set resolves and how deep they nest below depth,
+// size is the number of items when set is the selection of a page. Introspection only
+// reads the schema and is not counted.
+func (m measure) selections(set *ast.SelectionSet, depth, size int) (complexity, deepest int) {
+	deepest = depth
+	if set == nil {
+		return 0, deepest
+	}
+	for _, selection := range set.Selections {
+		var c, d int
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+			var items int
+			if argument, ok := pages[s.Name.Value]; ok {
+				items = m.pageSize(s, argument)
+			}
+			c, d = m.selections(s.SelectionSet, depth+1, items)
+			if s.Name.Value == pageItems && size > 0 {
+				c *= size
+			}
+			c++
+		case *ast.InlineFragment:
+			c, d = m.selections(s.SelectionSet, depth, size)
+		case *ast.FragmentSpread:
+			if fragment, ok := m.fragments[s.Name.Value]; ok {
+				c, d = m.selections(fragment.SelectionSet, depth, size)
+			}
+		}
+		complexity += c
+		if d > deepest {
+			deepest = d
+		}
+	}
+	return complexity, deepest
+}
+
+// pageSize is the value of the page size argument of field, or its default.
+func (m measure) pageSize(field *ast.Field, argument string) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != argument {
+			continue
+		}
+		var value any = arg.Value.GetValue()
+		if variable, ok := arg.Value.(*ast.Variable); ok {
+			value = m.variables[variable.Name.Value]
+		}
+		switch v := value.(type) {
+		case string: // literals keep their source
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				return n
+			}
+		case float64: // variables decoded from json
+			if v > 0 {
+				return int(v)
+			}
+		case int:
+			if v > 0 {
+				return v
+			}
+		}
+	}
+	return defaultLimit
+}
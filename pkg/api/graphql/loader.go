@@ -0,0 +1,87 @@
+// Package graphql is port handler.
+package graphql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+type loaderKey struct{}
+
+// loader batches the users read by id while a request is executed. The executor
+// resolves every field of a level before the thunks they return, so the ids of
+// a level are read with a single query.
+type loader struct {
+	ctx   context.Context
+	users users.T
+
+	mu      sync.Mutex
+	pending []string
+	loaded  map[string]*entity.User // nil for the ids that were not found
+	err     error
+}
+
+func withLoader(ctx context.Context, usc users.T) context.Context {
+	return context.WithValue(ctx, loaderKey{}, &loader{ctx: ctx, users: usc, loaded: map[string]*entity.User{}})
+}
+
+func loaderFromContext(ctx context.Context) *loader {
+	l, _ := ctx.Value(loaderKey{}).(*loader)
+	return l
+}
+
+// load queues id and returns the thunk resolving its user, nil when it is missing.
+func (l *loader) load(id string) func() (any, error) {
+	l.mu.Lock()
+	if _, ok := l.loaded[id]; !ok {
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (any, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if len(l.pending) > 0 {
+			l.fetch()
+		}
+		if l.err != nil {
+			return nil, l.err
+		}
+		if user := l.loaded[id]; user != nil {
+			return *user, nil
+		}
+		return nil, nil
+	}
+}
+
+// fetch reads the pending ids in one batch.
+func (l *loader) fetch() {
+	ids := unique(l.pending)
+	l.pending = nil
+	found, err := l.users.GetByIDs(l.ctx, ids)
+	if err != nil {
+		l.err = err
+		return
+	}
+	for _, id := range ids {
+		l.loaded[id] = nil
+	}
+	for index := range found {
+		l.loaded[found[index].ID] = &found[index]
+	}
+}
+
+func unique(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	kept := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
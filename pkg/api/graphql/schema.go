@@ -0,0 +1,247 @@
+// Package graphql is port handler.
+package graphql
+
+import (
+	"errors"
+	"fmt"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/kubuskotak/asgard/security"
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+)
+
+const (
+	defaultLimit = 10
+	maxLimit     = 100
+)
+
+// newSchema builds the schema of the users:
+//
+//	type Query {
+//	  user(id: ID!): User
+//	  users(page: Int = 1, limit: Int = 10, filters: [AttributeFilter!], sort: [String!]): UserPage!
+//	}
+//	type Mutation {
+//	  createUser(input: UserInput!): User!
+//	  updateUser(id: ID!, input: UserInput!): User!
+//	  deleteUser(id: ID!): ID!
+//	}
+func (h *GraphQL) newSchema() (gql.Schema, error) {
+	user := gql.NewObject(gql.ObjectConfig{
+		Name: "User",
+		Fields: gql.Fields{
+			"id":    &gql.Field{Type: gql.NewNonNull(gql.ID)},
+			"name":  &gql.Field{Type: gql.NewNonNull(gql.String)},
+			"email": &gql.Field{Type: gql.NewNonNull(gql.String)},
+			"age":   &gql.Field{Type: gql.NewNonNull(gql.Int)},
+			"createdAt": &gql.Field{
+				Type: gql.DateTime,
+				Resolve: func(p gql.ResolveParams) (any, error) {
+					if u, ok := p.Source.(entity.User); ok && u.CreatedAt != nil {
+						return *u.CreatedAt, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	})
+	page := gql.NewObject(gql.ObjectConfig{
+		Name: "UserPage",
+		Fields: gql.Fields{
+			"items": &gql.Field{
+				Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(user))),
+				Resolve: func(p gql.ResolveParams) (any, error) {
+					return p.Source.(entity.ResponseGetUsers).Users, nil
+				},
+			},
+			"page": &gql.Field{
+				Type: gql.NewNonNull(gql.Int),
+				Resolve: func(p gql.ResolveParams) (any, error) {
+					return p.Source.(entity.ResponseGetUsers).Page, nil
+				},
+			},
+			"limit": &gql.Field{
+				Type: gql.NewNonNull(gql.Int),
+				Resolve: func(p gql.ResolveParams) (any, error) {
+					return p.Source.(entity.ResponseGetUsers).Limit, nil
+				},
+			},
+		},
+	})
+	filter := gql.NewInputObject(gql.InputObjectConfig{
+		Name:        "AttributeFilter",
+		Description: "Matches the users whose custom attribute compares to value.",
+		Fields: gql.InputObjectConfigFieldMap{
+			"name":     &gql.InputObjectFieldConfig{Type: gql.NewNonNull(gql.String)},
+			"operator": &gql.InputObjectFieldConfig{Type: gql.String, DefaultValue: entity.FilterEqual},
+			"value":    &gql.InputObjectFieldConfig{Type: gql.NewNonNull(gql.String)},
+		},
+	})
+	input := gql.NewInputObject(gql.InputObjectConfig{
+		Name: "UserInput",
+		Fields: gql.InputObjectConfigFieldMap{
+			"name":  &gql.InputObjectFieldConfig{Type: gql.NewNonNull(gql.String)},
+			"email": &gql.InputObjectFieldConfig{Type: gql.NewNonNull(gql.String)},
+			"age":   &gql.InputObjectFieldConfig{Type: gql.NewNonNull(gql.Int)},
+		},
+	})
+
+	query := gql.NewObject(gql.ObjectConfig{
+		Name: "Query",
+		Fields: gql.Fields{
+			"user": &gql.Field{
+				Type: user,
+				Args: gql.FieldConfigArgument{
+					"id": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.ID)},
+				},
+				Resolve: h.user,
+			},
+			"users": &gql.Field{
+				Type: gql.NewNonNull(page),
+				Args: gql.FieldConfigArgument{
+					"page":    &gql.ArgumentConfig{Type: gql.Int, DefaultValue: 1},
+					"limit":   &gql.ArgumentConfig{Type: gql.Int, DefaultValue: defaultLimit},
+					"filters": &gql.ArgumentConfig{Type: gql.NewList(gql.NewNonNull(filter))},
+					"sort":    &gql.ArgumentConfig{Type: gql.NewList(gql.NewNonNull(gql.String))},
+				},
+				Resolve: h.users,
+			},
+		},
+	})
+	mutation := gql.NewObject(gql.ObjectConfig{
+		Name: "Mutation",
+		Fields: gql.Fields{
+			"createUser": &gql.Field{
+				Type: gql.NewNonNull(user),
+				Args: gql.FieldConfigArgument{
+					"input": &gql.ArgumentConfig{Type: gql.NewNonNull(input)},
+				},
+				Resolve: h.createUser,
+			},
+			"updateUser": &gql.Field{
+				Type: gql.NewNonNull(user),
+				Args: gql.FieldConfigArgument{
+					"id":    &gql.ArgumentConfig{Type: gql.NewNonNull(gql.ID)},
+					"input": &gql.ArgumentConfig{Type: gql.NewNonNull(input)},
+				},
+				Resolve: h.updateUser,
+			},
+			"deleteUser": &gql.Field{
+				Type: gql.NewNonNull(gql.ID),
+				Args: gql.FieldConfigArgument{
+					"id": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.ID)},
+				},
+				Resolve: h.deleteUser,
+			},
+		},
+	})
+	return gql.NewSchema(gql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+// user reads a user through the loader of the request, null when it is missing.
+func (h *GraphQL) user(p gql.ResolveParams) (any, error) {
+	id, _ := p.Args["id"].(string)
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+	return loaderFromContext(p.Context).load(id), nil
+}
+
+func (h *GraphQL) users(p gql.ResolveParams) (any, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(p.Context, "GraphQLUsers")
+	defer span.End()
+
+	request := entity.RequestGetUsers{
+		Pagination: entity.Pagination{Page: p.Args["page"].(int), Limit: p.Args["limit"].(int)},
+	}
+	if request.Page < 1 || request.Limit < 1 || request.Limit > maxLimit {
+		return nil, fmt.Errorf("%w: page must be at least 1 and limit between 1 and %d", errInvalidInput, maxLimit)
+	}
+	filters, _ := p.Args["filters"].([]any)
+	for _, f := range filters {
+		filter := f.(map[string]any)
+		request.Filters = append(request.Filters, entity.AttributeFilter{
+			Name:     filter["name"].(string),
+			Operator: filter["operator"].(string),
+			Value:    filter["value"].(string),
+		})
+	}
+	sort, _ := p.Args["sort"].([]any)
+	for _, s := range sort {
+		request.Sort = append(request.Sort, s.(string))
+	}
+
+	documents, err := h.UsersUsecase.GetAll(ctx, request)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return nil, err
+	}
+	return documents, nil
+}
+
+func (h *GraphQL) createUser(p gql.ResolveParams) (any, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(p.Context, "GraphQLCreateUser")
+	defer span.End()
+
+	payload, err := userInput(p.Args["input"])
+	if err != nil {
+		return nil, err
+	}
+	doc, err := h.UsersUsecase.Create(ctx, payload)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (h *GraphQL) updateUser(p gql.ResolveParams) (any, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(p.Context, "GraphQLUpdateUser")
+	defer span.End()
+
+	payload, err := userInput(p.Args["input"])
+	if err != nil {
+		return nil, err
+	}
+	payload.ID, _ = p.Args["id"].(string)
+	doc, err := h.UsersUsecase.UpdateByID(ctx, payload)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (h *GraphQL) deleteUser(p gql.ResolveParams) (any, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(p.Context, "GraphQLDeleteUser")
+	defer span.End()
+
+	id, _ := p.Args["id"].(string)
+	if err := h.UsersUsecase.DeleteByID(ctx, id); err != nil {
+		l.Info().Msg(err.Error())
+		return nil, err
+	}
+	return id, nil
+}
+
+// userInput is the user of a UserInput, validated with the same tags as the rest binder.
+func userInput(arg any) (entity.User, error) {
+	input, _ := arg.(map[string]any)
+	user := entity.User{}
+	user.Name, _ = input["name"].(string)
+	user.Email, _ = input["email"].(string)
+	user.Age, _ = input["age"].(int)
+
+	violations := security.Validate(user)
+	if len(violations) < 1 {
+		return user, nil
+	}
+	errs := []error{errInvalidInput}
+	for n := range violations {
+		errs = append(errs, errors.New(violations[n].Message))
+	}
+	return entity.User{}, errors.Join(errs...)
+}
@@ -0,0 +1,52 @@
+// Package grpc is port handler.
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	pkgGrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/causal"
+)
+
+// withConsistency reads after the write token of the metadata of ctx and hands the
+// token of a write to header, like the causal.Header of the http handlers.
+func withConsistency(ctx context.Context, header func(metadata.MD) error) (context.Context, error) {
+	key := strings.ToLower(causal.Header)
+	ctx = causal.WithRecorder(ctx, func(token string) {
+		_ = header(metadata.Pairs(key, token))
+	})
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 && values[0] != "" {
+		after, err := causal.Parse(values[0])
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		ctx = causal.WithToken(ctx, after)
+	}
+	return ctx, nil
+}
+
+func consistencyUnaryInterceptor() pkgGrpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *pkgGrpc.UnaryServerInfo, handler pkgGrpc.UnaryHandler) (any, error) {
+		stream := ctx
+		ctx, err := withConsistency(ctx, func(md metadata.MD) error { return pkgGrpc.SetHeader(stream, md) })
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func consistencyStreamInterceptor() pkgGrpc.StreamServerInterceptor {
+	return func(srv any, ss pkgGrpc.ServerStream, _ *pkgGrpc.StreamServerInfo, handler pkgGrpc.StreamHandler) error {
+		ctx, err := withConsistency(ss.Context(), ss.SetHeader)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &tenantStream{ServerStream: ss, ctx: ctx})
+	}
+}
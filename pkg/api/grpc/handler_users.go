@@ -0,0 +1,194 @@
+// Package grpc is port handler.
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kubuskotak/asgard/security"
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	pkgGrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/kubuskotak/ymir-test/pkg/api/grpc/pb"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+// listPageSize is the page size used when List streams every user.
+const listPageSize = 100
+
+// UsersOption is a struct holding the handler options.
+type UsersOption func(h *Users)
+
+// Users handler instance data.
+type Users struct {
+	pb.UnimplementedUserServiceServer
+	UsersUsecase users.T
+}
+
+// NewUsers creates a new Users handler instance.
+func NewUsers(opts ...UsersOption) *Users {
+	handler := &Users{}
+	for _, opt := range opts {
+		opt(handler)
+	}
+	return handler
+}
+
+// Register is service registration for handler.
+func (h *Users) Register(s *pkgGrpc.Server) {
+	pb.RegisterUserServiceServer(s, h)
+}
+
+// Get user.
+func (h *Users) Get(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(ctx, "GetByID")
+	defer span.End()
+
+	doc, err := h.UsersUsecase.GetByID(ctx, req.GetId())
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return nil, statusError(err)
+	}
+
+	l.Info().Msg("GetByID")
+	return toUser(doc), nil
+}
+
+// List users.
+func (h *Users) List(req *pb.ListUsersRequest, stream pb.UserService_ListServer) error {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(stream.Context(), "GetAll")
+	defer span.End()
+
+	page, limit := int(req.GetPage()), int(req.GetLimit())
+	if page < 1 {
+		page = 1
+	}
+	all := limit < 1
+	if all {
+		limit = listPageSize
+	}
+	for {
+		documents, err := h.UsersUsecase.GetAll(ctx, entity.RequestGetUsers{
+			Pagination: entity.Pagination{Page: page, Limit: limit},
+		})
+		if err != nil {
+			l.Info().Msg(err.Error())
+			return statusError(err)
+		}
+		for n := range documents.Users {
+			if err = stream.Send(toUser(documents.Users[n])); err != nil {
+				return err
+			}
+		}
+		if !all || len(documents.Users) < limit {
+			break
+		}
+		page++
+	}
+
+	l.Info().Msg("GetAll")
+	return nil
+}
+
+// Create user.
+func (h *Users) Create(ctx context.Context, req *pb.CreateUserRequest) (*pb.User, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(ctx, "CreateUser")
+	defer span.End()
+
+	payload := entity.User{
+		Name:  req.GetName(),
+		Email: req.GetEmail(),
+		Age:   int(req.GetAge()),
+	}
+	if err := validate(payload); err != nil {
+		l.Info().Msg(err.Error())
+		return nil, err
+	}
+
+	doc, err := h.UsersUsecase.Create(ctx, payload)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return nil, statusError(err)
+	}
+
+	l.Info().Msg("CreateUser")
+	return toUser(doc), nil
+}
+
+// Update user.
+func (h *Users) Update(ctx context.Context, req *pb.UpdateUserRequest) (*pb.User, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(ctx, "UpdateByID")
+	defer span.End()
+
+	payload := entity.User{
+		ID:    req.GetId(),
+		Name:  req.GetName(),
+		Email: req.GetEmail(),
+		Age:   int(req.GetAge()),
+	}
+	if err := validate(payload); err != nil {
+		l.Info().Msg(err.Error())
+		return nil, err
+	}
+
+	doc, err := h.UsersUsecase.UpdateByID(ctx, payload)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return nil, statusError(err)
+	}
+
+	l.Info().Msg("UpdateByID")
+	return toUser(doc), nil
+}
+
+// Delete user.
+func (h *Users) Delete(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(ctx, "DeleteByID")
+	defer span.End()
+
+	if err := h.UsersUsecase.DeleteByID(ctx, req.GetId()); err != nil {
+		l.Info().Msg(err.Error())
+		return nil, statusError(err)
+	}
+
+	l.Info().Msg("DeleteByID")
+	return &pb.DeleteUserResponse{Message: fmt.Sprintf("success delete %v", req.GetId())}, nil
+}
+
+// WithUsersUsecase allows setting the UsersUsecase during initialisation.
+func WithUsersUsecase(uc users.T) UsersOption {
+	return func(h *Users) {
+		h.UsersUsecase = uc
+	}
+}
+
+// validate applies the same tag validations as the rest binder.
+func validate(user entity.User) error {
+	violations := security.Validate(user)
+	if len(violations) < 1 {
+		return nil
+	}
+	var errs []error
+	for n := range violations {
+		errs = append(errs, errors.New(violations[n].Message))
+	}
+	return status.Error(codes.InvalidArgument, errors.Join(errs...).Error())
+}
+
+func toUser(user entity.User) *pb.User {
+	u := &pb.User{
+		Id:    user.ID,
+		Name:  user.Name,
+		Email: user.Email,
+		Age:   int32(user.Age),
+	}
+	if user.CreatedAt != nil {
+		u.CreatedAt = timestamppb.New(*user.CreatedAt)
+	}
+	return u
+}
@@ -0,0 +1,135 @@
+// Package grpc is port handler.
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	pkgGrpc "google.golang.org/grpc"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+// ErrServerNotStarted is define error when server not started.
+var ErrServerNotStarted = errors.New("grpc server not started")
+
+// Registrar is implemented by handlers exposing grpc services.
+type Registrar interface {
+	Register(s *pkgGrpc.Server)
+}
+
+// ServerOption is server type return func.
+type ServerOption func(s *Server)
+
+// Server is the grpc server of the service.
+type Server struct {
+	Port string
+
+	errCh   chan error
+	server  *pkgGrpc.Server
+	started bool
+
+	tenantKey      string // metadata key of the tenant, empty disables tenancy
+	tenantResolver tenant.Resolver
+	causal         bool // read after the write tokens of the calls
+}
+
+// NewServer creates a server with tracing interceptors.
+func NewServer(opts ...ServerOption) *Server {
+	s := &Server{
+		Port:  "5000",
+		errCh: make(chan error, 1),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	unary := []pkgGrpc.UnaryServerInterceptor{otelgrpc.UnaryServerInterceptor()}
+	stream := []pkgGrpc.StreamServerInterceptor{otelgrpc.StreamServerInterceptor()}
+	if s.causal {
+		unary = append(unary, consistencyUnaryInterceptor())
+		stream = append(stream, consistencyStreamInterceptor())
+	}
+	if s.tenantKey != "" {
+		unary = append(unary, tenantUnaryInterceptor(s.tenantKey, s.tenantResolver))
+		stream = append(stream, tenantStreamInterceptor(s.tenantKey, s.tenantResolver))
+	}
+	s.server = pkgGrpc.NewServer(
+		pkgGrpc.ChainUnaryInterceptor(unary...),
+		pkgGrpc.ChainStreamInterceptor(stream...),
+	)
+	return s
+}
+
+// Register will assign grpc handlers.
+func (s *Server) Register(handlers ...Registrar) {
+	for _, h := range handlers {
+		h.Register(s.server)
+	}
+}
+
+// ListenAndServe will run the server.
+func (s *Server) ListenAndServe() error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", s.Port))
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := s.server.Serve(lis); err != nil {
+			s.errCh <- err
+		}
+	}()
+	s.started = true
+	return nil
+}
+
+// Error is return channel for capture error.
+func (s *Server) Error() chan error {
+	return s.errCh
+}
+
+// Quite will shutdown the server, pending rpcs are cancelled when ctx is done.
+func (s *Server) Quite(ctx context.Context) error {
+	if !s.started {
+		return ErrServerNotStarted
+	}
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.server.Stop()
+	}
+	log.Info().Msgf("Stop grpc server at :%s", s.Port)
+	return nil
+}
+
+// WithPort will assign to port field server.
+func WithPort(port string) ServerOption {
+	return func(s *Server) {
+		s.Port = port
+	}
+}
+
+// WithTenant will read the tenant of every call from the metadata key and the
+// bearer token, trusted as the resolver decides.
+func WithTenant(key string, resolver tenant.Resolver) ServerOption {
+	return func(s *Server) {
+		s.tenantKey = key
+		s.tenantResolver = resolver
+	}
+}
+
+// WithCausalReads will send back the write token of a write in the causal.Header
+// metadata, and read after the token a call carries in it.
+func WithCausalReads() ServerOption {
+	return func(s *Server) {
+		s.causal = true
+	}
+}
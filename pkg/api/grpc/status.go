@@ -0,0 +1,52 @@
+// Package grpc is port handler.
+package grpc
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+// statusError maps domain errors into grpc status codes.
+func statusError(err error) error {
+	var code codes.Code
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		code = codes.NotFound
+	case invalidObjectID(err),
+		errors.Is(err, tenant.ErrRequired),
+		errors.Is(err, tenant.ErrInvalid),
+		errors.Is(err, users.ErrInvalidAttribute),
+		errors.As(err, new(users.AttributeErrors)):
+		code = codes.InvalidArgument
+	case errors.Is(err, users.ErrEmailExists),
+		errors.Is(err, users.ErrAttributeExists),
+		errors.Is(err, users.ErrAttributeConflict):
+		code = codes.AlreadyExists
+	case errors.Is(err, users.ErrUserErased):
+		code = codes.FailedPrecondition
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	default:
+		code = codes.Internal
+	}
+	return status.Error(code, err.Error())
+}
+
+// invalidObjectID reports whether err comes from parsing an id that is not an ObjectID,
+// a wrong length fails with ErrInvalidHex and a non-hex id of 24 characters with a hex error.
+func invalidObjectID(err error) bool {
+	return errors.Is(err, primitive.ErrInvalidHex) ||
+		errors.Is(err, hex.ErrLength) ||
+		errors.As(err, new(hex.InvalidByteError))
+}
@@ -0,0 +1,37 @@
+// Package grpc is port handler.
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+func TestStatusError(t *testing.T) {
+	_, short := primitive.ObjectIDFromHex("64b0")
+	_, nonHex := primitive.ObjectIDFromHex("zzzzzzzzzzzzzzzzzzzzzzzz")
+	for err, want := range map[error]codes.Code{
+		fmt.Errorf("no document with id 1 was found: %w", mongo.ErrNoDocuments): codes.NotFound,
+		users.ErrEmailExists:     codes.AlreadyExists,
+		users.ErrAttributeExists: codes.AlreadyExists,
+		fmt.Errorf("create: %w", users.AttributeErrors{{Field: "attributes.tier", Rule: "required"}}): codes.InvalidArgument,
+		users.ErrUserErased:           codes.FailedPrecondition,
+		tenant.ErrRequired:            codes.InvalidArgument,
+		short:                         codes.InvalidArgument,
+		fmt.Errorf("get: %w", nonHex): codes.InvalidArgument,
+		context.DeadlineExceeded:      codes.DeadlineExceeded,
+		fmt.Errorf("boom"):            codes.Internal,
+	} {
+		if got := status.Code(statusError(err)); got != want {
+			t.Errorf("%v: expected %v, got %v", err, want, got)
+		}
+	}
+}
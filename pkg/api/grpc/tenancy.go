@@ -0,0 +1,76 @@
+// Package grpc is port handler.
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	pkgGrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+// tenantStream overrides the context of a stream, with the tenant or the write token.
+type tenantStream struct {
+	pkgGrpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context carrying the tenant.
+func (s *tenantStream) Context() context.Context {
+	return s.ctx
+}
+
+// withTenant puts the tenant the resolver finds in the metadata of ctx in it, by the
+// bearer token of the authorization key and the tenant of the metadata key. Calls
+// without a tenant are left to the usecases, which reject them.
+func withTenant(ctx context.Context, key string, resolver tenant.Resolver) (context.Context, error) {
+	first := func(key string) string {
+		if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	token, _ := strings.CutPrefix(first("authorization"), "Bearer ")
+	var addr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+	id, err := resolver.Resolve(token, addr, first(strings.ToLower(key)))
+	switch {
+	case errors.Is(err, tenant.ErrRequired):
+		return ctx, nil
+	case errors.Is(err, tenant.ErrInvalidToken), errors.Is(err, tenant.ErrTokenRequired):
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, tenant.ErrConflict), errors.Is(err, tenant.ErrUntrusted):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return tenant.WithID(ctx, id), nil
+}
+
+func tenantUnaryInterceptor(key string, resolver tenant.Resolver) pkgGrpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *pkgGrpc.UnaryServerInfo, handler pkgGrpc.UnaryHandler) (any, error) {
+		ctx, err := withTenant(ctx, key, resolver)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func tenantStreamInterceptor(key string, resolver tenant.Resolver) pkgGrpc.StreamServerInterceptor {
+	return func(srv any, ss pkgGrpc.ServerStream, _ *pkgGrpc.StreamServerInfo, handler pkgGrpc.StreamHandler) error {
+		ctx, err := withTenant(ss.Context(), key, resolver)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &tenantStream{ServerStream: ss, ctx: ctx})
+	}
+}
@@ -0,0 +1,35 @@
+// Package grpc is port handler.
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+func TestWithTenant(t *testing.T) {
+	resolver, err := tenant.NewResolver("", "tenant_id", "", []string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := func(addr string) (context.Context, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", "acme"))
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 5000}})
+		return withTenant(ctx, "X-Tenant-ID", resolver)
+	}
+
+	if _, err = call("203.0.113.7"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected the metadata of a client to be rejected, got %v", err)
+	}
+	ctx, err := call("10.0.0.2")
+	if err != nil || tenant.FromContext(ctx) != "acme" {
+		t.Fatalf("expected acme from the gateway, got %q %v", tenant.FromContext(ctx), err)
+	}
+}
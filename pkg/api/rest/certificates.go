@@ -0,0 +1,134 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// reloadDebounce groups the burst of file events of a certificate rotation.
+const reloadDebounce = 200 * time.Millisecond
+
+// certificates holds the tls material and reloads it when its files change.
+type certificates struct {
+	certFile, keyFile, caFile string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+	pool *x509.CertPool
+
+	watcher *fsnotify.Watcher
+}
+
+// loadCertificates reads the key pair and the optional client CA bundle.
+func loadCertificates(certFile, keyFile, caFile string) (*certificates, error) {
+	c := &certificates{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certificates) reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("load tls key pair: %w", err)
+	}
+	var pool *x509.CertPool
+	if c.caFile != "" {
+		pem, err := os.ReadFile(c.caFile)
+		if err != nil {
+			return fmt.Errorf("read client ca bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("client ca bundle has no certificate")
+		}
+	}
+	c.mu.Lock()
+	c.cert, c.pool = &cert, pool
+	c.mu.Unlock()
+	return nil
+}
+
+// watch reloads the certificates when a file changes. The parent directories are
+// watched so that atomic renames, as done for kubernetes secrets, are seen too.
+func (c *certificates) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	dirs := map[string]bool{}
+	for _, f := range []string{c.certFile, c.keyFile, c.caFile} {
+		if f == "" || dirs[filepath.Dir(f)] {
+			continue
+		}
+		dirs[filepath.Dir(f)] = true
+		if err = watcher.Add(filepath.Dir(f)); err != nil {
+			_ = watcher.Close()
+			return err
+		}
+	}
+	c.watcher = watcher
+
+	go func() {
+		var debounce <-chan time.Time
+		for {
+			select {
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				debounce = time.After(reloadDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Error().Err(err).Msg("tls certificate watcher is failed")
+			case <-debounce:
+				if err := c.reload(); err != nil {
+					// keep serving the previous certificate until the files are consistent.
+					log.Error().Err(err).Msg("tls certificate reload is failed")
+					continue
+				}
+				log.Info().Str("cert", c.certFile).Msg("tls certificate reloaded")
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops watching the files.
+func (c *certificates) Close() error {
+	if c.watcher == nil {
+		return nil
+	}
+	return c.watcher.Close()
+}
+
+// tlsConfig resolves the current certificates on every handshake.
+func (c *certificates) tlsConfig(clientAuth tls.ClientAuthType) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				NextProtos:   []string{"h2", "http/1.1"},
+				Certificates: []tls.Certificate{*c.cert},
+				ClientCAs:    c.pool,
+				ClientAuth:   clientAuth,
+			}, nil
+		},
+	}
+}
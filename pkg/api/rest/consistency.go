@@ -0,0 +1,28 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"net/http"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/causal"
+)
+
+// consistency is middleware handler sending back the write token of a write in the
+// causal.Header, and reading after the token a request carries in it. With it reads
+// observe the writes of the client on any replica of the service.
+func consistency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := causal.WithRecorder(req.Context(), func(token string) {
+			w.Header().Set(causal.Header, token)
+		})
+		if token := req.Header.Get(causal.Header); token != "" {
+			after, err := causal.Parse(token)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			ctx = causal.WithToken(ctx, after)
+		}
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
@@ -0,0 +1,36 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/causal"
+)
+
+func TestConsistency(t *testing.T) {
+	var after primitive.Timestamp
+	handler := consistency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		after, _ = causal.FromContext(r.Context())
+		causal.Record(r.Context(), primitive.Timestamp{T: 20, I: 1})
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/user/64a7f1f2c2a4b1e0d4b3c2a1", nil)
+	req.Header.Set(causal.Header, "10.2")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if after != (primitive.Timestamp{T: 10, I: 2}) || w.Header().Get(causal.Header) != "20.1" {
+		t.Fatalf("expected to read after 10.2 and answer 20.1, got %v %q", after, w.Header().Get(causal.Header))
+	}
+
+	req.Header.Set(causal.Header, "yesterday")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid token to be rejected, got %d", w.Code)
+	}
+}
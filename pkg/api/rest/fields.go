@@ -0,0 +1,84 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+// splitFields splits a comma separated fields param, empty names are dropped.
+func splitFields(fields string) []string {
+	var names []string
+	for _, name := range strings.Split(fields, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// validateFields checks the fields param against the fields of a user.
+func validateFields(fields string) []FieldError {
+	for _, name := range splitFields(fields) {
+		if _, ok := users.Fields[name]; ok {
+			continue
+		}
+		known := make([]string, 0, len(users.Fields))
+		for field := range users.Fields {
+			known = append(known, field)
+		}
+		sort.Strings(known)
+		return []FieldError{{Field: "fields", Rule: "fields", Param: strings.Join(known, " ")}}
+	}
+	return nil
+}
+
+// parseFilters parses the filters param, name:value compares for equality.
+// The attributes, operators and values are checked against the definitions by the usecase.
+func parseFilters(filters []string) ([]entity.AttributeFilter, bool) {
+	parsed := make([]entity.AttributeFilter, 0, len(filters))
+	for _, filter := range filters {
+		name, value, ok := strings.Cut(filter, ":")
+		if !ok || name == "" {
+			return nil, false
+		}
+		operator := entity.FilterEqual
+		if op, v, ok := strings.Cut(value, ":"); ok && isOperator(op) {
+			operator, value = op, v
+		}
+		parsed = append(parsed, entity.AttributeFilter{Name: name, Operator: operator, Value: value})
+	}
+	return parsed, true
+}
+
+func isOperator(op string) bool {
+	switch op {
+	case entity.FilterEqual, entity.FilterNotEqual,
+		entity.FilterGreaterThan, entity.FilterGreaterThanOrEqual,
+		entity.FilterLessThan, entity.FilterLessThanOrEqual:
+		return true
+	}
+	return false
+}
+
+func (r GetListUsersRequest) validate() []FieldError {
+	errs := validateFields(r.Fields)
+	if _, ok := parseFilters(r.Filters); !ok {
+		errs = append(errs, FieldError{Field: "filters", Rule: "filter"})
+	}
+	return errs
+}
+
+func (r GetUserRequest) validate() []FieldError {
+	return validateFields(r.Fields)
+}
+
+func (r DefineAttributeRequest) validate() []FieldError {
+	if r.Name == "" || users.ValidAttributeName(r.Name) {
+		return nil
+	}
+	return []FieldError{{Field: "name", Rule: "attribute_name"}}
+}
@@ -0,0 +1,73 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	pkgRest "github.com/kubuskotak/asgard/rest"
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+// ListAttributes defined for the users.
+func (h *Mongorest) ListAttributes(w http.ResponseWriter, r *http.Request) (ListAttributesResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "ListAttributes")
+	defer span.End()
+
+	docs, err := h.UsersUsecase.ListAttributes(ctx)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return ListAttributesResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	l.Info().Msg("ListAttributes")
+	return ListAttributesResponse{Data: docs}, nil
+}
+
+// DefineAttribute users may hold.
+func (h *Mongorest) DefineAttribute(w http.ResponseWriter, r *http.Request) (AttributeResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "DefineAttribute")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[DefineAttributeRequest](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return AttributeResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	doc, err := h.UsersUsecase.DefineAttribute(ctx, request.AttributeDefinition)
+	if errors.Is(err, users.ErrAttributeExists) || errors.Is(err, users.ErrAttributeConflict) {
+		l.Info().Msg(err.Error())
+		return AttributeResponse{}, pkgRest.ErrStatusConflict(w, r, err)
+	}
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return AttributeResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	l.Info().Msg("DefineAttribute")
+	return AttributeResponse{AttributeDefinition: doc}, nil
+}
+
+// RemoveAttribute and the values users hold.
+func (h *Mongorest) RemoveAttribute(w http.ResponseWriter, r *http.Request) (ResponseMessage, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "RemoveAttribute")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[AttributeParam](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return ResponseMessage{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	err = h.UsersUsecase.RemoveAttribute(ctx, request.Name)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return ResponseMessage{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	l.Info().Msg("RemoveAttribute")
+	return ResponseMessage{Message: fmt.Sprintf("success remove attribute %v", request.Name)}, nil
+}
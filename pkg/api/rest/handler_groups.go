@@ -0,0 +1,240 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	pkgRest "github.com/kubuskotak/asgard/rest"
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/groups"
+)
+
+// GetAllGroups group.
+func (h *Mongorest) GetAllGroups(w http.ResponseWriter, r *http.Request) (GetListGroupsResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "GetAllGroups")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[GetListGroupsRequest](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetListGroupsResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	documents, err := h.GroupsUsecase.GetAll(ctx, request.Pagination)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetListGroupsResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	pkgRest.Paging(r, pkgRest.Pagination{
+		Page:  documents.Page,
+		Limit: documents.Limit,
+	})
+
+	l.Info().Msg("GetAllGroups")
+	return GetListGroupsResponse{Data: documents.Groups}, nil
+}
+
+// CreateGroup group.
+func (h *Mongorest) CreateGroup(w http.ResponseWriter, r *http.Request) (GetGroupResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "CreateGroup")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[UpsertGroupRequest](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetGroupResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	payload := entity.Group{
+		Name:        request.Name,
+		Description: request.Description,
+	}
+
+	doc, err := h.GroupsUsecase.Create(ctx, payload)
+	if errors.Is(err, groups.ErrGroupExists) {
+		l.Info().Msg(err.Error())
+		return GetGroupResponse{}, pkgRest.ErrStatusConflict(w, r, err)
+	}
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetGroupResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	l.Info().Msg("CreateGroup")
+	return GetGroupResponse{Group: doc}, nil
+}
+
+// GetGroupByID group.
+func (h *Mongorest) GetGroupByID(w http.ResponseWriter, r *http.Request) (GetGroupResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "GetGroupByID")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[GetGroupRequestParam](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetGroupResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	doc, err := h.GroupsUsecase.GetByID(ctx, request.GroupID)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetGroupResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	l.Info().Msg("GetGroupByID")
+	return GetGroupResponse{Group: doc}, nil
+}
+
+// UpdateGroupByID group.
+func (h *Mongorest) UpdateGroupByID(w http.ResponseWriter, r *http.Request) (GetGroupResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "UpdateGroupByID")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[UpsertGroupRequest](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetGroupResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	payload := entity.Group{
+		ID:          request.GroupID,
+		Name:        request.Name,
+		Description: request.Description,
+	}
+
+	doc, err := h.GroupsUsecase.UpdateByID(ctx, payload)
+	if errors.Is(err, groups.ErrGroupExists) {
+		l.Info().Msg(err.Error())
+		return GetGroupResponse{}, pkgRest.ErrStatusConflict(w, r, err)
+	}
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetGroupResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	l.Info().Msg("UpdateGroupByID")
+	return GetGroupResponse{Group: doc}, nil
+}
+
+// DeleteGroupByID group and its memberships.
+func (h *Mongorest) DeleteGroupByID(w http.ResponseWriter, r *http.Request) (ResponseMessage, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "DeleteGroupByID")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[GetGroupRequestParam](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return ResponseMessage{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	err = h.GroupsUsecase.DeleteByID(ctx, request.GroupID)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return ResponseMessage{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	l.Info().Msg("DeleteGroupByID")
+	return ResponseMessage{Message: fmt.Sprintf("success delete group %v", request.GroupID)}, nil
+}
+
+// GetGroupMembers of a group.
+func (h *Mongorest) GetGroupMembers(w http.ResponseWriter, r *http.Request) (GetGroupMembersResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "GetGroupMembers")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[GetGroupMembersRequest](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetGroupMembersResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	documents, err := h.GroupsUsecase.GetMembers(ctx, request.GroupID, request.Pagination)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetGroupMembersResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	pkgRest.Paging(r, pkgRest.Pagination{
+		Page:  documents.Page,
+		Limit: documents.Limit,
+	})
+
+	l.Info().Msg("GetGroupMembers")
+	return GetGroupMembersResponse{Data: documents.Members}, nil
+}
+
+// GetUserGroups of a user.
+func (h *Mongorest) GetUserGroups(w http.ResponseWriter, r *http.Request) (GetListGroupsResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "GetUserGroups")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[GetUserGroupsRequest](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetListGroupsResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	documents, err := h.GroupsUsecase.GetUserGroups(ctx, request.UserID, request.Pagination)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetListGroupsResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	pkgRest.Paging(r, pkgRest.Pagination{
+		Page:  documents.Page,
+		Limit: documents.Limit,
+	})
+
+	l.Info().Msg("GetUserGroups")
+	return GetListGroupsResponse{Data: documents.Groups}, nil
+}
+
+// AddGroupMember to a group.
+func (h *Mongorest) AddGroupMember(w http.ResponseWriter, r *http.Request) (GroupMemberResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "AddGroupMember")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[GroupMemberParam](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GroupMemberResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	doc, err := h.GroupsUsecase.AddMember(ctx, request.GroupID, request.UserID)
+	if errors.Is(err, groups.ErrMemberExists) {
+		l.Info().Msg(err.Error())
+		return GroupMemberResponse{}, pkgRest.ErrStatusConflict(w, r, err)
+	}
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GroupMemberResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	l.Info().Msg("AddGroupMember")
+	return GroupMemberResponse{GroupMember: doc}, nil
+}
+
+// RemoveGroupMember from a group.
+func (h *Mongorest) RemoveGroupMember(w http.ResponseWriter, r *http.Request) (ResponseMessage, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "RemoveGroupMember")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[GroupMemberParam](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return ResponseMessage{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	err = h.GroupsUsecase.RemoveMember(ctx, request.GroupID, request.UserID)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return ResponseMessage{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	l.Info().Msg("RemoveGroupMember")
+	return ResponseMessage{Message: fmt.Sprintf("success remove %v from group %v", request.UserID, request.GroupID)}, nil
+}
@@ -0,0 +1,138 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	pkgRest "github.com/kubuskotak/asgard/rest"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+)
+
+// Health statuses of the probe responses.
+const (
+	HealthOK          = "ok"
+	HealthUnavailable = "unavailable"
+)
+
+// Paths of the probes.
+const (
+	PathLive  = "/healthz"
+	PathReady = "/readyz"
+)
+
+// HealthOption is a struct holding the handler options.
+type HealthOption func(h *Health)
+
+// Health handler instance data, it serves the liveness and readiness probes.
+type Health struct {
+	Checkers []adapters.Checker
+	Timeout  time.Duration
+
+	shuttingDown atomic.Bool
+}
+
+// HealthCheck is the status of a dependency.
+type HealthCheck struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthResponse is the body of the probes.
+type HealthResponse struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks,omitempty"`
+}
+
+// NewHealth creates a new Health handler instance.
+func NewHealth(opts ...HealthOption) *Health {
+	handler := &Health{Timeout: 2 * time.Second}
+	for _, opt := range opts {
+		opt(handler)
+	}
+	return handler
+}
+
+// Register is endpoint group for handler.
+func (h *Health) Register(router chi.Router) {
+	router.Get(PathLive, h.Live)
+	router.Get(PathReady, h.Ready)
+}
+
+// Shutdown marks the service as not ready, load balancers stop routing to it
+// while the in-flight requests are drained.
+func (h *Health) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// Live [GET /healthz] reports that the process is serving.
+func (h *Health) Live(w http.ResponseWriter, _ *http.Request) {
+	writeHealth(w, HealthResponse{Status: HealthOK})
+}
+
+// Ready [GET /readyz] runs every checker concurrently and reports each dependency.
+func (h *Health) Ready(w http.ResponseWriter, r *http.Request) {
+	if h.shuttingDown.Load() {
+		writeHealth(w, HealthResponse{Status: HealthUnavailable, Checks: []HealthCheck{
+			{Name: "shutdown", Status: HealthUnavailable, Error: "service is shutting down"},
+		}})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.Timeout)
+	defer cancel()
+
+	var (
+		wg     sync.WaitGroup
+		checks = make([]HealthCheck, len(h.Checkers))
+	)
+	for idx, checker := range h.Checkers {
+		wg.Add(1)
+		go func(idx int, checker adapters.Checker) {
+			defer wg.Done()
+			start := time.Now()
+			err := checker.Check(ctx)
+			checks[idx] = HealthCheck{
+				Name:      checker.Name(),
+				Status:    HealthOK,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				checks[idx].Status, checks[idx].Error = HealthUnavailable, err.Error()
+			}
+		}(idx, checker)
+	}
+	wg.Wait()
+
+	resp := HealthResponse{Status: HealthOK, Checks: checks}
+	for _, check := range checks {
+		if check.Status != HealthOK {
+			resp.Status = HealthUnavailable
+		}
+	}
+	writeHealth(w, resp)
+}
+
+func writeHealth(w http.ResponseWriter, resp HealthResponse) {
+	w.Header().Set(pkgRest.HeaderContentType.String(), "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if resp.Status != HealthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// WithCheckers allows adding the dependencies checked by the readiness probe.
+func WithCheckers(checkers ...adapters.Checker) HealthOption {
+	return func(h *Health) {
+		h.Checkers = append(h.Checkers, checkers...)
+	}
+}
@@ -0,0 +1,54 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+)
+
+func ready(t *testing.T, h *Health) (int, HealthResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var resp HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return rec.Code, resp
+}
+
+func TestHealthReady(t *testing.T) {
+	var mongoErr error
+	h := NewHealth(WithCheckers(
+		adapters.NamedCheck("mongo", func(context.Context) error { return mongoErr }),
+		adapters.NamedCheck("migrations", func(context.Context) error { return nil }),
+	))
+
+	if code, resp := ready(t, h); code != http.StatusOK || resp.Status != HealthOK || len(resp.Checks) != 2 {
+		t.Fatalf("expected ready, got %d %+v", code, resp)
+	}
+
+	mongoErr = errors.New("server selection timeout")
+	code, resp := ready(t, h)
+	if code != http.StatusServiceUnavailable || resp.Checks[0].Status != HealthUnavailable || resp.Checks[1].Status != HealthOK {
+		t.Fatalf("expected mongo unavailable, got %d %+v", code, resp)
+	}
+
+	mongoErr = nil
+	h.Shutdown()
+	if code, resp = ready(t, h); code != http.StatusServiceUnavailable || resp.Checks[0].Name != "shutdown" {
+		t.Fatalf("expected not ready while shutting down, got %d %+v", code, resp)
+	}
+
+	rec := httptest.NewRecorder()
+	h.Live(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("liveness must not depend on shutdown, got %d", rec.Code)
+	}
+}
@@ -2,14 +2,19 @@
 package rest
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 
 	pkgRest "github.com/kubuskotak/asgard/rest"
 	pkgTracer "github.com/kubuskotak/asgard/tracer"
 	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/groups"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/idempotency"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/stats"
 	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
 )
 
@@ -18,7 +23,10 @@ type MongorestOption func(Mongorest *Mongorest)
 
 // Mongorest handler instance data.
 type Mongorest struct {
-	UsersUsecase users.T
+	UsersUsecase       users.T
+	GroupsUsecase      groups.T
+	StatsUsecase       stats.T
+	IdempotencyUsecase idempotency.T
 }
 
 // NewMongorest creates a new Mongorest handler instance.
@@ -52,11 +60,33 @@ func NewMongorest(opts ...MongorestOption) *Mongorest {
 
 // Register is endpoint group for handler.
 func (h *Mongorest) Register(router chi.Router) {
-	router.Get("/users", pkgRest.HandlerAdapter[GetListUsersRequest](h.GetAll).JSON)
-	router.Post("/user", pkgRest.HandlerAdapter[UpsertUserRequest](h.Create).JSON)
-	router.Get("/user/{UserId}", pkgRest.HandlerAdapter[GetRequestParam](h.GetByID).JSON)
-	router.Put("/user/{UserId}", pkgRest.HandlerAdapter[UpsertUserRequest](h.UpdateByID).JSON)
-	router.Delete("/user/{UserId}", pkgRest.HandlerAdapter[GetRequestParam](h.DeleteByID).JSON)
+	router.Get("/users", adapt[GetListUsersRequest](h.GetAll))
+	router.Get("/users/search", adapt[SearchUsersRequest](h.Search))
+	router.Get("/users/stats", adapt[GetUserStatsRequest](h.GetUserStats))
+	router.Get("/user/{UserId}", adapt[GetUserRequest](h.GetByID))
+	router.Get("/user/{UserId}/revisions/{Revision}", adapt[GetRevisionRequest](h.GetRevision))
+	router.Get("/user/{UserId}/export", adapt[GetRequestParam](h.Export))
+	router.Get("/attributes", adapt[ListAttributesRequest](h.ListAttributes))
+	router.Get("/user/{UserId}/groups", adapt[GetUserGroupsRequest](h.GetUserGroups))
+	router.Get("/groups", adapt[GetListGroupsRequest](h.GetAllGroups))
+	router.Get("/group/{GroupId}", adapt[GetGroupRequestParam](h.GetGroupByID))
+	router.Get("/group/{GroupId}/members", adapt[GetGroupMembersRequest](h.GetGroupMembers))
+	// mutating routes honor the Idempotency-Key header.
+	router.Group(func(router chi.Router) {
+		router.Use(h.idempotent)
+		router.Post("/user", adapt[UpsertUserRequest](h.Create))
+		router.Put("/user/{UserId}", adapt[UpsertUserRequest](h.UpdateByID))
+		router.Delete("/user/{UserId}", adapt[GetRequestParam](h.DeleteByID))
+		router.Post("/user/{UserId}/revert", adapt[GetRequestParam](h.Revert))
+		router.Post("/user/{UserId}/erase", adapt[GetRequestParam](h.Erase))
+		router.Post("/attributes", adapt[DefineAttributeRequest](h.DefineAttribute))
+		router.Delete("/attributes/{Name}", adapt[AttributeParam](h.RemoveAttribute))
+		router.Post("/group", adapt[UpsertGroupRequest](h.CreateGroup))
+		router.Put("/group/{GroupId}", adapt[UpsertGroupRequest](h.UpdateGroupByID))
+		router.Delete("/group/{GroupId}", adapt[GetGroupRequestParam](h.DeleteGroupByID))
+		router.Post("/group/{GroupId}/members/{UserId}", adapt[GroupMemberParam](h.AddGroupMember))
+		router.Delete("/group/{GroupId}/members/{UserId}", adapt[GroupMemberParam](h.RemoveGroupMember))
+	})
 }
 
 // GetAll user.
@@ -73,11 +103,20 @@ func (h *Mongorest) GetAll(w http.ResponseWriter, r *http.Request) (GetListUsers
 		return GetListUsersResponse{}, pkgRest.ErrBadRequest(w, r, err)
 	}
 
+	filters, _ := parseFilters(request.Filters)
 	payload := entity.RequestGetUsers{
 		Pagination: entity.Pagination{Limit: request.Limit, Page: request.Page},
+		Fields:     splitFields(request.Fields),
+		Filters:    filters,
+		Sort:       splitFields(request.Sort),
+		Count:      hypermedia(r) != "",
 	}
 
 	documents, err := h.UsersUsecase.GetAll(ctx, payload)
+	if errAttributes(w, r, err) {
+		l.Info().Msg(err.Error())
+		return GetListUsersResponse{}, nil
+	}
 	if err != nil {
 		l.Info().Msg(err.Error())
 		return GetListUsersResponse{}, pkgRest.ErrBadRequest(w, r, err)
@@ -86,12 +125,42 @@ func (h *Mongorest) GetAll(w http.ResponseWriter, r *http.Request) (GetListUsers
 	pkgRest.Paging(r, pkgRest.Pagination{
 		Page:  documents.Page,
 		Limit: documents.Limit,
+		Total: int(documents.Total),
 	})
 
 	l.Info().Msg("GetAll")
 	return GetListUsersResponse{Data: documents.Users}, nil
 }
 
+// Search users.
+func (h *Mongorest) Search(w http.ResponseWriter, r *http.Request) (SearchUsersResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "SearchUsers")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[SearchUsersRequest](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return SearchUsersResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	documents, err := h.UsersUsecase.Search(ctx, entity.RequestSearchUsers{
+		Query:      request.Q,
+		Pagination: request.Pagination,
+	})
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return SearchUsersResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	pkgRest.Paging(r, pkgRest.Pagination{
+		Page:  documents.Page,
+		Limit: documents.Limit,
+	})
+
+	l.Info().Msg("SearchUsers")
+	return SearchUsersResponse{Data: documents.Results}, nil
+}
+
 // Create user.
 func (h *Mongorest) Create(w http.ResponseWriter, r *http.Request) (GetUserResponse, error) {
 	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "CreateUser")
@@ -104,16 +173,25 @@ func (h *Mongorest) Create(w http.ResponseWriter, r *http.Request) (GetUserRespo
 	}
 
 	payload := entity.User{
-		Name:  request.Name,
-		Email: request.Email,
-		Age:   request.Age,
+		Name:       request.Name,
+		Email:      request.Email,
+		Age:        request.Age,
+		Attributes: request.Attributes,
 	}
 
 	documents, err := h.UsersUsecase.Create(ctx, payload)
+	if errAttributes(w, r, err) {
+		l.Info().Msg(err.Error())
+		return GetUserResponse{}, nil
+	}
+	if errors.Is(err, users.ErrEmailExists) {
+		return GetUserResponse{}, pkgRest.ErrStatusConflict(w, r, err)
+	}
 	if err != nil {
 		return GetUserResponse{}, pkgRest.ErrBadRequest(w, r, err)
 	}
 
+	setIdempotencySubject(r, documents.ID)
 	l.Info().Msg("CreateUser")
 	return GetUserResponse{User: documents}, nil
 }
@@ -123,13 +201,13 @@ func (h *Mongorest) GetByID(w http.ResponseWriter, r *http.Request) (GetUserResp
 	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "GetByID")
 	defer span.End()
 
-	request, err := pkgRest.GetBind[GetRequestParam](r)
+	request, err := pkgRest.GetBind[GetUserRequest](r)
 	if err != nil {
 		l.Info().Msg(err.Error())
 		return GetUserResponse{}, pkgRest.ErrBadRequest(w, r, err)
 	}
 
-	doc, err := h.UsersUsecase.GetByID(ctx, request.UserID)
+	doc, err := h.UsersUsecase.GetByID(ctx, request.UserID, splitFields(request.Fields)...)
 	if err != nil {
 		l.Info().Msg(err.Error())
 		return GetUserResponse{}, pkgRest.ErrBadRequest(w, r, err)
@@ -151,13 +229,22 @@ func (h *Mongorest) UpdateByID(w http.ResponseWriter, r *http.Request) (GetUserR
 	}
 
 	payload := entity.User{
-		ID:    request.UserID,
-		Name:  request.Name,
-		Email: request.Email,
-		Age:   request.Age,
+		ID:         request.UserID,
+		Name:       request.Name,
+		Email:      request.Email,
+		Age:        request.Age,
+		Attributes: request.Attributes,
 	}
 
 	doc, err := h.UsersUsecase.UpdateByID(ctx, payload)
+	if errAttributes(w, r, err) {
+		l.Info().Msg(err.Error())
+		return GetUserResponse{}, nil
+	}
+	if errors.Is(err, users.ErrEmailExists) {
+		l.Info().Msg(err.Error())
+		return GetUserResponse{}, pkgRest.ErrStatusConflict(w, r, err)
+	}
 	if err != nil {
 		l.Info().Msg(err.Error())
 		return GetUserResponse{}, pkgRest.ErrBadRequest(w, r, err)
@@ -188,9 +275,130 @@ func (h *Mongorest) DeleteByID(w http.ResponseWriter, r *http.Request) (Response
 	return ResponseMessage{Message: fmt.Sprintf("success delete %v", request.UserID)}, nil
 }
 
+// GetRevision user.
+func (h *Mongorest) GetRevision(w http.ResponseWriter, r *http.Request) (GetRevisionResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "GetRevision")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[GetRevisionRequest](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetRevisionResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	doc, err := h.UsersUsecase.GetRevision(ctx, request.UserID, request.Revision)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetRevisionResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	l.Info().Msg("GetRevision")
+	return GetRevisionResponse{UserRevision: doc}, nil
+}
+
+// Revert user to the revision given by the `to` query param.
+func (h *Mongorest) Revert(w http.ResponseWriter, r *http.Request) (GetUserResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "Revert")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[GetRequestParam](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetUserResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	// query params are not bound on POST, read the target revision directly.
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil || to < 1 {
+		err = errors.New("query param to must be a revision number greater than 0")
+		l.Info().Msg(err.Error())
+		return GetUserResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	doc, err := h.UsersUsecase.Revert(ctx, request.UserID, to)
+	if errAttributes(w, r, err) {
+		l.Info().Msg(err.Error())
+		return GetUserResponse{}, nil
+	}
+	if errors.Is(err, users.ErrUserErased) {
+		l.Info().Msg(err.Error())
+		return GetUserResponse{}, pkgRest.ErrStatusConflict(w, r, err)
+	}
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetUserResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	l.Info().Msg("Revert")
+	return GetUserResponse{User: doc}, nil
+}
+
+// Export every data held about the user.
+func (h *Mongorest) Export(w http.ResponseWriter, r *http.Request) (GetUserExportResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "Export")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[GetRequestParam](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetUserExportResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	doc, err := h.UsersUsecase.Export(ctx, request.UserID)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetUserExportResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	l.Info().Msg("Export")
+	return GetUserExportResponse{UserExport: doc}, nil
+}
+
+// Erase the personal data of the user.
+func (h *Mongorest) Erase(w http.ResponseWriter, r *http.Request) (ErasureReceiptResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "Erase")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[GetRequestParam](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return ErasureReceiptResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	receipt, err := h.UsersUsecase.Erase(ctx, request.UserID)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return ErasureReceiptResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	l.Info().Msg("Erase")
+	return ErasureReceiptResponse{ErasureReceipt: receipt}, nil
+}
+
 // WithUsersUsecase allows setting the UsersUsecase during initialisation.
 func WithUsersUsecase(uc users.T) MongorestOption {
 	return func(m *Mongorest) {
 		m.UsersUsecase = uc
 	}
 }
+
+// WithStatsUsecase allows setting the StatsUsecase during initialisation.
+func WithStatsUsecase(uc stats.T) MongorestOption {
+	return func(m *Mongorest) {
+		m.StatsUsecase = uc
+	}
+}
+
+// WithGroupsUsecase allows setting the GroupsUsecase during initialisation.
+func WithGroupsUsecase(uc groups.T) MongorestOption {
+	return func(m *Mongorest) {
+		m.GroupsUsecase = uc
+	}
+}
+
+// WithIdempotencyUsecase allows setting the IdempotencyUsecase during initialisation.
+func WithIdempotencyUsecase(uc idempotency.T) MongorestOption {
+	return func(m *Mongorest) {
+		m.IdempotencyUsecase = uc
+	}
+}
@@ -7,6 +7,39 @@ import "github.com/kubuskotak/ymir-test/pkg/entity"
 // for getting users request.
 type GetListUsersRequest struct {
 	entity.Pagination `json:"pagination"`
+	Fields            string   `json:"fields,omitempty"`  // comma separated user fields, empty is all
+	Filters           []string `json:"filters,omitempty"` // attribute filters as name:value or name:operator:value
+	Sort              string   `json:"sort,omitempty"`    // comma separated user fields or attributes.<name>, - sorts descending
+}
+
+// SearchUsersRequest is a struct for request
+// that holds the search query and pagination.
+type SearchUsersRequest struct {
+	entity.Pagination `json:"pagination"`
+	Q                 string `json:"q" validate:"required,max=100"` // words of the name, email or custom fields
+}
+
+// SearchUsersResponse is a struct for response
+// that return the users found, best match first.
+type SearchUsersResponse struct {
+	Data []entity.UserSearchResult
+}
+
+// GetUserStatsRequest is a struct for request
+// that holds how the user stats are bucketed.
+type GetUserStatsRequest struct {
+	Interval string `json:"interval,omitempty" validate:"omitempty,enum=day week"`
+	Timezone string `json:"timezone,omitempty" validate:"omitempty,timezone"` // IANA name, UTC when empty
+	From     string `json:"from,omitempty" validate:"omitempty,date"`         // first day of created_at counted
+	To       string `json:"to,omitempty" validate:"omitempty,date"`           // last day of created_at counted
+	AgeWidth int    `schema:"age_width" json:"age_width,omitempty" validate:"omitempty,min=1,max=100"`
+	Domains  int    `json:"domains,omitempty" validate:"omitempty,min=1,max=100"` // most used email domains listed, when Stats.domains is on
+}
+
+// GetUserStatsResponse is a struct for response
+// that return the user stats.
+type GetUserStatsResponse struct {
+	entity.UserStats
 }
 
 // ResponseMessage is a struct for response
@@ -33,9 +66,133 @@ type GetRequestParam struct {
 	UserID string
 }
 
+// GetUserRequest is a struct for request
+// that holds a UserId from param and the fields to return.
+type GetUserRequest struct {
+	GetRequestParam
+	Fields string `json:"fields,omitempty"` // comma separated user fields, empty is all
+}
+
 // UpsertUserRequest is a struct for request
 // that holds a User object.
 type UpsertUserRequest struct {
 	GetRequestParam
 	entity.User
 }
+
+// GetRevisionRequest is a struct for request
+// that holds a UserId and a revision number from param.
+type GetRevisionRequest struct {
+	GetRequestParam
+	Revision int `validate:"gte=1"`
+}
+
+// GetRevisionResponse is a struct for response
+// that return a UserRevision object.
+type GetRevisionResponse struct {
+	entity.UserRevision
+}
+
+// GetUserExportResponse is a struct for response
+// that return the UserExport bundle.
+type GetUserExportResponse struct {
+	entity.UserExport
+}
+
+// ErasureReceiptResponse is a struct for response
+// that return the ErasureReceipt object.
+type ErasureReceiptResponse struct {
+	entity.ErasureReceipt
+}
+
+// ListAttributesRequest is a struct for request
+// that lists the attribute definitions.
+type ListAttributesRequest struct{}
+
+// ListAttributesResponse is a struct for response
+// that holds a slice of AttributeDefinition objects.
+type ListAttributesResponse struct {
+	Data []entity.AttributeDefinition
+}
+
+// AttributeParam is a struct for request
+// that holds an attribute Name from param.
+type AttributeParam struct {
+	Name string
+}
+
+// DefineAttributeRequest is a struct for request
+// that holds an AttributeDefinition object.
+type DefineAttributeRequest struct {
+	entity.AttributeDefinition
+}
+
+// AttributeResponse is a struct for response
+// that return an AttributeDefinition object.
+type AttributeResponse struct {
+	entity.AttributeDefinition
+}
+
+// GetListGroupsRequest is a struct that embeds Pagination fields
+// for getting groups request.
+type GetListGroupsRequest struct {
+	entity.Pagination `json:"pagination"`
+}
+
+// GetListGroupsResponse is a struct for response
+// that holds a slice of Group objects.
+type GetListGroupsResponse struct {
+	Data []entity.Group
+}
+
+// GetGroupRequestParam is a struct for request
+// that holds a GroupId from param.
+type GetGroupRequestParam struct {
+	GroupID string
+}
+
+// GetGroupResponse is a struct for response
+// that return Group objects.
+type GetGroupResponse struct {
+	entity.Group
+}
+
+// UpsertGroupRequest is a struct for request
+// that holds a Group object.
+type UpsertGroupRequest struct {
+	GetGroupRequestParam
+	entity.Group
+}
+
+// GetGroupMembersRequest is a struct for request
+// that holds a GroupId from param and Pagination fields.
+type GetGroupMembersRequest struct {
+	GetGroupRequestParam
+	entity.Pagination `json:"pagination"`
+}
+
+// GetGroupMembersResponse is a struct for response
+// that holds a slice of GroupMember objects.
+type GetGroupMembersResponse struct {
+	Data []entity.GroupMember
+}
+
+// GetUserGroupsRequest is a struct for request
+// that holds a UserId from param and Pagination fields.
+type GetUserGroupsRequest struct {
+	GetRequestParam
+	entity.Pagination `json:"pagination"`
+}
+
+// GroupMemberParam is a struct for request
+// that holds a GroupId and a UserId from param.
+type GroupMemberParam struct {
+	GetGroupRequestParam
+	GetRequestParam
+}
+
+// GroupMemberResponse is a struct for response
+// that return a GroupMember object.
+type GroupMemberResponse struct {
+	entity.GroupMember
+}
@@ -0,0 +1,109 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	pkgRest "github.com/kubuskotak/asgard/rest"
+)
+
+// swaggerUI is the version of swagger-ui-dist loaded by the docs page.
+const swaggerUI = "5.9.0"
+
+// OpenAPIOption is a struct holding the handler options.
+type OpenAPIOption func(h *OpenAPI)
+
+// OpenAPI handler instance data, it serves the OpenAPI document of Mongorest
+// and a Swagger UI page reading it.
+type OpenAPI struct {
+	Title        string
+	Version      string
+	TenantHeader string // documented on every operation when set
+	Tenancy      bool   // documents the 401 and 403 of the tenancy middleware
+	RateLimited  bool   // documents the 429 of the rate limit middleware
+
+	document []byte
+}
+
+// NewOpenAPI creates a new OpenAPI handler documenting the routes of h.
+func NewOpenAPI(h *Mongorest, opts ...OpenAPIOption) (*OpenAPI, error) {
+	handler := &OpenAPI{Title: "users", Version: "0.0.0"}
+	for _, opt := range opts {
+		opt(handler)
+	}
+	document, err := openAPIDocument(h.Register, mongorestOperations, handler)
+	if err != nil {
+		return nil, err
+	}
+	if handler.document, err = json.Marshal(document); err != nil {
+		return nil, err
+	}
+	return handler, nil
+}
+
+// Register is endpoint group for handler.
+func (h *OpenAPI) Register(router chi.Router) {
+	router.Get("/openapi.json", h.Document)
+	router.Get("/docs", h.Docs)
+}
+
+// Document [GET /openapi.json] sends the OpenAPI document.
+func (h *OpenAPI) Document(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set(pkgRest.HeaderContentType.String(), pkgRest.MIMEApplicationJSON.String())
+	_, _ = w.Write(h.document)
+}
+
+var docsPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@{{.UI}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@{{.UI}}/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => { window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" }); };
+  </script>
+</body>
+</html>
+`))
+
+// Docs [GET /docs] sends the Swagger UI page.
+func (h *OpenAPI) Docs(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set(pkgRest.HeaderContentType.String(), pkgRest.MIMETextHTMLCharsetUTF8.String())
+	_ = docsPage.Execute(w, struct{ Title, UI string }{Title: h.Title, UI: swaggerUI})
+}
+
+// WithOpenAPIInfo option function to assign the title and version of the document.
+func WithOpenAPIInfo(title, version string) OpenAPIOption {
+	return func(h *OpenAPI) {
+		h.Title = title
+		h.Version = version
+	}
+}
+
+// WithOpenAPITenantHeader option function to document the tenant header.
+func WithOpenAPITenantHeader(header string) OpenAPIOption {
+	return func(h *OpenAPI) {
+		h.TenantHeader = header
+	}
+}
+
+// WithOpenAPITenancy option function to document the failures of the tenant resolution.
+func WithOpenAPITenancy() OpenAPIOption {
+	return func(h *OpenAPI) {
+		h.Tenancy = true
+	}
+}
+
+// WithOpenAPIRateLimit option function to document the requests over quota.
+func WithOpenAPIRateLimit() OpenAPIOption {
+	return func(h *OpenAPI) {
+		h.RateLimited = true
+	}
+}
@@ -0,0 +1,38 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"net/http"
+
+	pkgRest "github.com/kubuskotak/asgard/rest"
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+)
+
+// GetUserStats counts the users for the dashboards.
+func (h *Mongorest) GetUserStats(w http.ResponseWriter, r *http.Request) (GetUserStatsResponse, error) {
+	ctx, span, l := pkgTracer.StartSpanLogTrace(r.Context(), "GetUserStats")
+	defer span.End()
+
+	request, err := pkgRest.GetBind[GetUserStatsRequest](r)
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetUserStatsResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	stats, err := h.StatsUsecase.Users(ctx, entity.RequestUserStats{
+		Interval: request.Interval,
+		TimeZone: request.Timezone,
+		From:     request.From,
+		To:       request.To,
+		AgeWidth: request.AgeWidth,
+		Domains:  request.Domains,
+	})
+	if err != nil {
+		l.Info().Msg(err.Error())
+		return GetUserStatsResponse{}, pkgRest.ErrBadRequest(w, r, err)
+	}
+
+	l.Info().Msg("GetUserStats")
+	return GetUserStatsResponse{UserStats: stats}, nil
+}
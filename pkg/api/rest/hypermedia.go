@@ -0,0 +1,329 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	pkgRest "github.com/kubuskotak/asgard/rest"
+	"github.com/rs/zerolog/log"
+
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+)
+
+// hypermedia types a client may ask for in Accept, plain JSON keeps the asgard envelope.
+const (
+	MIMEApplicationJSONAPI = "application/vnd.api+json"
+	MIMEApplicationHAL     = "application/hal+json"
+)
+
+type ctxMediaKey struct{}
+
+// resource is an item of a hypermedia document, its attributes are without the id.
+type resource struct {
+	Type       string
+	ID         string
+	Attributes any
+	Meta       any
+}
+
+// hypermediaResponse is implemented by the responses served as JSON:API and HAL documents,
+// the other responses are served as plain JSON whatever the client accepts.
+type hypermediaResponse interface {
+	resources() (kind string, items []resource, collection bool)
+}
+
+// JSONAPIDocument is the body of a JSON:API response.
+type JSONAPIDocument struct {
+	Data  any                 `json:"data"` // a JSONAPIResource or a slice of them
+	Links map[string]string   `json:"links"`
+	Meta  *pkgRest.Pagination `json:"meta,omitempty"`
+}
+
+// JSONAPIResource is a typed resource of a JSON:API document.
+type JSONAPIResource struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id,omitempty"`
+	Attributes any               `json:"attributes,omitempty"`
+	Links      map[string]string `json:"links,omitempty"`
+	Meta       any               `json:"meta,omitempty"`
+}
+
+// HALLink is a link of a HAL document.
+type HALLink struct {
+	Href string `json:"href"`
+}
+
+func (r GetListUsersResponse) resources() (string, []resource, bool) {
+	items := make([]resource, 0, len(r.Data))
+	for _, user := range r.Data {
+		items = append(items, userResource(user, nil))
+	}
+	return "users", items, true
+}
+
+func (r SearchUsersResponse) resources() (string, []resource, bool) {
+	items := make([]resource, 0, len(r.Data))
+	for _, found := range r.Data {
+		items = append(items, userResource(found.User, struct {
+			Score      float64           `json:"score"`
+			Match      string            `json:"match"`
+			Highlights map[string]string `json:"highlights,omitempty"`
+		}{found.Score, found.Match, found.Highlights}))
+	}
+	return "users", items, true
+}
+
+func (r GetUserResponse) resources() (string, []resource, bool) {
+	return "users", []resource{userResource(r.User, nil)}, false
+}
+
+func userResource(user entity.User, meta any) resource {
+	id := user.ID
+	user.ID = ""
+	return resource{Type: "users", ID: id, Attributes: user, Meta: meta}
+}
+
+// resourcePath is the path a resource is served at.
+func resourcePath(item resource) string {
+	if item.ID == "" {
+		return ""
+	}
+	switch item.Type {
+	case "users":
+		return "/user/" + url.PathEscape(item.ID)
+	}
+	return ""
+}
+
+// negotiate returns the hypermedia type preferred by the Accept header, or empty when
+// plain JSON is preferred. Explicit types win over wildcards of the same quality.
+func negotiate(accept string) string {
+	var (
+		best     string
+		bestQ    float64
+		explicit bool
+	)
+	for _, part := range strings.Split(accept, ",") {
+		media, params, _ := strings.Cut(part, ";")
+		media = strings.ToLower(strings.TrimSpace(media))
+		q, extended := 1.0, false
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			switch strings.ToLower(key) {
+			case "":
+			case "q":
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			default:
+				extended = true
+			}
+		}
+		specific := true
+		switch media {
+		case MIMEApplicationJSONAPI:
+			// JSON:API forbids serving a media type with parameters it does not know.
+			if extended {
+				continue
+			}
+		case MIMEApplicationHAL:
+		case pkgRest.MIMEApplicationJSON.String():
+			media = ""
+		case "application/*", "*/*":
+			media, specific = "", false
+		default:
+			continue
+		}
+		if q > bestQ || q == bestQ && q > 0 && specific && !explicit {
+			best, bestQ, explicit = media, q, specific
+		}
+	}
+	return best
+}
+
+// hypermedia returns the hypermedia type negotiated for the request by adapt, empty for plain JSON.
+func hypermedia(r *http.Request) string {
+	media, _ := r.Context().Value(ctxMediaKey{}).(string)
+	return media
+}
+
+// withHypermedia negotiates the media type of the request for the response types
+// served as hypermedia documents.
+func withHypermedia[Res pkgRest.ResponseConstraint](w http.ResponseWriter, r *http.Request) string {
+	var zero Res
+	if _, ok := any(zero).(hypermediaResponse); !ok {
+		return ""
+	}
+	w.Header().Add("Vary", "Accept")
+	media := negotiate(r.Header.Get("Accept"))
+	if media != "" {
+		*r = *r.WithContext(context.WithValue(r.Context(), ctxMediaKey{}, media))
+	}
+	return media
+}
+
+// serveHypermedia serves fn as a document of media, errors keep the asgard envelope.
+func serveHypermedia[Req pkgRest.RequestConstraint, Res pkgRest.ResponseConstraint](fn pkgRest.Adapter[Req, Res], media string, w http.ResponseWriter, r *http.Request) {
+	// a response per request, the adapter holds the payload.
+	adapter := pkgRest.HandlerAdapter[Req](fn)
+	adapter.ServeHTTP(w, r)
+	code, ok := r.Context().Value(pkgRest.CtxStatusCode).(int)
+	if !ok || code < 1 {
+		code = http.StatusOK
+	}
+	if code >= http.StatusBadRequest {
+		return
+	}
+	payload, ok := adapter.Data.(hypermediaResponse)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	kind, items, collection := payload.resources()
+
+	var document any
+	if collection {
+		paging := adapter.Pagination
+		links := pageLinks(r.URL, &paging, len(items))
+		if media == MIMEApplicationJSONAPI {
+			document = jsonAPICollection(items, links, paging)
+		} else {
+			document = halCollection(kind, items, links, paging)
+		}
+	} else {
+		links := map[string]string{"self": r.URL.RequestURI()}
+		if path := resourcePath(items[0]); path != "" {
+			links["self"] = path
+		}
+		if media == MIMEApplicationJSONAPI {
+			document = JSONAPIDocument{Data: jsonAPIResource(items[0]), Links: links}
+		} else {
+			document = halResource(items[0], links)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(document); err != nil {
+		log.Error().Err(pkgRest.ErrInternalServerError(w, r, err)).Msg("hypermedia")
+		return
+	}
+	w.Header().Set(pkgRest.HeaderContentType.String(), media)
+	w.WriteHeader(code)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Error().Err(err).Msg("hypermedia")
+	}
+}
+
+// pageLinks are the self, first, prev, next and last links of the page of u. The last
+// page is only known when the matches are counted or when the first page is empty,
+// otherwise next is linked as long as the page is full. The page count is filled in.
+func pageLinks(u *url.URL, paging *pkgRest.Pagination, count int) map[string]string {
+	links := map[string]string{"self": u.RequestURI()}
+	if paging.Limit < 1 {
+		return links
+	}
+	at := func(page int) string {
+		query := u.Query()
+		query.Set("page", strconv.Itoa(page))
+		query.Set("limit", strconv.Itoa(paging.Limit))
+		return u.Path + "?" + query.Encode()
+	}
+	page := paging.Page
+	if page < 1 {
+		page = 1
+	}
+	links["first"] = at(1)
+	if page > 1 {
+		links["prev"] = at(page - 1)
+	}
+	switch {
+	case paging.Total > 0 || page == 1 && count == 0:
+		last := (paging.Total + paging.Limit - 1) / paging.Limit
+		if last < 1 {
+			last = 1
+		}
+		paging.Size = last
+		links["last"] = at(last)
+		if page < last {
+			links["next"] = at(page + 1)
+		}
+	case count >= paging.Limit:
+		links["next"] = at(page + 1)
+	}
+	return links
+}
+
+func jsonAPIResource(item resource) JSONAPIResource {
+	res := JSONAPIResource{Type: item.Type, ID: item.ID, Attributes: item.Attributes, Meta: item.Meta}
+	if path := resourcePath(item); path != "" {
+		res.Links = map[string]string{"self": path}
+	}
+	return res
+}
+
+func jsonAPICollection(items []resource, links map[string]string, paging pkgRest.Pagination) JSONAPIDocument {
+	data := make([]JSONAPIResource, 0, len(items))
+	for _, item := range items {
+		data = append(data, jsonAPIResource(item))
+	}
+	return JSONAPIDocument{Data: data, Links: links, Meta: &paging}
+}
+
+// halResource holds the attributes and the meta of item at the top level, next to its links.
+func halResource(item resource, links map[string]string) map[string]any {
+	document := map[string]any{}
+	for _, part := range []any{item.Attributes, item.Meta} {
+		if part == nil {
+			continue
+		}
+		// the raw values keep their numbers as encoded.
+		var fields map[string]json.RawMessage
+		if b, err := json.Marshal(part); err == nil && json.Unmarshal(b, &fields) == nil {
+			for name, value := range fields {
+				document[name] = value
+			}
+		}
+	}
+	if item.ID != "" {
+		document["id"] = item.ID
+	}
+	if len(links) == 0 {
+		if path := resourcePath(item); path != "" {
+			links = map[string]string{"self": path}
+		}
+	}
+	if len(links) > 0 {
+		document["_links"] = halLinks(links)
+	}
+	return document
+}
+
+func halCollection(kind string, items []resource, links map[string]string, paging pkgRest.Pagination) map[string]any {
+	embedded := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		embedded = append(embedded, halResource(item, nil))
+	}
+	document := map[string]any{
+		"_links":    halLinks(links),
+		"_embedded": map[string]any{kind: embedded},
+	}
+	// the page is described with the names of the plain envelope.
+	if b, err := json.Marshal(paging); err == nil {
+		_ = json.Unmarshal(b, &document)
+	}
+	return document
+}
+
+func halLinks(links map[string]string) map[string]HALLink {
+	hal := make(map[string]HALLink, len(links))
+	for rel, href := range links {
+		hal[rel] = HALLink{Href: href}
+	}
+	return hal
+}
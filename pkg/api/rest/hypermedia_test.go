@@ -0,0 +1,140 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+type fakeUsers struct {
+	users.T
+	counted bool
+}
+
+func (f *fakeUsers) GetAll(_ context.Context, request entity.RequestGetUsers) (entity.ResponseGetUsers, error) {
+	f.counted = request.Count
+	result := entity.ResponseGetUsers{Pagination: request.Pagination, Users: []entity.User{
+		{ID: "64a7f1f2c2a4b1e0d4b3c2a1", Name: "john", Age: 30},
+		{ID: "64a7f1f2c2a4b1e0d4b3c2a2", Name: "jane", Age: 28},
+	}}
+	if request.Count {
+		result.Total = 5
+	}
+	return result, nil
+}
+
+func TestNegotiate(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                                       "",
+		"application/json":                       "",
+		"*/*":                                    "",
+		"application/vnd.api+json":               MIMEApplicationJSONAPI,
+		"application/hal+json, */*":              MIMEApplicationHAL,
+		"application/json, application/hal+json": "",
+		"application/json;q=0.5, application/hal+json": MIMEApplicationHAL,
+		"application/vnd.api+json; ext=bulk":           "",
+		"text/html, application/hal+json;q=0":          "",
+	} {
+		if got := negotiate(accept); got != want {
+			t.Errorf("%q: expected %q, got %q", accept, want, got)
+		}
+	}
+}
+
+func TestHypermedia(t *testing.T) {
+	fake := &fakeUsers{}
+	router := chi.NewRouter()
+	NewMongorest(WithUsersUsecase(fake)).Register(router)
+	get := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users?page=2&limit=2&sort=name", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d %s", accept, w.Code, w.Body.String())
+		}
+		return w
+	}
+	links := map[string]string{
+		"self":  "/users?page=2&limit=2&sort=name",
+		"first": "/users?limit=2&page=1&sort=name",
+		"prev":  "/users?limit=2&page=1&sort=name",
+		"next":  "/users?limit=2&page=3&sort=name",
+		"last":  "/users?limit=2&page=3&sort=name",
+	}
+
+	t.Run("json api", func(t *testing.T) {
+		w := get(MIMEApplicationJSONAPI)
+		if ct := w.Header().Get("Content-Type"); ct != MIMEApplicationJSONAPI || !fake.counted {
+			t.Fatalf("expected a counted %s document, got %q", MIMEApplicationJSONAPI, ct)
+		}
+		var doc struct {
+			Data  []JSONAPIResource `json:"data"`
+			Links map[string]string `json:"links"`
+			Meta  struct {
+				PageCount int `json:"page_count"`
+			} `json:"meta"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		for rel, href := range links {
+			if doc.Links[rel] != href {
+				t.Errorf("%s: expected %q, got %q", rel, href, doc.Links[rel])
+			}
+		}
+		if len(doc.Data) != 2 || doc.Data[0].Type != "users" || doc.Data[0].ID != "64a7f1f2c2a4b1e0d4b3c2a1" ||
+			doc.Data[0].Links["self"] != "/user/64a7f1f2c2a4b1e0d4b3c2a1" || doc.Meta.PageCount != 3 {
+			t.Fatalf("unexpected document %s", w.Body.String())
+		}
+		if attributes, _ := doc.Data[0].Attributes.(map[string]any); attributes["name"] != "john" || attributes["id"] != nil {
+			t.Fatalf("expected the attributes without the id, got %v", doc.Data[0].Attributes)
+		}
+	})
+
+	t.Run("hal", func(t *testing.T) {
+		w := get(MIMEApplicationHAL)
+		var doc struct {
+			Links    map[string]HALLink `json:"_links"`
+			Embedded struct {
+				Users []struct {
+					ID    string             `json:"id"`
+					Name  string             `json:"name"`
+					Links map[string]HALLink `json:"_links"`
+				} `json:"users"`
+			} `json:"_embedded"`
+			TotalCount int `json:"total_count"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if w.Header().Get("Content-Type") != MIMEApplicationHAL || doc.Links["last"].Href != links["last"] ||
+			len(doc.Embedded.Users) != 2 || doc.Embedded.Users[1].Name != "jane" ||
+			doc.Embedded.Users[1].Links["self"].Href != "/user/64a7f1f2c2a4b1e0d4b3c2a2" || doc.TotalCount != 5 {
+			t.Fatalf("unexpected document %s", w.Body.String())
+		}
+	})
+
+	t.Run("plain json", func(t *testing.T) {
+		w := get("application/json")
+		var resp struct {
+			Pagination map[string]int  `json:"pagination"`
+			Data       json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if fake.counted || w.Header().Get("Content-Type") != "application/json" || w.Header().Get("Vary") != "Accept" ||
+			resp.Pagination["total_count"] != 0 || len(resp.Data) == 0 {
+			t.Fatalf("expected the plain envelope, got %s", w.Body.String())
+		}
+	})
+}
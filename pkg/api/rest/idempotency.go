@@ -0,0 +1,148 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	pkgRest "github.com/kubuskotak/asgard/rest"
+	"github.com/rs/zerolog/log"
+
+	"github.com/kubuskotak/ymir-test/pkg/usecase/idempotency"
+)
+
+const (
+	// HeaderIdempotencyKey is the request header holding the client idempotency key.
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed is set on responses replayed from a previous request.
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKey  = 255
+	maxIdempotencyBody = 1 << 20 // 1 MB
+)
+
+// idempotent is middleware handler replaying the stored response when a
+// request is retried with the same Idempotency-Key.
+func (h *Mongorest) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderIdempotencyKey)
+		if h.IdempotencyUsecase == nil || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			writeError(w, http.StatusBadRequest, errors.New("idempotency key is too long"))
+			return
+		}
+		key = idempotency.Scoped(r.Context(), key)
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotencyBody))
+		if err != nil {
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// the Accept header picks the representation of the response, a retry asking
+		// for another one must not replay the stored one.
+		sum := sha256.New()
+		_, _ = io.WriteString(sum, r.Method+"\n"+r.URL.RequestURI()+"\n"+r.Header.Get("Accept")+"\n")
+		_, _ = sum.Write(body)
+		fingerprint := hex.EncodeToString(sum.Sum(nil))
+
+		record, err := h.IdempotencyUsecase.Start(r.Context(), key, fingerprint)
+		switch {
+		case errors.Is(err, idempotency.ErrKeyReused):
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		case errors.Is(err, idempotency.ErrInFlight):
+			writeError(w, http.StatusConflict, err)
+			return
+		case err != nil:
+			log.Error().Err(err).Msg("idempotency key is failed to start")
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		case record != nil:
+			w.Header().Set(pkgRest.HeaderContentType.String(), record.ContentType)
+			w.Header().Set(HeaderIdempotentReplayed, "true")
+			w.WriteHeader(record.StatusCode)
+			_, _ = io.WriteString(w, record.Body)
+			return
+		}
+
+		subject := new(string)
+		r = r.WithContext(context.WithValue(r.Context(), idempotencySubjectKey{}, subject))
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			// a panicking handler leaves no response to replay, the recoverer answers it.
+			if p := recover(); p != nil {
+				h.releaseIdempotencyKey(r, key)
+				panic(p)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+
+		// server errors are not the final word, let the client retry them.
+		if rec.status >= http.StatusInternalServerError {
+			h.releaseIdempotencyKey(r, key)
+			return
+		}
+		// the route is only matched once served, a created user is named by its handler.
+		if id := chi.URLParam(r, "UserId"); id != "" {
+			*subject = id
+		}
+		err = h.IdempotencyUsecase.Complete(r.Context(), key, *subject, rec.status,
+			rec.Header().Get(pkgRest.HeaderContentType.String()), rec.body.Bytes())
+		if err != nil {
+			// without the response a retry could only be answered 409 until the key expires.
+			log.Error().Err(err).Msg("idempotency key is failed to complete")
+			h.releaseIdempotencyKey(r, key)
+		}
+	})
+}
+
+// idempotencySubjectKey holds the user the response of an idempotent request is about.
+type idempotencySubjectKey struct{}
+
+// setIdempotencySubject names the user a request without a UserId in its path is about,
+// so that its stored response is forgotten when the user is erased.
+func setIdempotencySubject(r *http.Request, userID string) {
+	if subject, ok := r.Context().Value(idempotencySubjectKey{}).(*string); ok {
+		*subject = userID
+	}
+}
+
+// releaseIdempotencyKey drops the key of a request that left no response to replay.
+func (h *Mongorest) releaseIdempotencyKey(r *http.Request, key string) {
+	if err := h.IdempotencyUsecase.Release(r.Context(), key); err != nil {
+		log.Error().Err(err).Msg("idempotency key is failed to release")
+	}
+}
+
+// responseRecorder passes the response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	if !rr.wroteHeader {
+		rr.status = code
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.wroteHeader = true
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
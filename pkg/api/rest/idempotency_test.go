@@ -0,0 +1,163 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/idempotency"
+)
+
+type fakeIdempotency struct {
+	records      map[string]*entity.IdempotencyRecord
+	failComplete bool
+}
+
+func (f *fakeIdempotency) Start(_ context.Context, key, fingerprint string) (*entity.IdempotencyRecord, error) {
+	record, ok := f.records[key]
+	switch {
+	case !ok:
+		f.records[key] = &entity.IdempotencyRecord{Key: key, Fingerprint: fingerprint, Status: entity.IdempotencyProcessing}
+		return nil, nil
+	case record.Fingerprint != fingerprint:
+		return nil, idempotency.ErrKeyReused
+	case record.Status != entity.IdempotencyCompleted:
+		return nil, idempotency.ErrInFlight
+	}
+	return record, nil
+}
+
+func (f *fakeIdempotency) Complete(_ context.Context, key, subject string, statusCode int, contentType string, body []byte) error {
+	if f.failComplete {
+		return errors.New("mongo is down")
+	}
+	record := f.records[key]
+	record.Subject, record.Status, record.StatusCode, record.ContentType, record.Body =
+		subject, entity.IdempotencyCompleted, statusCode, contentType, string(body)
+	return nil
+}
+
+func (f *fakeIdempotency) Release(_ context.Context, key string) error {
+	delete(f.records, key)
+	return nil
+}
+
+func (f *fakeIdempotency) Forget(context.Context, string) (string, int, error) {
+	return "idempotency_keys", 0, nil
+}
+
+func TestIdempotent(t *testing.T) {
+	var (
+		calls   int
+		handler = &Mongorest{IdempotencyUsecase: &fakeIdempotency{records: map[string]*entity.IdempotencyRecord{}}}
+		router  = chi.NewRouter()
+	)
+	router.With(handler.idempotent).Post("/user", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	})
+	send := func(key, body string, accept ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(body))
+		req.Header.Set(HeaderIdempotencyKey, key)
+		if len(accept) > 0 {
+			req.Header.Set("Accept", accept[0])
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send("k1", `{"name":"john"}`)
+	retry := send("k1", `{"name":"john"}`)
+	if calls != 1 {
+		t.Fatalf("handler should run once, ran %d times", calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() ||
+		retry.Header().Get(HeaderIdempotentReplayed) != "true" {
+		t.Fatalf("expected replayed response, got %d %q", retry.Code, retry.Body.String())
+	}
+	if reused := send("k1", `{"name":"jane"}`); reused.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for reused key, got %d", reused.Code)
+	}
+	if negotiated := send("k1", `{"name":"john"}`, "application/hal+json"); negotiated.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a key reused with another Accept, got %d", negotiated.Code)
+	}
+	if other := send("k2", `{"name":"jane"}`); other.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("expected a new key to run the handler, got %d", other.Code)
+	}
+}
+
+func TestIdempotentRelease(t *testing.T) {
+	var (
+		fake    = &fakeIdempotency{records: map[string]*entity.IdempotencyRecord{}}
+		handler = &Mongorest{IdempotencyUsecase: fake}
+		router  = chi.NewRouter()
+	)
+	router.Use(middleware.Recoverer)
+	router.With(handler.idempotent).Post("/panic", func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+	router.With(handler.idempotent).Post("/user", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	send := func(path string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+		req.Header.Set(HeaderIdempotencyKey, "k1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := send("/panic"); code != http.StatusInternalServerError || len(fake.records) != 0 {
+		t.Fatalf("expected the key of a panic to be released, got %d %v", code, fake.records)
+	}
+	fake.failComplete = true
+	if code := send("/user"); code != http.StatusCreated || len(fake.records) != 0 {
+		t.Fatalf("expected the key to be released when it cannot complete, got %d %v", code, fake.records)
+	}
+}
+
+func TestIdempotentSubject(t *testing.T) {
+	var (
+		fake    = &fakeIdempotency{records: map[string]*entity.IdempotencyRecord{}}
+		handler = &Mongorest{IdempotencyUsecase: fake}
+		router  = chi.NewRouter()
+	)
+	router.Group(func(router chi.Router) {
+		router.Use(handler.idempotent)
+		router.Post("/user", func(w http.ResponseWriter, r *http.Request) {
+			setIdempotencySubject(r, "u1")
+			w.WriteHeader(http.StatusCreated)
+		})
+		router.Put("/user/{UserId}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		router.Post("/group", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})
+	})
+	send := func(method, path, key string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+		req.Header.Set(HeaderIdempotencyKey, key)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send(http.MethodPost, "/user", "k1")
+	send(http.MethodPut, "/user/u1", "k2")
+	send(http.MethodPost, "/group", "k3")
+	for key, subject := range map[string]string{"k1": "u1", "k2": "u1", "k3": ""} {
+		if record := fake.records[key]; record == nil || record.Subject != subject {
+			t.Fatalf("expected %s to be about %q, got %+v", key, subject, record)
+		}
+	}
+}
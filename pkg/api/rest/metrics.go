@@ -0,0 +1,41 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/metrics"
+)
+
+// routeUnmatched labels requests outside of the registered routes, raw paths
+// would give every scanned url its own series.
+const routeUnmatched = "unmatched"
+
+// metrics is middleware handler observing rate, errors and duration per route.
+func (r *Router) metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		route := routeUnmatched
+		if rctx := chi.NewRouteContext(); r.h.Match(rctx, req.Method, req.URL.Path) {
+			route = rctx.RoutePattern()
+		}
+
+		metrics.HTTPInFlight.Inc()
+		defer metrics.HTTPInFlight.Dec()
+
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, req.ProtoMajor)
+		next.ServeHTTP(ww, req)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		metrics.HTTPRequests.WithLabelValues(req.Method, route, strconv.Itoa(status)).Inc()
+		metrics.HTTPDuration.WithLabelValues(req.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
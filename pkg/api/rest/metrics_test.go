@@ -0,0 +1,32 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/kubuskotak/ymir-test/pkg/shared/metrics"
+)
+
+func TestMetricsRouteLabels(t *testing.T) {
+	r := Routes()
+	r.h.Use(r.metrics)
+	r.h.Get("/user/{UserId}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r.h.NotFound(http.NotFound)
+
+	for _, path := range []string{"/user/1", "/user/2", "/wp-admin"} {
+		r.h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/user/{UserId}", "404")); got != 2 {
+		t.Fatalf("expected 2 requests on the route pattern, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(http.MethodGet, routeUnmatched, "404")); got != 1 {
+		t.Fatalf("expected 1 unmatched request, got %v", got)
+	}
+}
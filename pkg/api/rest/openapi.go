@@ -0,0 +1,556 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	pkgRest "github.com/kubuskotak/asgard/rest"
+)
+
+// openAPIVersion is the version of the OpenAPI specification of the document.
+const openAPIVersion = "3.1.0"
+
+// openAPIOperation documents a route, its request is bound from the path,
+// the query of GET and DELETE requests and the body of the others.
+type openAPIOperation struct {
+	Summary    string
+	Request    any   // request type of the handler adapter
+	Response   any   // data of the response envelope
+	Errors     []int // status codes of the failures
+	Idempotent bool  // honors the Idempotency-Key header
+}
+
+// mongorestOperations documents the routes of Mongorest.Register by method and pattern.
+var mongorestOperations = map[string]openAPIOperation{
+	"GET /users": {
+		Summary: "List users", Request: GetListUsersRequest{}, Response: GetListUsersResponse{},
+		Errors: []int{http.StatusBadRequest},
+	},
+	"GET /users/search": {
+		Summary: "Search users by name, email and custom fields", Request: SearchUsersRequest{}, Response: SearchUsersResponse{},
+		Errors: []int{http.StatusBadRequest},
+	},
+	"GET /users/stats": {
+		Summary: "Count users by creation date, age and email domain", Request: GetUserStatsRequest{}, Response: GetUserStatsResponse{},
+		Errors: []int{http.StatusBadRequest},
+	},
+	"GET /user/{UserId}": {
+		Summary: "Get a user", Request: GetUserRequest{}, Response: GetUserResponse{},
+		Errors: []int{http.StatusBadRequest},
+	},
+	"GET /user/{UserId}/revisions/{Revision}": {
+		Summary: "Get a revision of a user", Request: GetRevisionRequest{}, Response: GetRevisionResponse{},
+		Errors: []int{http.StatusBadRequest},
+	},
+	"GET /user/{UserId}/export": {
+		Summary: "Export the personal data of a user", Request: GetRequestParam{}, Response: GetUserExportResponse{},
+		Errors: []int{http.StatusBadRequest},
+	},
+	"POST /user": {
+		Summary: "Create a user", Request: UpsertUserRequest{}, Response: GetUserResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusConflict}, Idempotent: true,
+	},
+	"PUT /user/{UserId}": {
+		Summary: "Update a user", Request: UpsertUserRequest{}, Response: GetUserResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusConflict}, Idempotent: true,
+	},
+	"DELETE /user/{UserId}": {
+		Summary: "Delete a user", Request: GetRequestParam{}, Response: ResponseMessage{},
+		Errors: []int{http.StatusBadRequest}, Idempotent: true,
+	},
+	"POST /user/{UserId}/revert": {
+		Summary: "Revert a user to a revision", Request: GetRequestParam{}, Response: GetUserResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusConflict}, Idempotent: true,
+	},
+	"POST /user/{UserId}/erase": {
+		Summary: "Erase the personal data of a user", Request: GetRequestParam{}, Response: ErasureReceiptResponse{},
+		Errors: []int{http.StatusBadRequest}, Idempotent: true,
+	},
+	"GET /attributes": {
+		Summary: "List the custom attributes of users", Request: ListAttributesRequest{}, Response: ListAttributesResponse{},
+		Errors: []int{http.StatusBadRequest},
+	},
+	"POST /attributes": {
+		Summary: "Define a custom attribute of users", Request: DefineAttributeRequest{}, Response: AttributeResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusConflict}, Idempotent: true,
+	},
+	"DELETE /attributes/{Name}": {
+		Summary: "Remove a custom attribute and its values", Request: AttributeParam{}, Response: ResponseMessage{},
+		Errors: []int{http.StatusBadRequest}, Idempotent: true,
+	},
+	"GET /groups": {
+		Summary: "List groups", Request: GetListGroupsRequest{}, Response: GetListGroupsResponse{},
+		Errors: []int{http.StatusBadRequest},
+	},
+	"GET /group/{GroupId}": {
+		Summary: "Get a group", Request: GetGroupRequestParam{}, Response: GetGroupResponse{},
+		Errors: []int{http.StatusBadRequest},
+	},
+	"GET /group/{GroupId}/members": {
+		Summary: "List the members of a group", Request: GetGroupMembersRequest{}, Response: GetGroupMembersResponse{},
+		Errors: []int{http.StatusBadRequest},
+	},
+	"GET /user/{UserId}/groups": {
+		Summary: "List the groups of a user", Request: GetUserGroupsRequest{}, Response: GetListGroupsResponse{},
+		Errors: []int{http.StatusBadRequest},
+	},
+	"POST /group": {
+		Summary: "Create a group", Request: UpsertGroupRequest{}, Response: GetGroupResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusConflict}, Idempotent: true,
+	},
+	"PUT /group/{GroupId}": {
+		Summary: "Update a group", Request: UpsertGroupRequest{}, Response: GetGroupResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusConflict}, Idempotent: true,
+	},
+	"DELETE /group/{GroupId}": {
+		Summary: "Delete a group and its memberships", Request: GetGroupRequestParam{}, Response: ResponseMessage{},
+		Errors: []int{http.StatusBadRequest}, Idempotent: true,
+	},
+	"POST /group/{GroupId}/members/{UserId}": {
+		Summary: "Add a user to a group", Request: GroupMemberParam{}, Response: GroupMemberResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusConflict}, Idempotent: true,
+	},
+	"DELETE /group/{GroupId}/members/{UserId}": {
+		Summary: "Remove a user from a group", Request: GroupMemberParam{}, Response: ResponseMessage{},
+		Errors: []int{http.StatusBadRequest}, Idempotent: true,
+	},
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// openAPIBuilder collects the component schemas while operations are documented.
+type openAPIBuilder struct {
+	info    *OpenAPI
+	schemas map[string]any
+	names   map[reflect.Type]string
+}
+
+// openAPIDocument generates the OpenAPI document of the routes registered by register,
+// it fails when a route is not documented by operations or an operation has no route.
+func openAPIDocument(register func(chi.Router), operations map[string]openAPIOperation, info *OpenAPI) (map[string]any, error) {
+	router := chi.NewRouter()
+	register(router)
+
+	var routes []string
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes = append(routes, method+" "+route)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(routes)
+
+	var (
+		b = &openAPIBuilder{info: info, schemas: map[string]any{}, names: map[reflect.Type]string{}}
+
+		paths      = map[string]any{}
+		seen       = map[string]bool{}
+		undocument []string
+	)
+	for _, route := range routes {
+		op, ok := operations[route]
+		if !ok {
+			undocument = append(undocument, route)
+			continue
+		}
+		seen[route] = true
+		method, pattern, _ := strings.Cut(route, " ")
+		item, _ := paths[pattern].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[pattern] = item
+		}
+		item[strings.ToLower(method)] = b.operation(method, pattern, op)
+	}
+	var stale []string
+	for route := range operations {
+		if !seen[route] {
+			stale = append(stale, route)
+		}
+	}
+	sort.Strings(stale)
+	if len(undocument) > 0 || len(stale) > 0 {
+		return nil, fmt.Errorf("openapi: undocumented routes %v, operations without route %v", undocument, stale)
+	}
+
+	b.schemas["Error"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"meta":    b.schema(reflect.TypeOf(pkgRest.Meta{})),
+			"version": b.schema(reflect.TypeOf(pkgRest.Version{})),
+		},
+	}
+	return map[string]any{
+		"openapi":    openAPIVersion,
+		"info":       map[string]any{"title": info.Title, "version": info.Version},
+		"paths":      paths,
+		"components": map[string]any{"schemas": b.schemas},
+	}, nil
+}
+
+func (b *openAPIBuilder) operation(method, pattern string, op openAPIOperation) map[string]any {
+	var (
+		parameters []any
+		inPath     = map[string]bool{}
+	)
+	for _, m := range pathParam.FindAllStringSubmatch(pattern, -1) {
+		inPath[strings.ToLower(m[1])] = true
+	}
+
+	// the binder decodes path and query params into the fields of the request by name.
+	request := reflect.TypeOf(op.Request)
+	bound := map[string]bool{}
+	for _, f := range bindFields(request) {
+		name := f.Name
+		if alias := f.Tag.Get("schema"); alias != "" {
+			name = alias
+		}
+		switch {
+		case inPath[strings.ToLower(name)]:
+			bound[f.Name] = true
+			param := map[string]any{"name": pathName(pattern, name), "in": "path", "required": true, "schema": b.field(f)}
+			parameters = append(parameters, param)
+		case method == http.MethodGet || method == http.MethodDelete:
+			param := map[string]any{"name": strings.ToLower(name), "in": "query", "schema": b.field(f)}
+			if required(f) {
+				param["required"] = true
+			}
+			parameters = append(parameters, param)
+		}
+	}
+	if b.info.TenantHeader != "" {
+		parameters = append(parameters, map[string]any{
+			"name": b.info.TenantHeader, "in": "header", "description": "tenant of the request",
+			"schema": map[string]any{"type": "string", "pattern": "^[a-z0-9][a-z0-9_-]{0,47}$"},
+		})
+	}
+	if op.Idempotent {
+		parameters = append(parameters, map[string]any{
+			"name": HeaderIdempotencyKey, "in": "header", "description": "replays the stored response of a retried request",
+			"schema": map[string]any{"type": "string", "maxLength": maxIdempotencyKey},
+		})
+	}
+
+	operation := map[string]any{
+		"summary":     op.Summary,
+		"operationId": operationID(method, pattern),
+		"tags":        []string{"users"},
+		"responses":   b.responses(op),
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	if method != http.MethodGet && method != http.MethodDelete {
+		body := b.object(request, bound)
+		if props, _ := body["properties"].(map[string]any); len(props) > 0 {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					pkgRest.MIMEApplicationJSON.String(): map[string]any{"schema": body},
+				},
+			}
+		}
+	}
+	return operation
+}
+
+func (b *openAPIBuilder) responses(op openAPIOperation) map[string]any {
+	responses := map[string]any{
+		strconv.Itoa(http.StatusOK): map[string]any{
+			"description": http.StatusText(http.StatusOK),
+			"content": map[string]any{
+				pkgRest.MIMEApplicationJSON.String(): map[string]any{"schema": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"meta":       b.schema(reflect.TypeOf(pkgRest.Meta{})),
+						"version":    b.schema(reflect.TypeOf(pkgRest.Version{})),
+						"pagination": b.schema(reflect.TypeOf(pkgRest.Pagination{})),
+						"data":       b.schema(reflect.TypeOf(op.Response)),
+					},
+				}},
+			},
+		},
+	}
+	if _, ok := op.Response.(hypermediaResponse); ok {
+		content := responses[strconv.Itoa(http.StatusOK)].(map[string]any)["content"].(map[string]any)
+		content[MIMEApplicationJSONAPI] = map[string]any{"schema": b.schema(reflect.TypeOf(JSONAPIDocument{}))}
+		// HAL holds the attributes at the top level, next to _links and _embedded.
+		content[MIMEApplicationHAL] = map[string]any{"schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"_links":    b.schema(reflect.TypeOf(map[string]HALLink{})),
+				"_embedded": map[string]any{"type": "object"},
+			},
+		}}
+	}
+	// every route is validated by adapt.
+	validation := b.schema(reflect.TypeOf(ValidationErrorResponse{}))
+	responses[strconv.Itoa(http.StatusUnprocessableEntity)] = map[string]any{
+		"description": http.StatusText(http.StatusUnprocessableEntity),
+		"content": map[string]any{
+			pkgRest.MIMEApplicationJSON.String(): map[string]any{"schema": validation},
+		},
+	}
+	errs := append([]int(nil), op.Errors...)
+	if b.info.Tenancy {
+		errs = append(errs, http.StatusUnauthorized, http.StatusForbidden)
+	}
+	if b.info.RateLimited {
+		errs = append(errs, http.StatusTooManyRequests)
+	}
+	if op.Idempotent {
+		// a key still in progress, or a request body over the limit of the stored ones.
+		errs = append(errs, http.StatusConflict, http.StatusRequestEntityTooLarge)
+	}
+	for _, code := range errs {
+		responses[strconv.Itoa(code)] = map[string]any{
+			"description": http.StatusText(code),
+			"content": map[string]any{
+				pkgRest.MIMEApplicationJSON.String(): map[string]any{
+					"schema": map[string]any{"$ref": "#/components/schemas/Error"},
+				},
+			},
+		}
+	}
+	if b.info.RateLimited {
+		responses[strconv.Itoa(http.StatusTooManyRequests)].(map[string]any)["headers"] = map[string]any{
+			"Retry-After": map[string]any{
+				"description": "seconds until the next request is allowed",
+				"schema":      map[string]any{"type": "integer"},
+			},
+		}
+	}
+	if op.Idempotent {
+		// a key reused with another request fails outside of the validation.
+		responses[strconv.Itoa(http.StatusUnprocessableEntity)] = map[string]any{
+			"description": http.StatusText(http.StatusUnprocessableEntity),
+			"content": map[string]any{
+				pkgRest.MIMEApplicationJSON.String(): map[string]any{"schema": map[string]any{
+					"anyOf": []any{validation, map[string]any{"$ref": "#/components/schemas/Error"}},
+				}},
+			},
+		}
+	}
+	return responses
+}
+
+// schema is the json schema of t, named structs are components.
+func (b *openAPIBuilder) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name, ok := b.names[t]
+		if !ok {
+			name = b.componentName(t)
+			b.names[t] = name
+			b.schemas[name] = nil // reserved while its fields are walked
+			b.schemas[name] = b.object(t, nil)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		return b.object(t, nil)
+	}
+	return map[string]any{}
+}
+
+// object is the json schema of the fields of struct t, embedded structs without
+// a json name are flattened like encoding/json does. Fields in skip are left out.
+func (b *openAPIBuilder) object(t reflect.Type, skip map[string]bool) map[string]any {
+	var (
+		properties = map[string]any{}
+		requires   []string
+	)
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" || skip[f.Name] {
+				continue
+			}
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				walk(f.Type)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			properties[name] = b.field(f)
+			if required(f) {
+				requires = append(requires, name)
+			}
+		}
+	}
+	walk(t)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(requires) > 0 {
+		sort.Strings(requires)
+		schema["required"] = requires
+	}
+	return schema
+}
+
+// field is the schema of a struct field with its validate constraints.
+func (b *openAPIBuilder) field(f reflect.StructField) map[string]any {
+	schema := b.schema(f.Type)
+	if _, ref := schema["$ref"]; ref {
+		return schema
+	}
+	constrain(schema, f)
+	return schema
+}
+
+// constrain maps the validate tag of f on json schema keywords.
+func constrain(schema map[string]any, f reflect.StructField) {
+	kind := schema["type"]
+	for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "email":
+			schema["format"] = "email"
+		case "url", "uri":
+			schema["format"] = "uri"
+		case "uuid":
+			schema["format"] = "uuid"
+		case "min", "max", "len":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			prefix := map[any]string{"string": "Length", "array": "Items", "object": "Properties"}[kind]
+			if prefix == "" {
+				if name != "len" {
+					schema[map[string]string{"min": "minimum", "max": "maximum"}[name]] = n
+				}
+				continue
+			}
+			if name == "min" || name == "len" {
+				schema["min"+prefix] = n
+			}
+			if name == "max" || name == "len" {
+				schema["max"+prefix] = n
+			}
+		case "gte", "lte", "gt", "lt":
+			if n, err := strconv.ParseFloat(param, 64); err == nil {
+				schema[map[string]string{
+					"gte": "minimum", "lte": "maximum", "gt": "exclusiveMinimum", "lt": "exclusiveMaximum",
+				}[name]] = n
+			}
+		case "oneof":
+			var enum []any
+			for _, v := range strings.Fields(param) {
+				enum = append(enum, typed(kind, v))
+			}
+			schema["enum"] = enum
+		case "default":
+			schema["default"] = typed(kind, param)
+		}
+	}
+}
+
+// typed converts a tag value to the json type of the schema.
+func typed(kind any, v string) any {
+	switch kind {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
+
+func required(f reflect.StructField) bool {
+	for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+// bindFields are the fields the binder decodes params into, embedded structs are flattened.
+func bindFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		switch {
+		case f.Anonymous && f.Type.Kind() == reflect.Struct:
+			fields = append(fields, bindFields(f.Type)...)
+		case f.IsExported():
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// componentName names a component by its type, qualified by package on a clash.
+func (b *openAPIBuilder) componentName(t reflect.Type) string {
+	if _, taken := b.schemas[t.Name()]; !taken {
+		return t.Name()
+	}
+	pkg := path.Base(t.PkgPath())
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+}
+
+// pathName is the spelling of the param in the pattern.
+func pathName(pattern, name string) string {
+	for _, m := range pathParam.FindAllStringSubmatch(pattern, -1) {
+		if strings.EqualFold(m[1], name) {
+			return m[1]
+		}
+	}
+	return name
+}
+
+// operationID derives a stable id, GET /user/{UserId}/export is getUserUserIdExport.
+func operationID(method, pattern string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(pattern, func(r rune) bool { return r == '/' || r == '{' || r == '}' }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
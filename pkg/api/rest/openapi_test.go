@@ -0,0 +1,109 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TestOpenAPIDrift fails when a route of Mongorest is added, moved or removed
+// without its operation in mongorestOperations.
+func TestOpenAPIDrift(t *testing.T) {
+	h := NewMongorest()
+	if _, err := openAPIDocument(h.Register, mongorestOperations, &OpenAPI{Title: "users", Version: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	extra := func(router chi.Router) {
+		h.Register(router)
+		router.Get("/user/{UserId}/orders", http.NotFound)
+	}
+	_, err := openAPIDocument(extra, mongorestOperations, &OpenAPI{Title: "users", Version: "test"})
+	if err == nil || !strings.Contains(err.Error(), "GET /user/{UserId}/orders") {
+		t.Fatalf("expected the undocumented route to fail, got %v", err)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	handler, err := NewOpenAPI(NewMongorest(), WithOpenAPIInfo("users", "1.0.0"), WithOpenAPITenantHeader("X-Tenant-ID"),
+		WithOpenAPITenancy(), WithOpenAPIRateLimit())
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	router := chi.NewRouter()
+	handler.Register(router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Parameters []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			RequestBody *struct {
+				Content map[string]struct {
+					Schema struct {
+						Properties map[string]map[string]any `json:"properties"`
+						Required   []string                  `json:"required"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+			Responses map[string]any `json:"responses"`
+		} `json:"paths"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if doc.OpenAPI != openAPIVersion {
+		t.Fatalf("unexpected version %q", doc.OpenAPI)
+	}
+
+	update := doc.Paths["/user/{UserId}"]["put"]
+	var params []string
+	for _, p := range update.Parameters {
+		params = append(params, p.In+":"+p.Name)
+	}
+	if got := strings.Join(params, ","); got != "path:UserId,header:X-Tenant-ID,header:Idempotency-Key" {
+		t.Fatalf("unexpected parameters %s", got)
+	}
+	// the statuses answered by the middlewares ahead of the handler.
+	for _, code := range []string{"401", "403", "409", "413", "422", "429"} {
+		if _, ok := update.Responses[code]; !ok {
+			t.Fatalf("expected %s to be documented, got %v", code, update.Responses)
+		}
+	}
+	body := update.RequestBody.Content["application/json"].Schema
+	if _, ok := body.Properties["UserID"]; ok {
+		t.Fatal("path params must not be in the body")
+	}
+	if name := body.Properties["name"]; name["minLength"] != 3.0 || name["maxLength"] != 100.0 {
+		t.Fatalf("expected validate tags as constraints, got %v", name)
+	}
+	if email := body.Properties["email"]; email["format"] != "email" {
+		t.Fatalf("expected email format, got %v", email)
+	}
+	if strings.Join(body.Required, ",") != "age,email,name" {
+		t.Fatalf("unexpected required fields %v", body.Required)
+	}
+
+	list := doc.Paths["/users"]["get"]
+	if _, ok := list.Responses["409"]; ok {
+		t.Fatal("only the idempotent operations answer 409")
+	}
+	if len(list.Parameters) != 6 || list.Parameters[0].Name != "page" || list.Parameters[2].Name != "fields" || list.Parameters[0].In != "query" {
+		t.Fatalf("expected pagination query params, got %+v", list.Parameters)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if !strings.Contains(w.Body.String(), "swagger-ui") {
+		t.Fatal("expected the swagger ui page")
+	}
+}
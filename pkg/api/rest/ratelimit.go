@@ -0,0 +1,126 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	pkgRest "github.com/kubuskotak/asgard/rest"
+	"github.com/rs/zerolog/log"
+
+	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/shared/ratelimit"
+)
+
+// ErrTooManyRequests is the error returned when a client is over its quota.
+var ErrTooManyRequests = errors.New("too many requests, retry later")
+
+// rateLimit is middleware handler taking a token per client and route.
+// A client is its api key when the key is configured, otherwise its ip address.
+func (r *Router) rateLimit(next http.Handler) http.Handler {
+	cfg := infrastructure.Envs.RateLimit
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// a limited probe would take the instance out of the load balancers.
+		if req.URL.Path == PathLive || req.URL.Path == PathReady {
+			next.ServeHTTP(w, req)
+			return
+		}
+		var (
+			route  = r.routePattern(req)
+			client = clientKey(req, cfg.KeyHeader, cfg.Clients, cfg.TrustForwarded)
+			rule   = ratelimit.Rule{Requests: cfg.Requests, Period: cfg.Period, Burst: cfg.Burst}
+		)
+		override, overridden := cfg.Routes[req.Method+" "+route]
+		if overridden {
+			rule = ratelimit.Rule(override)
+		}
+		// the quota of an api key cannot lift the override of a route, the stricter applies.
+		if key, ok := strings.CutPrefix(client, "key:"); ok {
+			rule = ratelimit.Rule(cfg.Clients[key])
+			if overridden {
+				rule = rule.Stricter(ratelimit.Rule(override))
+			}
+		}
+		if !rule.Valid() {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		decision, err := r.limiter.Allow(req.Context(), req.Method+" "+route+"|"+client, rule)
+		if err != nil {
+			// fail open, a broken limiter backend must not take the api down.
+			log.Error().Err(err).Msg("rate limiter is failed")
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(seconds(decision.Reset)))
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", rule.Requests, seconds(rule.Period), decision.Limit))
+		if !decision.Allowed {
+			h.Set("Retry-After", strconv.Itoa(seconds(decision.RetryAfter)))
+			writeError(w, http.StatusTooManyRequests, ErrTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// routePattern resolves the chi route pattern ahead of routing, so that
+// "/user/1" and "/user/2" share the bucket of "/user/{UserId}".
+func (r *Router) routePattern(req *http.Request) string {
+	rctx := chi.NewRouteContext()
+	if !r.h.Match(rctx, req.Method, req.URL.Path) {
+		return req.URL.Path
+	}
+	return rctx.RoutePattern()
+}
+
+// clientKey identifies the caller by api key or ip address. Only the keys of clients
+// are trusted, any other key would let a caller take a fresh bucket per request.
+func clientKey(req *http.Request, keyHeader string, clients map[string]infrastructure.RateLimitRule, trustForwarded bool) string {
+	if keyHeader != "" {
+		if key := req.Header.Get(keyHeader); key != "" {
+			if _, ok := clients[key]; ok {
+				return "key:" + key
+			}
+		}
+	}
+	if trustForwarded {
+		if fwd := req.Header.Get("X-Forwarded-For"); fwd != "" {
+			return "ip:" + strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds rounds a duration up to whole seconds for headers.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// writeError sends an error response in the same envelope as the handlers.
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set(pkgRest.HeaderContentType.String(), pkgRest.MIMEApplicationJSON.String())
+	w.Header().Set(pkgRest.HeaderContentTypeOptions.String(), "nosniff")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(struct {
+		Meta pkgRest.Meta `json:"meta"`
+	}{
+		Meta: pkgRest.Meta{Code: strconv.Itoa(code), Message: err.Error()},
+	})
+}
@@ -0,0 +1,122 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/shared/ratelimit"
+)
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, ratelimit.Rule) (ratelimit.Decision, error) {
+	return ratelimit.Decision{}, errors.New("redis is down")
+}
+
+func TestRateLimit(t *testing.T) {
+	envs := infrastructure.Envs
+	defer func() { infrastructure.Envs = envs }()
+	infrastructure.Envs = &infrastructure.Config{}
+	cfg := &infrastructure.Envs.RateLimit
+	cfg.KeyHeader = "X-API-Key"
+	cfg.Requests, cfg.Period = 1, time.Minute
+	cfg.Clients = map[string]infrastructure.RateLimitRule{"partner": {Requests: 2, Period: time.Minute}}
+
+	serve := func(limiter ratelimit.Limiter) func(peer, key string) *httptest.ResponseRecorder {
+		r := Routes(WithRateLimiter(limiter))
+		r.h.Use(r.rateLimit)
+		r.h.Get("/user/{UserId}", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		return func(peer, key string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+			req.RemoteAddr = peer
+			if key != "" {
+				req.Header.Set(cfg.KeyHeader, key)
+			}
+			w := httptest.NewRecorder()
+			r.h.ServeHTTP(w, req)
+			return w
+		}
+	}
+
+	t.Run("unknown keys share the ip bucket", func(t *testing.T) {
+		get := serve(ratelimit.NewMemory())
+		if w := get("203.0.113.7:4000", "random-1"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" {
+			t.Fatalf("expected the first request to pass, got %d %v", w.Code, w.Header())
+		}
+		w := get("203.0.113.7:4001", "random-2")
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+			t.Fatalf("expected another key from the same ip to be limited, got %d %v", w.Code, w.Header())
+		}
+		if w = get("203.0.113.8:4000", ""); w.Code != http.StatusOK {
+			t.Fatalf("expected another ip to have its own bucket, got %d", w.Code)
+		}
+	})
+
+	t.Run("configured key has its own bucket and rule", func(t *testing.T) {
+		get := serve(ratelimit.NewMemory())
+		if w := get("203.0.113.7:4000", ""); w.Code != http.StatusOK {
+			t.Fatalf("expected the ip to pass, got %d", w.Code)
+		}
+		for n := 0; n < 2; n++ {
+			if w := get("203.0.113.7:4000", "partner"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "2" {
+				t.Fatalf("request %d: expected the partner quota, got %d %v", n, w.Code, w.Header())
+			}
+		}
+		if w := get("203.0.113.7:4000", "partner"); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected the partner to run out, got %d", w.Code)
+		}
+	})
+
+	t.Run("route override applies to a configured key", func(t *testing.T) {
+		cfg.Routes = map[string]infrastructure.RateLimitRule{"GET /user/{UserId}": {Requests: 1, Period: time.Minute}}
+		cfg.Clients["throttled"] = infrastructure.RateLimitRule{Requests: 1, Period: time.Hour}
+		defer func() {
+			cfg.Routes = nil
+			delete(cfg.Clients, "throttled")
+		}()
+		get := serve(ratelimit.NewMemory())
+		if w := get("203.0.113.7:4000", "partner"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" {
+			t.Fatalf("expected the route quota for the partner, got %d %v", w.Code, w.Header())
+		}
+		if w := get("203.0.113.7:4000", "partner"); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected the partner quota not to lift the route override, got %d", w.Code)
+		}
+		// a key stricter than the route keeps its own quota.
+		if w := get("203.0.113.7:4000", "throttled"); w.Code != http.StatusOK ||
+			w.Header().Get("RateLimit-Policy") != "1;w=3600;burst=1" {
+			t.Fatalf("expected the quota of the throttled key, got %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("probes are not limited", func(t *testing.T) {
+		r := Routes(WithRateLimiter(ratelimit.NewMemory()))
+		r.h.Use(r.rateLimit)
+		NewHealth().Register(r.h)
+		for n := 0; n < 3; n++ {
+			for _, path := range []string{PathLive, PathReady} {
+				w := httptest.NewRecorder()
+				r.h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+					t.Fatalf("%s %d: expected an unlimited probe, got %d %v", path, n, w.Code, w.Header())
+				}
+			}
+		}
+	})
+
+	t.Run("failing limiter fails open", func(t *testing.T) {
+		get := serve(failingLimiter{})
+		for n := 0; n < 3; n++ {
+			if w := get("203.0.113.7:4000", ""); w.Code != http.StatusOK {
+				t.Fatalf("request %d: expected to pass, got %d", n, w.Code)
+			}
+		}
+	})
+}
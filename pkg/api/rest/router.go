@@ -10,34 +10,69 @@ import (
 	pkgTracer "github.com/kubuskotak/asgard/tracer"
 
 	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/shared/ratelimit"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
 	"github.com/kubuskotak/ymir-test/pkg/version"
 )
 
 // RegisterFunc is type func to register handler.
 type RegisterFunc func(h chi.Router) http.Handler
 
+// RouterOption is a struct holding the router options.
+type RouterOption func(r *Router)
+
 // Router is the data struct.
 type Router struct {
-	h *chi.Mux
+	h       *chi.Mux
+	limiter ratelimit.Limiter
+	tenants tenant.Resolver
 }
 
 // Register will assign rest handler.
 func (r *Router) Register(fn RegisterFunc) http.Handler {
 	// set router middleware
+	r.h.Use(r.metrics)
 	r.h.Use(pkgTracer.Middleware(
 		infrastructure.Envs.App.ServiceName))
 	r.h.Use(pkgRest.SemanticVersion(
 		infrastructure.Envs.App.ServiceName,
 		version.GetVersion().VersionNumber(),
 	))
+	if infrastructure.Envs.Tenancy.Enable {
+		r.h.Use(r.tenancy)
+	}
+	if infrastructure.Envs.Reads.Causal {
+		r.h.Use(consistency)
+	}
+	if r.limiter != nil {
+		r.h.Use(r.rateLimit)
+	}
 	r.h.NotFound(pkgRest.NotFoundDefault()) // Not Found Handler
 	return fn(r.h)
 }
 
 // Routes create Router instance.
-func Routes() *Router {
+func Routes(opts ...RouterOption) *Router {
 	r := &Router{
 		h: chi.NewRouter(), // port http
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
 	return r
 }
+
+// WithRateLimiter allows setting the rate limiter of the middleware chain.
+func WithRateLimiter(limiter ratelimit.Limiter) RouterOption {
+	return func(r *Router) {
+		r.limiter = limiter
+	}
+}
+
+// WithTenantResolver sets the trust rules of the tenancy middleware, without it
+// every request is rejected for lack of a tenant.
+func WithTenantResolver(resolver tenant.Resolver) RouterOption {
+	return func(r *Router) {
+		r.tenants = resolver
+	}
+}
@@ -0,0 +1,181 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	pkgRest "github.com/kubuskotak/asgard/rest"
+	"github.com/rs/zerolog/log"
+)
+
+// Client certificate policies of TLSServer.
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// TLSServerOption is tls server type return func.
+type TLSServerOption func(s *TLSServer) error
+
+// TLSServer is the https server, certificates are reloaded when their files change.
+type TLSServer struct {
+	Port string
+
+	certFile, keyFile, caFile string
+	clientAuth                tls.ClientAuthType
+
+	errCh   chan error
+	handler http.Handler
+	certs   *certificates
+	server  *http.Server
+	started bool
+}
+
+// NewTLSServer creates a https server.
+func NewTLSServer(opts ...TLSServerOption) (*TLSServer, error) {
+	s := &TLSServer{
+		Port:       "8443",
+		clientAuth: tls.NoClientCert,
+		errCh:      make(chan error, 1),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Handler will assign http handler.
+func (s *TLSServer) Handler(h http.Handler) {
+	s.handler = h
+}
+
+// ListenAndServe will run the server.
+func (s *TLSServer) ListenAndServe() error {
+	if s.handler == nil {
+		return pkgRest.ErrServerHandlerNotProvided
+	}
+	if s.started {
+		return pkgRest.ErrServerAlreadyStarted
+	}
+	certs, err := loadCertificates(s.certFile, s.keyFile, s.caFile)
+	if err != nil {
+		return err
+	}
+	if err = certs.watch(); err != nil {
+		return err
+	}
+	s.certs = certs
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", s.Port))
+	if err != nil {
+		_ = certs.Close()
+		return err
+	}
+	s.server = &http.Server{
+		Handler:           s.handler,
+		TLSConfig:         certs.tlsConfig(s.clientAuth),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       30 * time.Second,
+	}
+	go func() {
+		// certificates come from TLSConfig, so no files are given here.
+		if err := s.server.ServeTLS(lis, "", ""); err != nil && err != http.ErrServerClosed {
+			s.errCh <- err
+		}
+	}()
+	s.started = true
+	return nil
+}
+
+// Error is return channel for capture error.
+func (s *TLSServer) Error() chan error {
+	return s.errCh
+}
+
+// Quite will shutdown the server.
+func (s *TLSServer) Quite(ctx context.Context) error {
+	if !s.started {
+		return pkgRest.ErrServerNotStarted
+	}
+	defer func() {
+		if err := s.certs.Close(); err != nil {
+			log.Error().Err(err).Msg("tls certificate watcher is failed to close")
+		}
+	}()
+	if err := s.server.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Wait is over due to error")
+		return s.server.Close()
+	}
+	log.Info().Msgf("Stop https server at :%s", s.Port)
+	return nil
+}
+
+// WithTLSPort will assign to port field server.
+func WithTLSPort(port string) TLSServerOption {
+	return func(s *TLSServer) error {
+		s.Port = port
+		return nil
+	}
+}
+
+// WithCertificate will assign the certificate and key files.
+func WithCertificate(certFile, keyFile string) TLSServerOption {
+	return func(s *TLSServer) error {
+		if certFile == "" || keyFile == "" {
+			return errors.New("tls certificate and key files are required")
+		}
+		s.certFile, s.keyFile = certFile, keyFile
+		return nil
+	}
+}
+
+// WithClientCA will verify client certificates against the CA bundle, the policy
+// is one of none, optional or require and defaults to require.
+func WithClientCA(caFile, policy string) TLSServerOption {
+	return func(s *TLSServer) error {
+		if caFile == "" {
+			return nil
+		}
+		s.caFile = caFile
+		switch policy {
+		case "", ClientAuthRequire:
+			s.clientAuth = tls.RequireAndVerifyClientCert
+		case ClientAuthOptional:
+			s.clientAuth = tls.VerifyClientCertIfGiven
+		case ClientAuthNone:
+			s.clientAuth = tls.NoClientCert
+		default:
+			return fmt.Errorf("unknown tls client auth %q", policy)
+		}
+		return nil
+	}
+}
+
+// RedirectHTTPS is the handler sending every request to the https port.
+// 308 keeps the method and body of the request.
+func RedirectHTTPS(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
+}
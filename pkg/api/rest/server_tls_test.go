@@ -0,0 +1,107 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed key pair for the common name into dir.
+func writeCertificate(t *testing.T, dir, cn string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{cn},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func servedName(t *testing.T, c *certificates) string {
+	t.Helper()
+	conf, err := c.tlsConfig(tls.NoClientCert).GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(conf.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertificatesReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificate(t, dir, "first.local")
+
+	certs, err := loadCertificates(certFile, keyFile, "")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if err = certs.watch(); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	defer func() { _ = certs.Close() }()
+
+	if got := servedName(t, certs); got != "first.local" {
+		t.Fatalf("expected first.local, got %s", got)
+	}
+	writeCertificate(t, dir, "second.local")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for servedName(t, certs) != "second.local" {
+		if time.Now().After(deadline) {
+			t.Fatal("certificate was not reloaded")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestRedirectHTTPS(t *testing.T) {
+	for port, want := range map[int]string{
+		443:  "https://example.com/user/1?to=2",
+		8003: "https://example.com:8003/user/1?to=2",
+	} {
+		rec := httptest.NewRecorder()
+		RedirectHTTPS(port).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://example.com:8007/user/1?to=2", nil))
+		if rec.Code != http.StatusPermanentRedirect {
+			t.Fatalf("expected 308, got %d", rec.Code)
+		}
+		if got := rec.Header().Get("Location"); got != want {
+			t.Fatalf("expected %s, got %s", want, got)
+		}
+	}
+}
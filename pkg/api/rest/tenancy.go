@@ -0,0 +1,99 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+// Tenant sources of the Tenancy config. The jwt source is used whenever a secret is
+// configured, every request then needs a verified token.
+const (
+	TenantSourceHeader    = "header"
+	TenantSourceJWT       = "jwt"
+	TenantSourceSubdomain = "subdomain"
+)
+
+var (
+	// ErrTenantConflict is returned when the sources of a request name different tenants.
+	ErrTenantConflict = tenant.ErrConflict
+	// ErrInvalidToken is returned when the bearer token cannot be verified.
+	ErrInvalidToken = tenant.ErrInvalidToken
+)
+
+// tenantSources reads the tenants a request names in its configured sources, the
+// resolver decides whether they are trusted.
+type tenantSources struct {
+	sources    []string
+	header     string
+	baseDomain string
+	resolver   tenant.Resolver
+}
+
+// tenancy is middleware handler putting the tenant of the request in its context.
+func (r *Router) tenancy(next http.Handler) http.Handler {
+	cfg := infrastructure.Envs.Tenancy
+	sources := tenantSources{
+		sources:    cfg.Sources,
+		header:     cfg.Header,
+		baseDomain: cfg.BaseDomain,
+		resolver:   r.tenants,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		for _, path := range cfg.Exempt {
+			if req.URL.Path == path {
+				next.ServeHTTP(w, req)
+				return
+			}
+		}
+		id, err := sources.resolve(req)
+		switch {
+		case errors.Is(err, tenant.ErrInvalidToken), errors.Is(err, tenant.ErrTokenRequired):
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		case errors.Is(err, tenant.ErrConflict), errors.Is(err, tenant.ErrUntrusted):
+			writeError(w, http.StatusForbidden, err)
+			return
+		case err != nil:
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(tenant.WithID(req.Context(), id)))
+	})
+}
+
+// resolve returns the tenant of the request, see tenant.Resolver for the trust rules.
+func (t tenantSources) resolve(req *http.Request) (string, error) {
+	token, _ := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	var named []string
+	for _, source := range t.sources {
+		switch source {
+		case TenantSourceHeader:
+			named = append(named, req.Header.Get(t.header))
+		case TenantSourceSubdomain:
+			named = append(named, t.fromSubdomain(req))
+		}
+	}
+	return t.resolver.Resolve(token, req.RemoteAddr, named...)
+}
+
+// fromSubdomain reads the tenant from the first label of a host under the base domain.
+func (t tenantSources) fromSubdomain(req *http.Request) string {
+	if t.baseDomain == "" {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(req.Host)
+	if err != nil {
+		host = req.Host
+	}
+	sub, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(t.baseDomain))
+	if !ok || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
@@ -0,0 +1,56 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+func TestTenancy(t *testing.T) {
+	resolver, err := tenant.NewResolver("", "tenant_id", "", []string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	envs := infrastructure.Envs
+	defer func() { infrastructure.Envs = envs }()
+	infrastructure.Envs = &infrastructure.Config{}
+	infrastructure.Envs.Tenancy.Sources = []string{TenantSourceHeader, TenantSourceJWT, TenantSourceSubdomain}
+	infrastructure.Envs.Tenancy.Header = "X-Tenant-ID"
+	infrastructure.Envs.Tenancy.BaseDomain = "api.example.com"
+
+	var served string
+	handler := Routes(WithTenantResolver(resolver)).tenancy(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = tenant.FromContext(r.Context())
+	}))
+
+	for name, tc := range map[string]struct {
+		peer, header, host string
+		want               string
+		code               int
+	}{
+		"header from a client":    {peer: "203.0.113.7:4000", header: "acme", code: http.StatusForbidden},
+		"subdomain from a client": {peer: "203.0.113.7:4000", host: "acme.api.example.com", code: http.StatusForbidden},
+		"header from the gateway": {peer: "10.0.0.2:4000", header: "acme", want: "acme", code: http.StatusOK},
+		"agreeing":                {peer: "10.0.0.2:4000", header: "acme", host: "acme.api.example.com:8007", want: "acme", code: http.StatusOK},
+		"conflict":                {peer: "10.0.0.2:4000", header: "acme", host: "globex.api.example.com", code: http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.RemoteAddr = tc.peer
+		if tc.header != "" {
+			req.Header.Set("X-Tenant-ID", tc.header)
+		}
+		if tc.host != "" {
+			req.Host = tc.host
+		}
+		served = ""
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tc.code || served != tc.want {
+			t.Errorf("%s: expected %d %q, got %d %q", name, tc.code, tc.want, w.Code, served)
+		}
+	}
+}
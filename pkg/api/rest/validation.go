@@ -0,0 +1,327 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	idTranslations "github.com/go-playground/validator/v10/translations/id"
+	pkgRest "github.com/kubuskotak/asgard/rest"
+	"github.com/kubuskotak/asgard/security"
+
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+// maxValidatedBody bounds the body buffered to be bound twice, once for validation.
+const maxValidatedBody = 1 << 20 // 1 MB
+
+// FieldError is a failing field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationErrorResponse is the body of a 422 response.
+type ValidationErrorResponse struct {
+	Meta   pkgRest.Meta `json:"meta"`
+	Errors []FieldError `json:"errors"`
+}
+
+// validation messages of the asgard rules and of the request validators.
+var customMessages = map[string]map[string]string{
+	"en": {
+		"fields":         "{0} must only list the fields [{1}]",
+		"filter":         "{0} must be formatted as name:value or name:operator:value",
+		"attribute":      "{0} must only use the attributes [{1}]",
+		"attribute_name": "{0} must start with a lowercase letter followed by lowercase letters, digits or underscores",
+		"type":           "{0} must be a {1}",
+		"operator":       "{0} must use one of the operators [{1}]",
+		"sort":           "{0} must only sort on [{1}]",
+		"unique":         "{0} is already held by another user",
+		"enum":           "{0} must be one of [{1}]",
+		"date":           "{0} must be a date formatted as YYYY-MM-DD",
+		"datetime":       "{0} must be an RFC 3339 date time",
+		"daterange":      "{0} must be a date between 1900-01-01 and 2100-01-01",
+		"timezone":       "{0} must be a time zone of the IANA database such as Asia/Jakarta",
+	},
+	"id": {
+		"fields":         "{0} hanya boleh berisi field [{1}]",
+		"filter":         "{0} harus berformat nama:nilai atau nama:operator:nilai",
+		"attribute":      "{0} hanya boleh menggunakan atribut [{1}]",
+		"attribute_name": "{0} harus diawali huruf kecil dan hanya berisi huruf kecil, angka atau garis bawah",
+		"type":           "{0} harus berupa {1}",
+		"operator":       "{0} harus menggunakan salah satu operator [{1}]",
+		"sort":           "{0} hanya boleh diurutkan berdasarkan [{1}]",
+		"unique":         "{0} sudah dimiliki oleh pengguna lain",
+		"enum":           "{0} harus berupa salah satu dari [{1}]",
+		"date":           "{0} harus berupa tanggal dengan format YYYY-MM-DD",
+		"datetime":       "{0} harus berupa tanggal dan waktu RFC 3339",
+		"daterange":      "{0} harus berupa tanggal antara 1900-01-01 dan 2100-01-01",
+		"timezone":       "{0} harus berupa zona waktu dari basis data IANA seperti Asia/Jakarta",
+	},
+}
+
+var validationMessage = map[string]string{
+	"en": "request validation failed",
+	"id": "validasi permintaan gagal",
+}
+
+var (
+	validate   *validator.Validate
+	translator *ut.UniversalTranslator
+)
+
+func init() {
+	// the same rules and field names as the validation of the asgard handler adapter.
+	validate = validator.New()
+	_ = validate.RegisterValidation("date", security.DateValidation)
+	_ = validate.RegisterValidation("datetime", security.DatetimeValidation)
+	_ = validate.RegisterValidation("daterange", security.DateRangeValidation)
+	_ = validate.RegisterValidation("enum", security.ParseTags)
+	_ = validate.RegisterValidation("default", security.ParseDefault)
+	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		name, _, _ := strings.Cut(fld.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	translator = ut.New(en.New(), en.New(), id.New())
+	for locale, register := range map[string]func(*validator.Validate, ut.Translator) error{
+		"en": enTranslations.RegisterDefaultTranslations,
+		"id": idTranslations.RegisterDefaultTranslations,
+	} {
+		trans, _ := translator.GetTranslator(locale)
+		if err := register(validate, trans); err != nil {
+			panic(err)
+		}
+		for tag, message := range customMessages[locale] {
+			message := message
+			err := validate.RegisterTranslation(tag, trans, func(t ut.Translator) error {
+				return t.Add(tag, message, true)
+			}, func(t ut.Translator, fe validator.FieldError) string {
+				msg, _ := t.T(fe.Tag(), fe.Field(), fe.Param())
+				return msg
+			})
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// requestValidator is implemented by requests with rules struct tags cannot express.
+type requestValidator interface {
+	validate() []FieldError
+}
+
+// adapt serves fn with the asgard handler adapter, after validating the request
+// itself so that failures are answered with 422 and every failing field. Responses
+// with resources are served as JSON:API or HAL documents when the client asks so.
+func adapt[Req pkgRest.RequestConstraint, Res pkgRest.ResponseConstraint](fn pkgRest.Adapter[Req, Res]) http.HandlerFunc {
+	next := pkgRest.HandlerAdapter[Req](fn).JSON
+	return func(w http.ResponseWriter, r *http.Request) {
+		media := withHypermedia[Res](w, r)
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValidatedBody))
+		if err != nil {
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var request Req
+		_, err = pkgRest.Bind(r, &request)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			// malformed requests are answered by the handler adapter.
+			next(w, r)
+			return
+		}
+		if errs := validateRequest(r, &request); len(errs) > 0 {
+			unprocessable(w, r, errs)
+			return
+		}
+		if media != "" {
+			serveHypermedia(fn, media, w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// unprocessable answers 422 with every failing field in the language of the request.
+func unprocessable(w http.ResponseWriter, r *http.Request, errs []fieldError) {
+	locale := language(r.Header.Get("Accept-Language"))
+	w.Header().Set(pkgRest.HeaderContentType.String(), pkgRest.MIMEApplicationJSON.String())
+	w.Header().Set("Content-Language", locale)
+	_ = pkgRest.ErrUnprocessableEntity(w, r, nil)
+	_ = json.NewEncoder(w).Encode(ValidationErrorResponse{
+		Meta: pkgRest.Meta{
+			Code:    strconv.Itoa(http.StatusUnprocessableEntity),
+			Message: validationMessage[locale],
+		},
+		Errors: translate(errs, locale),
+	})
+}
+
+// errAttributes answers the users.AttributeErrors in err like a failed validation,
+// the handler then returns without error. It reports false for any other error.
+func errAttributes(w http.ResponseWriter, r *http.Request, err error) bool {
+	var attrs users.AttributeErrors
+	if !errors.As(err, &attrs) {
+		return false
+	}
+	errs := make([]fieldError, 0, len(attrs))
+	for _, e := range attrs {
+		errs = append(errs, fieldError{FieldError: FieldError{Field: e.Field, Rule: e.Rule, Param: e.Param}})
+	}
+	unprocessable(w, r, errs)
+	return true
+}
+
+// fieldError is a failing field before its message is translated.
+type fieldError struct {
+	FieldError
+	err validator.FieldError
+}
+
+// validateRequest returns the failing fields of the bound request v, named as the
+// client sent them: path params as in the route, query params and body by json name.
+func validateRequest(r *http.Request, v any) []fieldError {
+	var errs []fieldError
+	if rv, ok := v.(requestValidator); ok {
+		for _, e := range rv.validate() {
+			errs = append(errs, fieldError{FieldError: e})
+		}
+	}
+	err := validate.Struct(v)
+	if err == nil {
+		return errs
+	}
+	verrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return append(errs, fieldError{FieldError: FieldError{Rule: "invalid", Message: err.Error()}})
+	}
+	var params []string
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		params = rctx.URLParams.Keys
+	}
+	query := r.Method == http.MethodGet || r.Method == http.MethodDelete || r.Method == http.MethodHead
+
+	for _, fe := range verrs {
+		errs = append(errs, fieldError{
+			FieldError: FieldError{
+				Field: fieldPath(reflect.TypeOf(v).Elem(), fe.StructNamespace(), params, query),
+				Rule:  fe.Tag(),
+				Param: fe.Param(),
+			},
+			err: fe,
+		})
+	}
+	return errs
+}
+
+// fieldPath maps the go namespace of a failing field on its name in the request.
+// Embedded structs are flattened, like the binder and encoding/json do.
+func fieldPath(t reflect.Type, namespace string, params []string, query bool) string {
+	segments := strings.Split(namespace, ".")[1:] // the first one is the request type
+	var path []string
+	for _, segment := range segments {
+		name, index, _ := strings.Cut(segment, "[")
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		f, ok := t.FieldByName(name)
+		if !ok {
+			path = append(path, segment)
+			continue
+		}
+		t = f.Type
+		jsonName, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.Anonymous && (jsonName == "" || query) {
+			continue
+		}
+		switch {
+		case len(path) == 0 && paramName(params, f.Name) != "":
+			name = paramName(params, f.Name)
+		case query:
+			name = strings.ToLower(f.Name)
+		case jsonName != "":
+			name = jsonName
+		}
+		if index != "" {
+			name += "[" + index
+		}
+		path = append(path, name)
+	}
+	return strings.Join(path, ".")
+}
+
+func paramName(params []string, name string) string {
+	for _, param := range params {
+		if strings.EqualFold(param, name) {
+			return param
+		}
+	}
+	return ""
+}
+
+// translate sets the messages of errs in the locale.
+func translate(errs []fieldError, locale string) []FieldError {
+	trans, _ := translator.GetTranslator(locale)
+	out := make([]FieldError, 0, len(errs))
+	for _, e := range errs {
+		switch {
+		case e.err != nil:
+			e.Message = strings.Replace(e.err.Translate(trans), e.err.Field(), e.Field, 1)
+		case e.Message == "":
+			e.Message, _ = trans.T(e.Rule, e.Field, e.Param)
+		}
+		out = append(out, e.FieldError)
+	}
+	return out
+}
+
+// language picks the supported locale with the highest weight in an Accept-Language
+// header, English when none is supported.
+func language(header string) string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+	var candidates []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if _, ok := validationMessage[primary]; ok && q > 0 {
+			candidates = append(candidates, weighted{locale: primary, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	if len(candidates) == 0 {
+		return "en"
+	}
+	return candidates[0].locale
+}
@@ -0,0 +1,106 @@
+// Package rest is port handler.
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestValidationErrors(t *testing.T) {
+	router := chi.NewRouter()
+	NewMongorest().Register(router)
+
+	for name, tc := range map[string]struct {
+		method, target, body, language string
+		want                           map[string]string // field to rule
+		message                        string
+	}{
+		"body": {
+			method: http.MethodPost, target: "/user", body: `{"name":"jo","email":"not-an-email"}`,
+			want:    map[string]string{"name": "min", "email": "email", "age": "required"},
+			message: "name must be at least 3 characters in length",
+		},
+		"body in indonesian": {
+			method: http.MethodPost, target: "/user", body: `{"name":"jo","email":"john@example.com","age":20}`,
+			language: "fr-CH, id;q=0.9, en;q=0.8",
+			want:     map[string]string{"name": "min"},
+			message:  "panjang minimal name adalah 3 karakter",
+		},
+		"path": {
+			method: http.MethodGet, target: "/user/64a7f1f2c2a4b1e0d4b3c2a1/revisions/0",
+			want: map[string]string{"Revision": "gte"},
+		},
+		"query": {
+			method: http.MethodGet, target: "/users?page=-1",
+			want: map[string]string{"page": "gte"},
+		},
+		"search query": {
+			method: http.MethodGet, target: "/users/search?page=1",
+			want: map[string]string{"q": "required"},
+		},
+		"stats": {
+			method: http.MethodGet, target: "/users/stats?interval=month&timezone=Mars/Olympus&age_width=0",
+			want: map[string]string{"interval": "enum", "timezone": "timezone"},
+		},
+		"unknown field": {
+			method: http.MethodGet, target: "/users?fields=id,password",
+			want: map[string]string{"fields": "fields"},
+		},
+		"filter": {
+			method: http.MethodGet, target: "/users?filters=tier:gold&filters=tier",
+			want: map[string]string{"filters": "filter"},
+		},
+		"attribute name": {
+			method: http.MethodPost, target: "/attributes", body: `{"name":"Tier","type":"text"}`,
+			want: map[string]string{"name": "attribute_name", "type": "enum"},
+		},
+	} {
+		req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Language", tc.language)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected 422, got %d %s", name, w.Code, w.Body.String())
+		}
+		var resp ValidationErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+		got := map[string]string{}
+		for _, e := range resp.Errors {
+			got[e.Field] = e.Rule
+			if tc.message != "" && e.Field == "name" && e.Message != tc.message {
+				t.Errorf("%s: unexpected message %q", name, e.Message)
+			}
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: expected %v, got %v", name, tc.want, got)
+		}
+		for field, rule := range tc.want {
+			if got[field] != rule {
+				t.Errorf("%s: expected %s to fail %s, got %v", name, field, rule, got)
+			}
+		}
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                        "en",
+		"id-ID":                   "id",
+		"en-US,en;q=0.9,id;q=0.8": "en",
+		"de, id;q=0.5":            "id",
+		"id;q=0, en;q=0.1":        "en",
+	} {
+		if got := language(header); got != want {
+			t.Errorf("language(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Package entity defines all the entities used in the application.
+package entity
+
+import (
+	"time"
+)
+
+// Types of the custom attributes of a user.
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeNumber  = "number"
+	AttributeTypeBoolean = "boolean"
+	AttributeTypeDate    = "date"
+)
+
+// AttributeDefinition describes a custom attribute users of a tenant may hold.
+type AttributeDefinition struct {
+	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID  string    `bson:"tenant_id,omitempty" json:"-"`
+	Name      string    `bson:"name" json:"name" validate:"required,max=32"`
+	Type      string    `bson:"type" json:"type" validate:"required,enum=string number boolean date"`
+	Required  bool      `bson:"required" json:"required"`
+	Unique    bool      `bson:"unique" json:"unique"`   // no two users of the tenant hold the same value
+	Indexed   bool      `bson:"indexed" json:"indexed"` // filters and sorts on the attribute are backed by an index
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// Operators of an AttributeFilter.
+const (
+	FilterEqual              = "eq"
+	FilterNotEqual           = "ne"
+	FilterGreaterThan        = "gt"
+	FilterGreaterThanOrEqual = "gte"
+	FilterLessThan           = "lt"
+	FilterLessThanOrEqual    = "lte"
+)
+
+// AttributeFilter restricts a listing to the users whose attribute compares to Value.
+type AttributeFilter struct {
+	Name     string `json:"name"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"` // parsed as the type of the attribute
+}
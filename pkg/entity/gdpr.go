@@ -0,0 +1,26 @@
+// Package entity defines all the entities used in the application.
+package entity
+
+import (
+	"time"
+)
+
+// UserExport is the bundle of everything the service holds about a user.
+type UserExport struct {
+	UserID     string         `json:"user_id"`
+	Profile    *User          `json:"profile,omitempty"`
+	Revisions  []UserRevision `json:"revisions"`
+	Related    map[string]any `json:"related,omitempty"` // documents other components hold about the user, per collection
+	ExportedAt time.Time      `json:"exported_at"`
+}
+
+// ErasureReceipt is the proof that the personal data of a user was erased.
+type ErasureReceipt struct {
+	ID          string         `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID    string         `bson:"tenant_id,omitempty" json:"-"`
+	UserID      string         `bson:"user_id" json:"user_id"`
+	Collections map[string]int `bson:"collections" json:"collections"` // documents erased or anonymized per collection
+	Digest      string         `bson:"digest" json:"digest"`           // sha256 of the export bundle taken before erasure
+	ExportedAt  time.Time      `bson:"exported_at" json:"exported_at"` // exported_at of the hashed bundle, to recompute the digest
+	ErasedAt    time.Time      `bson:"erased_at" json:"erased_at"`
+}
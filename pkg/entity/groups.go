@@ -0,0 +1,37 @@
+// Package entity defines all the entities used in the application.
+package entity
+
+import (
+	"time"
+)
+
+// Group represents a group of users in the collection.
+type Group struct {
+	ID          string     `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID    string     `bson:"tenant_id,omitempty" json:"-"`
+	Name        string     `bson:"name,omitempty" json:"name,omitempty" validate:"required,min=3,max=100"`
+	Description string     `bson:"description,omitempty" json:"description,omitempty" validate:"max=500"`
+	Members     int        `bson:"members" json:"members"` // number of members, kept by the membership writes
+	CreatedAt   *time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
+}
+
+// GroupMember represents the membership of a user in a group.
+type GroupMember struct {
+	ID       string    `bson:"_id,omitempty" json:"-"`
+	TenantID string    `bson:"tenant_id,omitempty" json:"-"`
+	GroupID  string    `bson:"group_id" json:"group_id"`
+	UserID   string    `bson:"user_id" json:"user_id"`
+	JoinedAt time.Time `bson:"joined_at" json:"joined_at"`
+}
+
+// ResponseGetGroups represents a page of groups in the collection.
+type ResponseGetGroups struct {
+	Groups     []Group `json:"groups"`
+	Pagination `json:"pagination"`
+}
+
+// ResponseGetGroupMembers represents a page of the members of a group.
+type ResponseGetGroupMembers struct {
+	Members    []GroupMember `json:"members"`
+	Pagination `json:"pagination"`
+}
@@ -0,0 +1,24 @@
+// Package entity defines all the entities used in the application.
+package entity
+
+import (
+	"time"
+)
+
+// Idempotency record statuses.
+const (
+	IdempotencyProcessing = "processing"
+	IdempotencyCompleted  = "completed"
+)
+
+// IdempotencyRecord is the stored outcome of a request sent with an Idempotency-Key.
+type IdempotencyRecord struct {
+	Key         string    `bson:"_id"`
+	Fingerprint string    `bson:"fingerprint"`       // sha256 of method, path, Accept and body
+	Subject     string    `bson:"subject,omitempty"` // user the response is about, to forget it on erasure
+	Status      string    `bson:"status"`
+	StatusCode  int       `bson:"status_code,omitempty"`
+	ContentType string    `bson:"content_type,omitempty"`
+	Body        string    `bson:"body,omitempty"`
+	CreatedAt   time.Time `bson:"created_at"`
+}
@@ -0,0 +1,25 @@
+// Package entity defines all the entities used in the application.
+package entity
+
+import (
+	"time"
+)
+
+// Revision actions recorded on each UserRevision.
+const (
+	RevisionActionCreate = "create"
+	RevisionActionUpdate = "update"
+	RevisionActionDelete = "delete"
+	RevisionActionRevert = "revert"
+)
+
+// UserRevision represents a full snapshot of a user document at a point in time.
+type UserRevision struct {
+	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID  string    `bson:"tenant_id,omitempty" json:"-"`
+	UserID    string    `bson:"user_id" json:"user_id"`
+	Revision  int       `bson:"revision" json:"revision"`
+	Action    string    `bson:"action" json:"action"`
+	Document  User      `bson:"document" json:"document"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,52 @@
+// Package entity defines all the entities used in the application.
+package entity
+
+import (
+	"time"
+)
+
+// Intervals of the created counts of UserStats.
+const (
+	StatsIntervalDay  = "day"
+	StatsIntervalWeek = "week" // weeks start on monday
+)
+
+// RequestUserStats selects the users counted by UserStats and how they are bucketed.
+type RequestUserStats struct {
+	Interval string `json:"interval"`  // StatsIntervalDay or StatsIntervalWeek
+	TimeZone string `json:"time_zone"` // IANA name the days start in, UTC when empty
+	From     string `json:"from"`      // first day of created_at counted as YYYY-MM-DD, empty is unbounded
+	To       string `json:"to"`        // last day of created_at counted as YYYY-MM-DD, empty is unbounded
+	AgeWidth int    `json:"age_width"` // years of an age bucket
+	Domains  int    `json:"domains"`   // how many of the most used email domains are listed
+}
+
+// CreatedBucket counts the users created in the day or week starting at Start.
+type CreatedBucket struct {
+	Start time.Time `bson:"_id" json:"start"`
+	Count int       `bson:"count" json:"count"`
+}
+
+// AgeBucket counts the users aged From up to, but not including, To.
+type AgeBucket struct {
+	From  int `bson:"_id" json:"from"`
+	To    int `bson:"-" json:"to"`
+	Count int `bson:"count" json:"count"`
+}
+
+// DomainCount counts the users with an email of Domain.
+type DomainCount struct {
+	Domain string `bson:"_id" json:"domain"`
+	Count  int    `bson:"count" json:"count"`
+}
+
+// UserStats are the counts of the users for the dashboards.
+type UserStats struct {
+	Total       int             `json:"total"`
+	Interval    string          `json:"interval"`
+	TimeZone    string          `json:"time_zone"`
+	Created     []CreatedBucket `json:"created"`
+	Ages        []AgeBucket     `json:"ages"`
+	Domains     []DomainCount   `json:"domains"`
+	GeneratedAt time.Time       `json:"generated_at"` // cached stats are served until they expire
+}
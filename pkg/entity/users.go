@@ -7,20 +7,69 @@ import (
 
 // User represents a user in the collection.
 type User struct {
-	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
-	Name      string    `bson:"name,omitempty" json:"name,omitempty" validate:"required,min=3,max=100"`
-	Email     string    `bson:"email,omitempty" json:"email,omitempty" validate:"required,email"`
-	Age       int       `bson:"age,omitempty" json:"age,omitempty" validate:"required"`
-	CreatedAt time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	ID          string         `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID    string         `bson:"tenant_id,omitempty" json:"-"`
+	Name        string         `bson:"name,omitempty" json:"name,omitempty" validate:"required,min=3,max=100"`
+	Email       string         `bson:"email,omitempty" json:"email,omitempty" validate:"required,email"`
+	EmailIndex  string         `bson:"email_index,omitempty" json:"-"`
+	EmailDomain string         `bson:"email_domain,omitempty" json:"-"` // kept in clear when the stats count domains, the address stays sealed
+	Age         int            `bson:"age,omitempty" json:"age,omitempty" validate:"required"`
+	CreatedAt   *time.Time     `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	Attributes  map[string]any `bson:"attributes,omitempty" json:"attributes,omitempty"` // custom attributes, see AttributeDefinition
+	Unique      map[string]any `bson:"unique,omitempty" json:"-"`                        // copies of the attributes unique in the tenant, for their index
+	Search      *UserSearch    `bson:"search,omitempty" json:"-"`
+}
+
+// UserSearch holds the tokens a user is found by, the tokens of the email are blinded.
+type UserSearch struct {
+	Terms []string `bson:"terms"` // words, for the text index
+	Grams []string `bson:"grams"` // trigrams of the words, for typo tolerant matches
 }
 
 // RequestGetUsers represents a parameter to get user with pagination in the collection.
 type RequestGetUsers struct {
 	Pagination `json:"pagination"`
+	Fields     []string          `json:"fields,omitempty"`  // json names of the User fields to project, empty is all
+	Filters    []AttributeFilter `json:"filters,omitempty"` // every filter must match
+	Sort       []string          `json:"sort,omitempty"`    // User fields or attributes.<name>, descending when prefixed with -
+	Count      bool              `json:"-"`                 // count the users matching the filters, for the link to the last page
 }
 
 // ResponseGetUsers represents a parameter to get user with pagination in the collection.
 type ResponseGetUsers struct {
 	Users      []User `json:"users"`
 	Pagination `json:"pagination"`
+	Total      int64 `json:"total,omitempty"` // users matching the filters, when counted
+}
+
+// ReEncryptResult reports how many documents were re-encrypted by a key rotation.
+type ReEncryptResult struct {
+	Users     int `json:"users"`
+	Revisions int `json:"revisions"`
+}
+
+// RequestSearchUsers represents a parameter to search users with pagination in the collection.
+type RequestSearchUsers struct {
+	Query      string `json:"query"`
+	Pagination `json:"pagination"`
+}
+
+// Matches of a UserSearchResult.
+const (
+	SearchMatchText  = "text"  // every result matched words of the query
+	SearchMatchFuzzy = "fuzzy" // no user matched the words, results are similar to them
+)
+
+// UserSearchResult is a user found by a search.
+type UserSearchResult struct {
+	User       `bson:",inline"`
+	Score      float64           `bson:"score" json:"score"`
+	Match      string            `bson:"-" json:"match"`
+	Highlights map[string]string `bson:"-" json:"highlights,omitempty"` // matched fields, html escaped with the matches in <em>
+}
+
+// ResponseSearchUsers represents a page of users found by a search.
+type ResponseSearchUsers struct {
+	Results    []UserSearchResult `json:"results"`
+	Pagination `json:"pagination"`
 }
@@ -27,6 +27,7 @@ type Config struct {
 	} `yaml:"App"`
 	Server struct {
 		Timeout time.Duration `yaml:"timeout" env:"SERVER_TIMEOUT" env-description:"server timeout"`
+		Drain   time.Duration `yaml:"drain" env:"SERVER_DRAIN" env-description:"how long the servers keep serving once not ready, before they stop"`
 	} `yaml:"Server"`
 	Telemetry struct {
 		CollectorEnable   bool   `yaml:"collector_enable" env:"COLLECTOR_ENABLE" env-description:"exporter tracing monitoring"`
@@ -39,15 +40,131 @@ type Config struct {
 		MaxIdleCons       int `yaml:"max_idle_cons" env:"MAX_IDLE_CONS" env-description:"database max idle conn"`
 		ConnMaxLifetime   int `yaml:"conn_max_lifetime" env:"CONN_MAX_LIFETIME" env-description:"database max lifetime"`
 	} `yaml:"DB"`
-	UserDataMongo struct {
-		Protocol string `yaml:"protocol" env:"USERDATA_MONGO_PROTOCOL" env-description:"database protocol"`
-		Database string `yaml:"database" env:"USERDATA_MONGO_DATABASE" env-description:"database name"`
-		User     string `yaml:"user" env:"USERDATA_MONGO_USER" env-description:"database user"`
-		Password string `yaml:"password" env:"USERDATA_MONGO_PASSWORD" env-description:"database password"`
-		Host     string `yaml:"host" env:"USERDATA_MONGO_HOST" env-description:"database host"`
-		Port     uint16 `yaml:"port" env:"USERDATA_MONGO_PORT" env-description:"database port"`
-		Auth     bool   `yaml:"auth" env:"USERDATA_MONGO_AUTH" env-description:"database auth enabled"`
-	} `yaml:"UserDataMongo"`
+	UserDataMongo    Mongo                      `yaml:"UserDataMongo" env-prefix:"USERDATA_MONGO_"`
+	MongoConnections map[string]Mongo           `yaml:"MongoConnections"` // named connections besides UserDataMongo
+	Collections      map[string]MongoCollection `yaml:"Collections"`      // collection names used in code mapped to their connection and name
+
+	Reads struct {
+		Listing      string        `yaml:"listing" env:"READS_LISTING" env-description:"read preference of listings and exports, empty reads the connection default"`
+		MaxStaleness time.Duration `yaml:"max_staleness" env:"READS_MAX_STALENESS" env-description:"skip secondaries lagging more than this, at least 90s"`
+		Causal       bool          `yaml:"causal" env:"READS_CAUSAL" env-description:"read users by id with the listing preference, causally after their last write in this process or the X-Consistency-Token of the request"`
+		CausalWindow time.Duration `yaml:"causal_window" env:"READS_CAUSAL_WINDOW" env-description:"how long a write is tracked for read-your-writes"`
+	} `yaml:"Reads"`
+	Redis struct {
+		Host     string `yaml:"host" env:"REDIS_HOST" env-description:"redis host, empty to disable"`
+		Port     uint16 `yaml:"port" env:"REDIS_PORT" env-description:"redis port"`
+		User     string `yaml:"user" env:"REDIS_USER" env-description:"redis user"`
+		Password string `yaml:"password" env:"REDIS_PASSWORD" env-description:"redis password"`
+		DB       int    `yaml:"db" env:"REDIS_DB" env-description:"redis database number"`
+	} `yaml:"Redis"`
+	RateLimit struct {
+		Enable         bool                     `yaml:"enable" env:"RATE_LIMIT_ENABLE" env-description:"rate limit enabled"`
+		Backend        string                   `yaml:"backend" env:"RATE_LIMIT_BACKEND" env-description:"rate limit storage, memory or redis"`
+		KeyHeader      string                   `yaml:"key_header" env:"RATE_LIMIT_KEY_HEADER" env-description:"header carrying the client api key"`
+		TrustForwarded bool                     `yaml:"trust_forwarded" env:"RATE_LIMIT_TRUST_FORWARDED" env-description:"use X-Forwarded-For as client ip"`
+		Requests       int                      `yaml:"requests" env:"RATE_LIMIT_REQUESTS" env-description:"default requests per period"`
+		Period         time.Duration            `yaml:"period" env:"RATE_LIMIT_PERIOD" env-description:"default rate limit period"`
+		Burst          int                      `yaml:"burst" env:"RATE_LIMIT_BURST" env-description:"default bucket size"`
+		Routes         map[string]RateLimitRule `yaml:"routes"`  // keyed by "METHOD /route/{pattern}"
+		Clients        map[string]RateLimitRule `yaml:"clients"` // keyed by api key
+	} `yaml:"RateLimit"`
+	Idempotency struct {
+		TTL   time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" env-description:"how long responses are kept for Idempotency-Key replays"`
+		Lease time.Duration `yaml:"lease" env:"IDEMPOTENCY_LEASE" env-description:"how long a request holds its Idempotency-Key before a retry may take it over"`
+	} `yaml:"Idempotency"`
+	GraphQL struct {
+		MaxDepth      int `yaml:"max_depth" env:"GRAPHQL_MAX_DEPTH" env-description:"deepest nesting of fields in a graphql query"`
+		MaxComplexity int `yaml:"max_complexity" env:"GRAPHQL_MAX_COMPLEXITY" env-description:"most fields a graphql query may resolve, lists count every item of a page"`
+	} `yaml:"GraphQL"`
+	Stats struct {
+		CacheTTL  time.Duration `yaml:"cache_ttl" env:"STATS_CACHE_TTL" env-description:"how long user stats are served from cache"`
+		CacheSize int           `yaml:"cache_size" env:"STATS_CACHE_SIZE" env-description:"most user stats held in cache, the least recently used are dropped"`
+		Domains   bool          `yaml:"domains" env:"STATS_DOMAINS" env-description:"keep the email domains of the users in clear to count them"`
+	} `yaml:"Stats"`
+	Crypto struct {
+		KeyFile   string `yaml:"key_file" env:"CRYPTO_KEY_FILE" env-description:"json keyfile for field encryption"`
+		Keys      string `yaml:"keys" env:"CRYPTO_KEYS" env-description:"field encryption keys as comma separated id:base64"`
+		ActiveKey string `yaml:"active_key" env:"CRYPTO_ACTIVE_KEY" env-description:"key id used to encrypt new values"`
+		IndexKey  string `yaml:"index_key" env:"CRYPTO_INDEX_KEY" env-description:"base64 key for email blind index"`
+	} `yaml:"Crypto"`
+	TLS struct {
+		Enable       bool   `yaml:"enable" env:"TLS_ENABLE" env-description:"serve https on the https port"`
+		CertFile     string `yaml:"cert_file" env:"TLS_CERT_FILE" env-description:"pem certificate chain, reloaded on change"`
+		KeyFile      string `yaml:"key_file" env:"TLS_KEY_FILE" env-description:"pem private key, reloaded on change"`
+		ClientCAFile string `yaml:"client_ca_file" env:"TLS_CLIENT_CA_FILE" env-description:"pem ca bundle verifying client certificates, enables mtls"`
+		ClientAuth   string `yaml:"client_auth" env:"TLS_CLIENT_AUTH" env-description:"client certificate policy none, optional or require"`
+		Redirect     bool   `yaml:"redirect" env:"TLS_REDIRECT" env-description:"http port only redirects to https"`
+	} `yaml:"TLS"`
+	Tenancy struct {
+		Enable            bool     `yaml:"enable" env:"TENANCY_ENABLE" env-description:"scope users by the tenant of the request"`
+		Sources           []string `yaml:"sources" env:"TENANCY_SOURCES" env-separator:"," env-description:"tenant resolution order of header, jwt and subdomain"`
+		Header            string   `yaml:"header" env:"TENANCY_HEADER" env-description:"header carrying the tenant id"`
+		JWTClaim          string   `yaml:"jwt_claim" env:"TENANCY_JWT_CLAIM" env-description:"bearer token claim carrying the tenant id"`
+		JWTSecret         string   `yaml:"jwt_secret" env:"TENANCY_JWT_SECRET" env-description:"HS256 secret verifying bearer tokens, every request then needs one"`
+		BaseDomain        string   `yaml:"base_domain" env:"TENANCY_BASE_DOMAIN" env-description:"the tenant is the subdomain of this domain"`
+		Default           string   `yaml:"default" env:"TENANCY_DEFAULT" env-description:"tenant of requests without one, empty rejects them"`
+		TrustedProxies    []string `yaml:"trusted_proxies" env:"TENANCY_TRUSTED_PROXIES" env-separator:"," env-description:"IPs or CIDRs of the gateways whose tenant header and host are trusted without a token"`
+		Exempt            []string `yaml:"exempt"` // paths served without a tenant
+		DatabasePerTenant bool     `yaml:"database_per_tenant" env:"TENANCY_DATABASE_PER_TENANT" env-description:"keep every tenant in its own database"`
+		DatabasePrefix    string   `yaml:"database_prefix" env:"TENANCY_DATABASE_PREFIX" env-description:"prefix of the tenant database names"`
+	} `yaml:"Tenancy"`
+}
+
+// Mongo is the connection config of a mongodb server.
+type Mongo struct {
+	Protocol string `yaml:"protocol" env:"PROTOCOL" env-description:"database protocol"`
+	Database string `yaml:"database" env:"DATABASE" env-description:"database name"`
+	User     string `yaml:"user" env:"USER" env-description:"database user"`
+	Password string `yaml:"password" env:"PASSWORD" env-description:"database password"`
+	Host     string `yaml:"host" env:"HOST" env-description:"database host"`
+	Port     uint16 `yaml:"port" env:"PORT" env-description:"database port"`
+	Auth     bool   `yaml:"auth" env:"AUTH" env-description:"database auth enabled"`
+	URI      string `yaml:"uri" env:"URI" env-description:"full connection string, replaces protocol, host and port"`
+	AppName  string `yaml:"app_name" env:"APP_NAME" env-description:"application name sent to the server"`
+
+	ReplicaSet    string `yaml:"replica_set" env:"REPLICA_SET" env-description:"replica set name"`
+	AuthSource    string `yaml:"auth_source" env:"AUTH_SOURCE" env-description:"database holding the user credentials"`
+	AuthMechanism string `yaml:"auth_mechanism" env:"AUTH_MECHANISM" env-description:"SCRAM-SHA-256, SCRAM-SHA-1, MONGODB-X509 or MONGODB-AWS"`
+
+	TLS            bool   `yaml:"tls" env:"TLS" env-description:"connect with tls"`
+	TLSCAFile      string `yaml:"tls_ca_file" env:"TLS_CA_FILE" env-description:"pem ca bundle verifying the server"`
+	TLSCertKeyFile string `yaml:"tls_cert_key_file" env:"TLS_CERT_KEY_FILE" env-description:"pem client certificate and key"`
+	TLSInsecure    bool   `yaml:"tls_insecure" env:"TLS_INSECURE" env-description:"skip server certificate verification"`
+
+	MinPoolSize     uint64        `yaml:"min_pool_size" env:"MIN_POOL_SIZE" env-description:"minimum connections per server"`
+	MaxPoolSize     uint64        `yaml:"max_pool_size" env:"MAX_POOL_SIZE" env-description:"maximum connections per server"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time" env:"MAX_CONN_IDLE_TIME" env-description:"idle time before a connection is closed"`
+
+	ConnectTimeout         time.Duration `yaml:"connect_timeout" env:"CONNECT_TIMEOUT" env-description:"timeout of a new connection"`
+	ServerSelectionTimeout time.Duration `yaml:"server_selection_timeout" env:"SERVER_SELECTION_TIMEOUT" env-description:"timeout to find a suitable server"`
+	SocketTimeout          time.Duration `yaml:"socket_timeout" env:"SOCKET_TIMEOUT" env-description:"timeout of socket reads and writes"`
+
+	ReadPreference string        `yaml:"read_preference" env:"READ_PREFERENCE" env-description:"primary, primaryPreferred, secondary, secondaryPreferred or nearest"`
+	ReadConcern    string        `yaml:"read_concern" env:"READ_CONCERN" env-description:"local, available, majority, linearizable or snapshot"`
+	WriteConcern   string        `yaml:"write_concern" env:"WRITE_CONCERN" env-description:"majority, a number of nodes or a tag set"`
+	WriteTimeout   time.Duration `yaml:"write_timeout" env:"WRITE_TIMEOUT" env-description:"write concern timeout"`
+	Journal        bool          `yaml:"journal" env:"JOURNAL" env-description:"acknowledge writes after the journal"`
+
+	Compressors []string `yaml:"compressors" env:"COMPRESSORS" env-separator:"," env-description:"snappy, zlib or zstd"`
+
+	StartupRetries    uint64        `yaml:"startup_retries" env:"STARTUP_RETRIES" env-description:"ping retries before giving up at startup"`
+	StartupBackoff    time.Duration `yaml:"startup_backoff" env:"STARTUP_BACKOFF" env-description:"first retry interval, doubled on every retry"`
+	StartupMaxBackoff time.Duration `yaml:"startup_max_backoff" env:"STARTUP_MAX_BACKOFF" env-description:"longest retry interval"`
+	StartDegraded     bool          `yaml:"start_degraded" env:"START_DEGRADED" env-description:"start while mongo is down, readiness stays false until it is up"`
+	MonitorInterval   time.Duration `yaml:"monitor_interval" env:"MONITOR_INTERVAL" env-description:"ping interval of the reconnect monitor, zero disables it"`
+}
+
+// MongoCollection places a collection on a named connection.
+type MongoCollection struct {
+	Connection string `yaml:"connection"` // key of MongoConnections, empty is UserDataMongo
+	Name       string `yaml:"name"`
+}
+
+// RateLimitRule is a token bucket quota of the RateLimit config.
+type RateLimitRule struct {
+	Requests int           `yaml:"requests"`
+	Period   time.Duration `yaml:"period"`
+	Burst    int           `yaml:"burst"`
 }
 
 var (
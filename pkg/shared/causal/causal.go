@@ -0,0 +1,69 @@
+// Package causal carries the write tokens of read-your-writes between a client and
+// any replica of the service, a process only knows the writes it made itself.
+package causal
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Header is the http header, and the grpc metadata key, carrying a write token: sent
+// back after a write, a client passes it on its next reads to observe that write.
+const Header = "X-Consistency-Token"
+
+// ErrInvalidToken is returned when a write token cannot be parsed.
+var ErrInvalidToken = errors.New("consistency token is invalid")
+
+type (
+	ctxTokenKey    struct{}
+	ctxRecorderKey struct{}
+)
+
+// Format encodes the operation time of a write as a token.
+func Format(ts primitive.Timestamp) string {
+	return strconv.FormatUint(uint64(ts.T), 10) + "." + strconv.FormatUint(uint64(ts.I), 10)
+}
+
+// Parse decodes a token of Format.
+func Parse(token string) (primitive.Timestamp, error) {
+	t, i, ok := strings.Cut(token, ".")
+	if !ok {
+		return primitive.Timestamp{}, ErrInvalidToken
+	}
+	seconds, err := strconv.ParseUint(t, 10, 32)
+	if err != nil || seconds == 0 {
+		return primitive.Timestamp{}, ErrInvalidToken
+	}
+	increment, err := strconv.ParseUint(i, 10, 32)
+	if err != nil {
+		return primitive.Timestamp{}, ErrInvalidToken
+	}
+	return primitive.Timestamp{T: uint32(seconds), I: uint32(increment)}, nil
+}
+
+// WithToken returns a copy of ctx reading after the write of the token.
+func WithToken(ctx context.Context, ts primitive.Timestamp) context.Context {
+	return context.WithValue(ctx, ctxTokenKey{}, ts)
+}
+
+// FromContext returns the write token of ctx.
+func FromContext(ctx context.Context) (primitive.Timestamp, bool) {
+	ts, ok := ctx.Value(ctxTokenKey{}).(primitive.Timestamp)
+	return ts, ok
+}
+
+// WithRecorder returns a copy of ctx in which the writes hand their token to record.
+func WithRecorder(ctx context.Context, record func(token string)) context.Context {
+	return context.WithValue(ctx, ctxRecorderKey{}, record)
+}
+
+// Record hands the operation time of a write to the recorder of ctx, if any.
+func Record(ctx context.Context, ts primitive.Timestamp) {
+	if record, ok := ctx.Value(ctxRecorderKey{}).(func(string)); ok {
+		record(Format(ts))
+	}
+}
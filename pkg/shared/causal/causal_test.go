@@ -0,0 +1,30 @@
+// Package causal carries the write tokens of read-your-writes between a client and
+// any replica of the service, a process only knows the writes it made itself.
+package causal
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestToken(t *testing.T) {
+	ts := primitive.Timestamp{T: 1697712345, I: 3}
+	if got, err := Parse(Format(ts)); err != nil || got != ts {
+		t.Fatalf("expected %v, got %v %v", ts, got, err)
+	}
+	for _, token := range []string{"", "1697712345", "0.1", "a.b", "1.-1", "99999999999.1"} {
+		if _, err := Parse(token); err == nil {
+			t.Errorf("expected %q to be invalid", token)
+		}
+	}
+
+	var recorded string
+	ctx := WithRecorder(context.Background(), func(token string) { recorded = token })
+	Record(ctx, ts)
+	Record(context.Background(), ts) // without a recorder the token is dropped
+	if recorded != "1697712345.3" {
+		t.Fatalf("expected the token to be recorded, got %q", recorded)
+	}
+}
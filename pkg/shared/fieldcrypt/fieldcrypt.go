@@ -0,0 +1,180 @@
+// Package fieldcrypt implements field-level encryption for personal data.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// prefix marks an encrypted value, the key id and payload follow it.
+const prefix = "enc:v1:"
+
+var (
+	// ErrUnknownKey is returned when a value was sealed with a key that is not in the keyring.
+	ErrUnknownKey = errors.New("fieldcrypt: unknown key id")
+	// ErrMalformed is returned when an encrypted value cannot be parsed.
+	ErrMalformed = errors.New("fieldcrypt: malformed value")
+)
+
+// Options is the source of the key material.
+type Options struct {
+	KeyFile   string // path to a json keyfile
+	Keys      string // comma separated id:base64 pairs, merged over the keyfile
+	ActiveKey string // key id used to seal new values
+	IndexKey  string // base64 key of the blind index
+}
+
+// keyFile is the json layout of Options.KeyFile.
+type keyFile struct {
+	ActiveKey string            `json:"active_key"`
+	IndexKey  string            `json:"index_key"`
+	Keys      map[string]string `json:"keys"`
+}
+
+// Keyring seals values with AES-GCM and derives deterministic blind indexes.
+// A nil Keyring is valid and keeps values in plaintext.
+type Keyring struct {
+	active   string
+	keys     map[string]cipher.AEAD
+	indexKey []byte
+}
+
+// Load builds a Keyring from a keyfile and/or inline keys.
+// It returns a nil Keyring when no key is configured.
+func Load(opt Options) (*Keyring, error) {
+	file := keyFile{Keys: map[string]string{}}
+	if opt.KeyFile != "" {
+		b, err := os.ReadFile(opt.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: read keyfile: %w", err)
+		}
+		if err = json.Unmarshal(b, &file); err != nil {
+			return nil, fmt.Errorf("fieldcrypt: parse keyfile: %w", err)
+		}
+	}
+	for _, pair := range strings.Split(opt.Keys, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		id, key, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("fieldcrypt: key %q must be formatted as id:base64", pair)
+		}
+		if file.Keys == nil {
+			file.Keys = map[string]string{}
+		}
+		file.Keys[id] = key
+	}
+	if opt.ActiveKey != "" {
+		file.ActiveKey = opt.ActiveKey
+	}
+	if opt.IndexKey != "" {
+		file.IndexKey = opt.IndexKey
+	}
+	if len(file.Keys) == 0 {
+		return nil, nil
+	}
+
+	kr := &Keyring{active: file.ActiveKey, keys: map[string]cipher.AEAD{}}
+	for id, encoded := range file.Keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("fieldcrypt: invalid key id %q", id)
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: decode key %q: %w", id, err)
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: key %q: %w", id, err)
+		}
+		if kr.keys[id], err = cipher.NewGCM(block); err != nil {
+			return nil, fmt.Errorf("fieldcrypt: key %q: %w", id, err)
+		}
+	}
+	if _, ok := kr.keys[kr.active]; !ok {
+		return nil, fmt.Errorf("fieldcrypt: active key %q is not in the keyring", kr.active)
+	}
+	indexKey, err := base64.StdEncoding.DecodeString(file.IndexKey)
+	if err != nil || len(indexKey) < 16 {
+		return nil, errors.New("fieldcrypt: index key must be at least 16 base64 encoded bytes")
+	}
+	kr.indexKey = indexKey
+	return kr, nil
+}
+
+// Enabled reports whether values are encrypted.
+func (k *Keyring) Enabled() bool {
+	return k != nil
+}
+
+// Encrypt seals a value with the active key.
+func (k *Keyring) Encrypt(plain string) (string, error) {
+	if k == nil || plain == "" {
+		return plain, nil
+	}
+	aead := k.keys[k.active]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), []byte(k.active))
+	return prefix + k.active + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a sealed value with the key it was sealed with.
+// Values without the encryption prefix are returned as is.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, prefix) {
+		return value, nil
+	}
+	if k == nil {
+		return "", ErrUnknownKey
+	}
+	id, payload, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return "", ErrMalformed
+	}
+	aead, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrMalformed
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("fieldcrypt: open value: %w", err)
+	}
+	return string(plain), nil
+}
+
+// NeedsRotation reports whether a value is plaintext or sealed with a retired key.
+func (k *Keyring) NeedsRotation(value string) bool {
+	if k == nil || value == "" {
+		return false
+	}
+	return !strings.HasPrefix(value, prefix+k.active+":")
+}
+
+// BlindIndex returns a deterministic token of the normalized value for equality lookups.
+func (k *Keyring) BlindIndex(value string) string {
+	normalized := strings.ToLower(strings.TrimSpace(value))
+	if k == nil || normalized == "" {
+		return normalized
+	}
+	mac := hmac.New(sha256.New, k.indexKey)
+	_, _ = mac.Write([]byte(normalized))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,62 @@
+// Package fieldcrypt implements field-level encryption for personal data.
+package fieldcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestKeyringRotation(t *testing.T) {
+	old, err := Load(Options{Keys: "k1:" + testKey(1), ActiveKey: "k1", IndexKey: testKey(9)})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	sealed, err := old.Encrypt("John@Example.com")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if sealed == "John@Example.com" {
+		t.Fatal("value was not encrypted")
+	}
+
+	rotated, err := Load(Options{
+		Keys:      "k1:" + testKey(1) + ",k2:" + testKey(2),
+		ActiveKey: "k2",
+		IndexKey:  testKey(9),
+	})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !rotated.NeedsRotation(sealed) {
+		t.Fatal("value sealed with k1 should need rotation")
+	}
+	plain, err := rotated.Decrypt(sealed)
+	if err != nil || plain != "John@Example.com" {
+		t.Fatalf("decrypt: %q %v", plain, err)
+	}
+	if old.BlindIndex("john@example.com ") != rotated.BlindIndex("JOHN@example.com") {
+		t.Fatal("blind index must be deterministic over normalized values")
+	}
+
+	retired, _ := Load(Options{Keys: "k2:" + testKey(2), ActiveKey: "k2", IndexKey: testKey(9)})
+	if _, err = retired.Decrypt(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestKeyringDisabled(t *testing.T) {
+	kr, err := Load(Options{})
+	if err != nil || kr.Enabled() {
+		t.Fatalf("expected disabled keyring, got %v %v", kr, err)
+	}
+	sealed, _ := kr.Encrypt("john@example.com")
+	if sealed != "john@example.com" || kr.BlindIndex(" John@example.com") != "john@example.com" {
+		t.Fatal("disabled keyring must keep values in plaintext")
+	}
+}
@@ -0,0 +1,58 @@
+// Package metrics exposes the prometheus collectors of the service.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every collector served by Handler.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests counts the served requests by route and status code.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of http requests by route and status code.",
+	}, []string{"method", "route", "code"})
+	// HTTPDuration observes the latency of the served requests by route.
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of http requests by route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	// HTTPInFlight is the number of requests being served.
+	HTTPInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "Number of http requests being served.",
+	})
+
+	// UsersCreated counts the created users.
+	UsersCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "users_created_total",
+		Help: "Number of created users.",
+	})
+	// UsersDeleted counts the deleted users by reason, delete or erase.
+	UsersDeleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "users_deleted_total",
+		Help: "Number of deleted users by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPDuration, HTTPInFlight,
+		mongoCommands, mongoPoolConnections, mongoPoolInUse, mongoPoolCheckoutFailures,
+		UsersCreated, UsersDeleted,
+	)
+}
+
+// Handler serves the registry in the prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,61 @@
+// Package metrics exposes the prometheus collectors of the service.
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	mongoCommands = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongodb_command_duration_seconds",
+		Help:    "Latency of mongodb commands by command name and status.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"command", "status"})
+	mongoPoolConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mongodb_pool_connections",
+		Help: "Number of open connections in the pool by server address.",
+	}, []string{"address"})
+	mongoPoolInUse = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mongodb_pool_connections_in_use",
+		Help: "Number of checked out connections by server address.",
+	}, []string{"address"})
+	mongoPoolCheckoutFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongodb_pool_checkout_failures_total",
+		Help: "Number of failed connection checkouts by server address and reason.",
+	}, []string{"address", "reason"})
+)
+
+// CommandMonitor observes the latency of every mongodb command.
+func CommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			mongoCommands.WithLabelValues(e.CommandName, "ok").Observe(e.Duration.Seconds())
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			mongoCommands.WithLabelValues(e.CommandName, "error").Observe(e.Duration.Seconds())
+		},
+	}
+}
+
+// PoolMonitor tracks the connection pool of every server.
+func PoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.ConnectionCreated:
+				mongoPoolConnections.WithLabelValues(e.Address).Inc()
+			case event.ConnectionClosed:
+				mongoPoolConnections.WithLabelValues(e.Address).Dec()
+			case event.GetSucceeded:
+				mongoPoolInUse.WithLabelValues(e.Address).Inc()
+			case event.ConnectionReturned:
+				mongoPoolInUse.WithLabelValues(e.Address).Dec()
+			case event.GetFailed:
+				mongoPoolCheckoutFailures.WithLabelValues(e.Address, e.Reason).Inc()
+			}
+		},
+	}
+}
@@ -0,0 +1,139 @@
+// Package mongotrace emits OpenTelemetry spans for mongodb commands.
+package mongotrace
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxStatement bounds db.statement, bulk inserts would otherwise carry every document.
+const maxStatement = 2048
+
+// ignored are the command fields of the driver itself, they are left out of db.statement.
+var ignored = map[string]bool{
+	"lsid": true, "$clusterTime": true, "$db": true, "txnNumber": true,
+	"$readPreference": true, "autocommit": true, "startTransaction": true,
+}
+
+type spanKey struct {
+	conn string
+	id   int64
+}
+
+// Monitor returns a command monitor starting a client span per command.
+// Commands without a parent span are not traced.
+func Monitor() *event.CommandMonitor {
+	var (
+		tracer = otel.Tracer("go.mongodb.org/mongo-driver")
+		spans  sync.Map
+	)
+	end := func(e event.CommandFinishedEvent, failure string) {
+		v, ok := spans.LoadAndDelete(spanKey{conn: e.ConnectionID, id: e.RequestID})
+		if !ok {
+			return
+		}
+		span := v.(trace.Span)
+		if failure != "" {
+			span.RecordError(errors.New(failure))
+			span.SetStatus(codes.Error, failure)
+		}
+		span.End()
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			if !trace.SpanContextFromContext(ctx).IsValid() {
+				return
+			}
+			collection := Collection(e.Command, e.CommandName)
+			name := e.CommandName
+			if collection != "" {
+				name += " " + collection
+			}
+			_, span := tracer.Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemMongoDB,
+					semconv.DBName(e.DatabaseName),
+					semconv.DBOperation(e.CommandName),
+					semconv.DBMongoDBCollection(collection),
+					semconv.DBStatement(Statement(e.Command)),
+				))
+			spans.Store(spanKey{conn: e.ConnectionID, id: e.RequestID}, span)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			end(e.CommandFinishedEvent, "")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			end(e.CommandFinishedEvent, e.Failure)
+		},
+	}
+}
+
+// Collection returns the collection a command runs on, it is the value of the command name field.
+func Collection(command bson.Raw, name string) string {
+	collection, ok := command.Lookup(name).StringValueOK()
+	if !ok {
+		return ""
+	}
+	return collection
+}
+
+// Statement renders the command as extended json with every value replaced by "?",
+// so that personal data never leaves the process.
+func Statement(command bson.Raw) string {
+	elems, err := command.Elements()
+	if err != nil {
+		return ""
+	}
+	doc := bson.D{}
+	for idx, elem := range elems {
+		key := elem.Key()
+		switch {
+		case ignored[key]:
+			continue
+		case idx == 0 && elem.Value().Type == bsontype.String:
+			// the command name holds the collection.
+			doc = append(doc, bson.E{Key: key, Value: elem.Value().StringValue()})
+		default:
+			doc = append(doc, bson.E{Key: key, Value: sanitize(elem.Value())})
+		}
+	}
+	b, err := bson.MarshalExtJSON(doc, false, false)
+	if err != nil {
+		return ""
+	}
+	if len(b) > maxStatement {
+		return string(b[:maxStatement])
+	}
+	return string(b)
+}
+
+func sanitize(v bson.RawValue) any {
+	switch v.Type {
+	case bsontype.EmbeddedDocument:
+		elems, _ := v.Document().Elements()
+		doc := bson.D{}
+		for _, elem := range elems {
+			doc = append(doc, bson.E{Key: elem.Key(), Value: sanitize(elem.Value())})
+		}
+		return doc
+	case bsontype.Array:
+		values, _ := v.Array().Values()
+		arr := bson.A{}
+		for _, value := range values {
+			arr = append(arr, sanitize(value))
+		}
+		return arr
+	default:
+		return "?"
+	}
+}
@@ -0,0 +1,32 @@
+// Package mongotrace emits OpenTelemetry spans for mongodb commands.
+package mongotrace
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestStatement(t *testing.T) {
+	command, err := bson.Marshal(bson.D{
+		{Key: "find", Value: "users"},
+		{Key: "filter", Value: bson.D{
+			{Key: "email_index", Value: "john@example.com"},
+			{Key: "age", Value: bson.D{{Key: "$in", Value: bson.A{21, 22}}}},
+		}},
+		{Key: "limit", Value: 10},
+		{Key: "lsid", Value: bson.D{{Key: "id", Value: "session"}}},
+		{Key: "$db", Value: "ymir"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Collection(command, "find"); got != "users" {
+		t.Fatalf("expected users, got %s", got)
+	}
+	want := `{"find":"users","filter":{"email_index":"?","age":{"$in":["?","?"]}},"limit":"?"}`
+	if got := Statement(command); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
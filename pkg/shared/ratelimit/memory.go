@@ -0,0 +1,70 @@
+// Package ratelimit implements token bucket rate limiting with pluggable storage.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle buckets are dropped from memory.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rule   Rule
+}
+
+// Memory keeps buckets in process, suited for a single instance.
+type Memory struct {
+	m       sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+// NewMemory creates an in-memory Limiter.
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow implements Limiter.
+func (m *Memory) Allow(_ context.Context, key string, rule Rule) (Decision, error) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: rule.capacity(), last: now}
+		m.buckets[key] = b
+	}
+	b.tokens = refill(b.tokens, now.Sub(b.last), rule)
+	b.last = now
+	b.rule = rule
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return decide(allowed, b.tokens, rule), nil
+}
+
+// sweep drops buckets that are full again, they behave the same as a new bucket.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.swept) < sweepInterval {
+		return
+	}
+	m.swept = now
+	for key, b := range m.buckets {
+		if refill(b.tokens, now.Sub(b.last), b.rule) >= b.rule.capacity() {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Package ratelimit implements token bucket rate limiting with pluggable storage.
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryAllow(t *testing.T) {
+	var (
+		now  = time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+		m    = NewMemory()
+		rule = Rule{Requests: 2, Period: time.Second}
+		ctx  = context.Background()
+	)
+	m.now = func() time.Time { return now }
+
+	for n := 0; n < 2; n++ {
+		d, _ := m.Allow(ctx, "client", rule)
+		if !d.Allowed {
+			t.Fatalf("request %d should be allowed", n)
+		}
+	}
+	d, _ := m.Allow(ctx, "client", rule)
+	if d.Allowed || d.Remaining != 0 || d.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("expected denial with retry after 500ms, got %+v", d)
+	}
+	if other, _ := m.Allow(ctx, "other", rule); !other.Allowed {
+		t.Fatal("buckets must be isolated per key")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if d, _ = m.Allow(ctx, "client", rule); !d.Allowed {
+		t.Fatalf("token should be refilled, got %+v", d)
+	}
+
+	now = now.Add(2 * sweepInterval)
+	_, _ = m.Allow(ctx, "fresh", rule)
+	if _, ok := m.buckets["client"]; ok {
+		t.Fatal("idle bucket should be swept")
+	}
+}
@@ -0,0 +1,88 @@
+// Package ratelimit implements token bucket rate limiting with pluggable storage.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Rule is a token bucket quota, Requests tokens are refilled every Period.
+type Rule struct {
+	Requests int
+	Period   time.Duration
+	Burst    int // bucket capacity, defaults to Requests
+}
+
+// capacity returns the size of the bucket.
+func (r Rule) capacity() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return float64(r.Requests)
+}
+
+// rate returns the refilled tokens per nanosecond.
+func (r Rule) rate() float64 {
+	return float64(r.Requests) / float64(r.Period)
+}
+
+// Valid reports whether the rule limits anything.
+func (r Rule) Valid() bool {
+	return r.Requests > 0 && r.Period > 0
+}
+
+// Stricter returns the rule letting the fewest requests through: the lower rate, then
+// the smaller bucket. A rule that is not Valid limits nothing and loses.
+func (r Rule) Stricter(other Rule) Rule {
+	switch {
+	case !other.Valid():
+		return r
+	case !r.Valid():
+		return other
+	case r.rate() != other.rate():
+		if r.rate() < other.rate() {
+			return r
+		}
+		return other
+	case other.capacity() < r.capacity():
+		return other
+	}
+	return r
+}
+
+// Decision is the outcome of taking a token from a bucket.
+type Decision struct {
+	Allowed    bool
+	Limit      int           // bucket capacity
+	Remaining  int           // tokens left after this request
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token is available, set when not allowed
+}
+
+// Limiter takes one token from the bucket identified by key.
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule Rule) (Decision, error)
+}
+
+// refill returns the tokens of a bucket after elapsed time.
+func refill(tokens float64, elapsed time.Duration, rule Rule) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(rule.capacity(), tokens+float64(elapsed)*rule.rate())
+}
+
+// decide builds the decision from the tokens left in the bucket.
+func decide(allowed bool, tokens float64, rule Rule) Decision {
+	d := Decision{
+		Allowed:   allowed,
+		Limit:     int(rule.capacity()),
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration(math.Ceil((rule.capacity() - tokens) / rule.rate())),
+	}
+	if !allowed {
+		d.RetryAfter = time.Duration(math.Ceil((1 - tokens) / rule.rate()))
+	}
+	return d
+}
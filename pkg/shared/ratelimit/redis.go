@@ -0,0 +1,70 @@
+// Package ratelimit implements token bucket rate limiting with pluggable storage.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucket refills and takes a token atomically, using the redis clock so
+// that every instance shares the same view of time.
+var tokenBucket = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = capacity
+  ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], ttl)
+return {allowed, tostring(tokens)}
+`)
+
+// Redis keeps buckets in redis so that all instances share the same quota.
+type Redis struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedis creates a redis backed Limiter, keys are stored under prefix.
+func NewRedis(client redis.Scripter, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+// Allow implements Limiter.
+func (r *Redis) Allow(ctx context.Context, key string, rule Rule) (Decision, error) {
+	var (
+		ratePerMs = rule.rate() * 1e6
+		ttlMs     = int64(rule.capacity()/ratePerMs) + 1000
+	)
+	res, err := tokenBucket.Run(ctx, r.client, []string{r.prefix + key},
+		rule.capacity(), ratePerMs, ttlMs).Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	if len(res) != 2 {
+		return Decision{}, fmt.Errorf("ratelimit: unexpected redis reply %v", res)
+	}
+	allowed, _ := res[0].(int64)
+	raw, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return Decision{}, fmt.Errorf("ratelimit: parse tokens: %w", err)
+	}
+	return decide(allowed == 1, tokens, rule), nil
+}
@@ -0,0 +1,50 @@
+// Package ratelimit implements token bucket rate limiting with pluggable storage.
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisAllow(t *testing.T) {
+	var (
+		s    = miniredis.RunT(t)
+		r    = NewRedis(redis.NewClient(&redis.Options{Addr: s.Addr()}), "test:ratelimit:")
+		rule = Rule{Requests: 2, Period: time.Second}
+		ctx  = context.Background()
+	)
+	// the script reads the redis clock, which stands still unless it is set.
+	now := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	s.SetTime(now)
+
+	for n := 0; n < 2; n++ {
+		d, err := r.Allow(ctx, "client", rule)
+		if err != nil || !d.Allowed {
+			t.Fatalf("request %d should be allowed, got %+v %v", n, d, err)
+		}
+	}
+	d, err := r.Allow(ctx, "client", rule)
+	if err != nil || d.Allowed || d.Remaining != 0 || d.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("expected denial with retry after 500ms, got %+v %v", d, err)
+	}
+	if other, _ := r.Allow(ctx, "other", rule); !other.Allowed {
+		t.Fatal("buckets must be isolated per key")
+	}
+	if ttl := s.TTL("test:ratelimit:client"); ttl <= 0 {
+		t.Fatalf("expected the bucket to expire, got ttl %v", ttl)
+	}
+
+	s.SetTime(now.Add(500 * time.Millisecond))
+	if d, err = r.Allow(ctx, "client", rule); err != nil || !d.Allowed {
+		t.Fatalf("token should be refilled, got %+v %v", d, err)
+	}
+
+	s.Close()
+	if _, err = r.Allow(ctx, "client", rule); err == nil {
+		t.Fatal("expected an error when redis is down")
+	}
+}
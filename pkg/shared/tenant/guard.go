@@ -0,0 +1,42 @@
+// Package tenant carries the tenant of a request in its context.
+package tenant
+
+import (
+	"context"
+	"sync"
+)
+
+// Guard checks the tenant of the requests of a component. When every tenant has its
+// own database, the database of a tenant is migrated on its first request.
+type Guard struct {
+	Required bool                            // requests without a tenant are rejected
+	Migrate  func(ctx context.Context) error // migrates the database of the tenant of ctx, nil when shared
+
+	migrated sync.Map // ids of the tenants with a migrated database
+}
+
+// Check returns ErrRequired or ErrInvalid for the tenant of ctx, or the error of
+// migrating its database.
+func (g *Guard) Check(ctx context.Context) error {
+	id := FromContext(ctx)
+	if id == "" {
+		if g.Required {
+			return ErrRequired
+		}
+		return nil
+	}
+	if !Valid(id) {
+		return ErrInvalid
+	}
+	if g.Migrate == nil {
+		return nil
+	}
+	if _, ok := g.migrated.Load(id); ok {
+		return nil
+	}
+	if err := g.Migrate(ctx); err != nil {
+		return err
+	}
+	g.migrated.Store(id, struct{}{})
+	return nil
+}
@@ -0,0 +1,40 @@
+// Package tenant carries the tenant of a request in its context.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGuard(t *testing.T) {
+	var migrations int
+	guard := &Guard{Required: true, Migrate: func(ctx context.Context) error {
+		migrations++
+		return nil
+	}}
+	if err := guard.Check(context.Background()); !errors.Is(err, ErrRequired) {
+		t.Fatalf("expected ErrRequired, got %v", err)
+	}
+	if err := guard.Check(WithID(context.Background(), "ACME")); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid, got %v", err)
+	}
+	for n := 0; n < 2; n++ {
+		if err := guard.Check(WithID(context.Background(), "acme")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if migrations != 1 {
+		t.Fatalf("expected the database of acme to be migrated once, got %d", migrations)
+	}
+
+	failing := &Guard{Migrate: func(ctx context.Context) error { return errors.New("down") }}
+	if err := failing.Check(context.Background()); err != nil {
+		t.Fatalf("expected no tenant to be allowed, got %v", err)
+	}
+	for n := 0; n < 2; n++ {
+		if err := failing.Check(WithID(context.Background(), "acme")); err == nil {
+			t.Fatal("expected the failed migration to be retried")
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package tenant carries the tenant of a request in its context.
+package tenant
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrConflict is returned when the sources of a request name different tenants.
+	ErrConflict = errors.New("request names more than one tenant")
+	// ErrInvalidToken is returned when the bearer token cannot be verified.
+	ErrInvalidToken = errors.New("bearer token is invalid")
+	// ErrTokenRequired is returned when tokens are verified and the request has none.
+	ErrTokenRequired = errors.New("bearer token is required")
+	// ErrUntrusted is returned when a tenant is named by a peer that is not a trusted proxy.
+	ErrUntrusted = errors.New("tenant can only be named by a trusted proxy")
+)
+
+// Resolver decides which tenant a request runs as. With a secret every request needs
+// a verified bearer token, its claim is the tenant and the other sources may only
+// repeat it. Without a secret the tenant named by the request is only trusted from a
+// proxy, the gateway in front of the service that strips it from client requests.
+type Resolver struct {
+	secret   []byte // HS256 secret of the bearer tokens
+	claim    string
+	fallback string
+	proxies  []*net.IPNet
+}
+
+// NewResolver returns the resolver of the config, proxies are IPs or CIDRs.
+func NewResolver(secret, claim, fallback string, proxies []string) (Resolver, error) {
+	r := Resolver{secret: []byte(secret), claim: claim, fallback: fallback}
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return Resolver{}, fmt.Errorf("trusted proxy %q: %w", proxy, err)
+		}
+		r.proxies = append(r.proxies, network)
+	}
+	return r, nil
+}
+
+// Resolve returns the tenant of a request from its bearer token, the address of its
+// peer and the tenants it names otherwise, like its header or its subdomain.
+func (r Resolver) Resolve(token, peer string, named ...string) (string, error) {
+	var id string
+	if len(r.secret) > 0 {
+		if token == "" {
+			return "", ErrTokenRequired
+		}
+		var err error
+		if id, err = r.verify(token); err != nil {
+			return "", err
+		}
+		for _, found := range named {
+			if found != "" && found != id {
+				return "", ErrConflict
+			}
+		}
+	} else {
+		for _, found := range named {
+			if found == "" {
+				continue
+			}
+			if !r.trusted(peer) {
+				return "", ErrUntrusted
+			}
+			if id != "" && id != found {
+				return "", ErrConflict
+			}
+			id = found
+		}
+	}
+	if id == "" {
+		id = r.fallback
+	}
+	if id == "" {
+		return "", ErrRequired
+	}
+	if !Valid(id) {
+		return "", ErrInvalid
+	}
+	return id, nil
+}
+
+// trusted reports whether the host:port or host of peer is a trusted proxy.
+func (r Resolver) trusted(peer string) bool {
+	host, _, err := net.SplitHostPort(peer)
+	if err != nil {
+		host = peer
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range r.proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// verify returns the tenant claim of an HS256 token after checking its signature and lifetime.
+func (r Resolver) verify(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return "", ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	mac := hmac.New(sha256.New, r.secret)
+	_, _ = mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return "", ErrInvalidToken
+	}
+	var claims map[string]any
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return "", ErrInvalidToken
+	}
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); ok && now >= exp {
+		return "", ErrInvalidToken
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return "", ErrInvalidToken
+	}
+	id, _ := claims[r.claim].(string)
+	return id, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,77 @@
+// Package tenant carries the tenant of a request in its context.
+package tenant
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testToken(t *testing.T, secret string, claims map[string]any) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	b, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("claims: %v", err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(header + "." + payload))
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestResolver(t *testing.T) {
+	verified, err := NewResolver("secret", "tenant_id", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxied, err := NewResolver("", "tenant_id", "", []string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour).Unix()
+	acme := testToken(t, "secret", map[string]any{"tenant_id": "acme", "exp": future})
+
+	for name, tc := range map[string]struct {
+		resolver Resolver
+		token    string
+		peer     string
+		named    []string
+		want     string
+		err      error
+	}{
+		"token":            {resolver: verified, token: acme, want: "acme"},
+		"token and header": {resolver: verified, token: acme, named: []string{"acme", ""}, want: "acme"},
+		"header only":      {resolver: verified, named: []string{"acme"}, err: ErrTokenRequired},
+		"other header":     {resolver: verified, token: acme, named: []string{"globex"}, err: ErrConflict},
+		"no claim": {
+			resolver: verified, token: testToken(t, "secret", map[string]any{"sub": "john"}),
+			named: []string{"acme"}, err: ErrConflict,
+		},
+		"bad secret":     {resolver: verified, token: testToken(t, "other", map[string]any{"tenant_id": "acme"}), err: ErrInvalidToken},
+		"expired":        {resolver: verified, token: testToken(t, "secret", map[string]any{"tenant_id": "acme", "exp": 1}), err: ErrInvalidToken},
+		"proxy":          {resolver: proxied, peer: "10.1.2.3:5000", named: []string{"acme"}, want: "acme"},
+		"proxy ipv6":     {resolver: proxied, peer: "[::1]:5000", named: []string{"acme"}, want: "acme"},
+		"client":         {resolver: proxied, peer: "203.0.113.7:5000", named: []string{"acme"}, err: ErrUntrusted},
+		"proxy conflict": {resolver: proxied, peer: "10.1.2.3:5000", named: []string{"acme", "globex"}, err: ErrConflict},
+		"invalid":        {resolver: proxied, peer: "10.1.2.3:5000", named: []string{"Acme/../admin"}, err: ErrInvalid},
+		"missing":        {resolver: proxied, peer: "203.0.113.7:5000", err: ErrRequired},
+	} {
+		got, err := tc.resolver.Resolve(tc.token, tc.peer, tc.named...)
+		if !errors.Is(err, tc.err) || got != tc.want {
+			t.Errorf("%s: expected %q %v, got %q %v", name, tc.want, tc.err, got, err)
+		}
+	}
+
+	proxied.fallback = "shared"
+	if got, err := proxied.Resolve("", "203.0.113.7:5000"); err != nil || got != "shared" {
+		t.Fatalf("expected the fallback tenant, got %q %v", got, err)
+	}
+	if _, err = NewResolver("", "", "", []string{"gateway"}); err == nil {
+		t.Fatal("expected an invalid proxy to fail")
+	}
+}
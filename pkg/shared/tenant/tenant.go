@@ -0,0 +1,36 @@
+// Package tenant carries the tenant of a request in its context.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+var (
+	// ErrRequired is returned when tenancy is enabled and the request has no tenant.
+	ErrRequired = errors.New("tenant is required")
+	// ErrInvalid is returned when a tenant id is not a valid identifier.
+	ErrInvalid = errors.New("tenant id is invalid")
+)
+
+// ids are lowercase so that they are safe as database names.
+var valid = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,47}$`)
+
+type ctxKey struct{}
+
+// Valid reports whether id can be used as a tenant id.
+func Valid(id string) bool {
+	return valid.MatchString(id)
+}
+
+// WithID returns a copy of ctx carrying the tenant id.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant id of ctx, empty when there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
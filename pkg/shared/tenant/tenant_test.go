@@ -0,0 +1,32 @@
+// Package tenant carries the tenant of a request in its context.
+package tenant
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	if id := FromContext(context.Background()); id != "" {
+		t.Fatalf("expected no tenant, got %q", id)
+	}
+	if id := FromContext(WithID(context.Background(), "acme")); id != "acme" {
+		t.Fatalf("expected acme, got %q", id)
+	}
+}
+
+func TestValid(t *testing.T) {
+	for id, want := range map[string]bool{
+		"acme":       true,
+		"acme-eu_1":  true,
+		"":           false,
+		"Acme":       false,
+		"-acme":      false,
+		"acme.corp":  false,
+		"acme/../db": false,
+	} {
+		if got := Valid(id); got != want {
+			t.Errorf("Valid(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
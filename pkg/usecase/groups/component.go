@@ -0,0 +1,89 @@
+// Package groups is implements component logic.
+package groups
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+	"github.com/kubuskotak/ymir-test/pkg/usecase"
+	"github.com/kubuskotak/ymir-test/pkg/usecase/users"
+)
+
+func init() {
+	usecase.Register(usecase.Registration{
+		Name: "groups",
+		Inf:  reflect.TypeOf((*T)(nil)).Elem(),
+		New: func() any {
+			return &impl{}
+		},
+	})
+}
+
+// T is the interface implemented by all groups Component implementations.
+type T interface {
+	GetAll(ctx context.Context, paging entity.Pagination) (entity.ResponseGetGroups, error)
+	Create(ctx context.Context, group entity.Group) (entity.Group, error)
+	GetByID(ctx context.Context, groupID string) (entity.Group, error)
+	UpdateByID(ctx context.Context, group entity.Group) (entity.Group, error)
+	DeleteByID(ctx context.Context, groupID string) error
+	AddMember(ctx context.Context, groupID, userID string) (entity.GroupMember, error)
+	RemoveMember(ctx context.Context, groupID, userID string) error
+	GetMembers(ctx context.Context, groupID string, paging entity.Pagination) (entity.ResponseGetGroupMembers, error)
+	GetUserGroups(ctx context.Context, userID string, paging entity.Pagination) (entity.ResponseGetGroups, error)
+	Cascade(usc users.T)
+	AssignTenant(ctx context.Context, id string) (map[string]int64, error)
+	Migrated(ctx context.Context) error
+}
+
+type impl struct {
+	adapter *adapters.Adapter
+	users   users.T
+	tenancy tenant.Guard
+}
+
+// Init initializes the execution of a process involved in a groups Component usecase.
+func (i *impl) Init(adapter *adapters.Adapter) error {
+	i.adapter = adapter
+	tenants := infrastructure.Envs.Tenancy
+	i.tenancy.Required = tenants.Enable
+	if tenants.Enable && tenants.DatabasePerTenant {
+		i.tenancy.Migrate = i.migrate
+	}
+	// deferred until Mongo is up when the service starts degraded.
+	return adapter.UserDataMongo.WhenConnected(i.migrate)
+}
+
+// Cascade checks the members added against usc, exports the memberships of the
+// users usc exports and removes those of the users usc deletes or erases.
+func (i *impl) Cascade(usc users.T) {
+	i.users = usc
+	usc.OnExport(i.exportUser)
+	usc.OnDelete(i.removeUser)
+}
+
+// Migrated reports an error when an index created by Init is missing.
+func (i *impl) Migrated(ctx context.Context) error {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "groups.Migrated")
+	defer span.End()
+
+	for coll, index := range map[string]string{
+		"groups":        "tenant_id_1_name_1",
+		"group_members": "group_id_1_user_id_1",
+	} {
+		specs, err := i.adapter.TenantCollection(ctx, coll).Indexes().ListSpecifications(ctx)
+		if err != nil {
+			return err
+		}
+		if !adapters.HasIndex(specs, index) {
+			return fmt.Errorf("index %s of %s is missing", index, coll)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+// Package groups implement all logic.
+package groups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+// ErrMemberExists is returned when the user is already a member of the group.
+var ErrMemberExists = errors.New("user is already a member of this group")
+
+func (i *impl) AddMember(ctx context.Context, groupID, userID string) (entity.GroupMember, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "groups.AddMember")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return entity.GroupMember{}, err
+	}
+	filter, err := groupFilter(ctx, groupID)
+	if err != nil {
+		return entity.GroupMember{}, err
+	}
+	if i.users != nil {
+		if _, err = i.users.GetByID(ctx, userID, "id"); err != nil {
+			return entity.GroupMember{}, err
+		}
+	}
+
+	member := entity.GroupMember{
+		TenantID: tenant.FromContext(ctx),
+		GroupID:  groupID,
+		UserID:   userID,
+		JoinedAt: time.Now(),
+	}
+	// The membership and the count of members commit together. Standalone servers have
+	// no transaction, the count only moves once the membership is stored.
+	err = i.adapter.WithTransaction(ctx, func(ctx mongo.SessionContext) error {
+		members := i.adapter.TenantCollection(ctx, "group_members")
+		inserted, err := members.InsertOne(ctx, member)
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrMemberExists
+		}
+		if err != nil {
+			return err
+		}
+		counted, err := i.adapter.TenantCollection(ctx, "groups").UpdateOne(ctx, filter,
+			bson.D{{Key: "$inc", Value: bson.D{{Key: "members", Value: 1}}}})
+		if err != nil {
+			return err
+		}
+		if counted.MatchedCount == 0 {
+			if !adapters.InTransaction(ctx) {
+				_, _ = members.DeleteOne(ctx, bson.D{{Key: "_id", Value: inserted.InsertedID}})
+			}
+			return fmt.Errorf("no group with id %v was found: %w", groupID, mongo.ErrNoDocuments)
+		}
+		return nil
+	})
+	if err != nil {
+		return entity.GroupMember{}, err
+	}
+	return member, nil
+}
+
+func (i *impl) RemoveMember(ctx context.Context, groupID, userID string) error {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "groups.RemoveMember")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return err
+	}
+	filter, err := groupFilter(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	return i.adapter.WithTransaction(ctx, func(ctx mongo.SessionContext) error {
+		deleted, err := i.adapter.TenantCollection(ctx, "group_members").DeleteOne(ctx, adapters.Scope(ctx, bson.D{
+			{Key: "group_id", Value: groupID},
+			{Key: "user_id", Value: userID},
+		}))
+		if err != nil {
+			return err
+		}
+		if deleted.DeletedCount == 0 {
+			return fmt.Errorf("user %v is not a member of group %v: %w", userID, groupID, mongo.ErrNoDocuments)
+		}
+		_, err = i.adapter.TenantCollection(ctx, "groups").UpdateOne(ctx, filter,
+			bson.D{{Key: "$inc", Value: bson.D{{Key: "members", Value: -1}}}})
+		return err
+	})
+}
+
+func (i *impl) GetMembers(ctx context.Context, groupID string, paging entity.Pagination) (result entity.ResponseGetGroupMembers, err error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "groups.GetMembers")
+	defer span.End()
+
+	// an unknown group is not found rather than empty.
+	if _, err = i.GetByID(ctx, groupID); err != nil {
+		return result, err
+	}
+
+	cursor, err := i.adapter.TenantCollection(ctx, "group_members").Find(ctx,
+		adapters.Scope(ctx, bson.D{{Key: "group_id", Value: groupID}}),
+		page(paging).SetSort(bson.D{{Key: "joined_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return result, err
+	}
+	result.Members = make([]entity.GroupMember, 0)
+	if err = cursor.All(ctx, &result.Members); err != nil {
+		return result, err
+	}
+	result.Pagination = paging
+	return result, nil
+}
+
+func (i *impl) GetUserGroups(ctx context.Context, userID string, paging entity.Pagination) (result entity.ResponseGetGroups, err error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "groups.GetUserGroups")
+	defer span.End()
+
+	if err = i.tenancy.Check(ctx); err != nil {
+		return result, err
+	}
+
+	// the page is taken from the memberships, in the order the user joined.
+	cursor, err := i.adapter.TenantCollection(ctx, "group_members").Find(ctx,
+		adapters.Scope(ctx, bson.D{{Key: "user_id", Value: userID}}),
+		page(paging).SetSort(bson.D{{Key: "joined_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return result, err
+	}
+	var members []entity.GroupMember
+	if err = cursor.All(ctx, &members); err != nil {
+		return result, err
+	}
+
+	result.Groups = make([]entity.Group, 0, len(members))
+	result.Pagination = paging
+	if len(members) == 0 {
+		return result, nil
+	}
+	ids := make(bson.A, 0, len(members))
+	for _, member := range members {
+		id, err := primitive.ObjectIDFromHex(member.GroupID)
+		if err != nil {
+			return result, err
+		}
+		ids = append(ids, id)
+	}
+	cursor, err = i.adapter.TenantCollection(ctx, "groups").Find(ctx,
+		adapters.Scope(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}))
+	if err != nil {
+		return result, err
+	}
+	var groups []entity.Group
+	if err = cursor.All(ctx, &groups); err != nil {
+		return result, err
+	}
+	byID := make(map[string]entity.Group, len(groups))
+	for _, group := range groups {
+		byID[group.ID] = group
+	}
+	for _, member := range members {
+		if group, ok := byID[member.GroupID]; ok {
+			result.Groups = append(result.Groups, group)
+		}
+	}
+	return result, nil
+}
+
+// exportUser reads the memberships of an exported or erased user.
+func (i *impl) exportUser(ctx context.Context, userID string) (string, any, error) {
+	cursor, err := i.adapter.TenantCollection(ctx, "group_members").Find(ctx,
+		adapters.Scope(ctx, bson.D{{Key: "user_id", Value: userID}}),
+		options.Find().SetSort(bson.D{{Key: "joined_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return "group_members", nil, err
+	}
+	members := make([]entity.GroupMember, 0)
+	if err = cursor.All(ctx, &members); err != nil {
+		return "group_members", nil, err
+	}
+	return "group_members", members, nil
+}
+
+// removeUser drops the memberships of a deleted user and the counts of its groups.
+func (i *impl) removeUser(ctx context.Context, userID string) (string, int, error) {
+	members := i.adapter.TenantCollection(ctx, "group_members")
+	filter := adapters.Scope(ctx, bson.D{{Key: "user_id", Value: userID}})
+
+	groupIDs, err := members.Distinct(ctx, "group_id", filter)
+	if err != nil || len(groupIDs) == 0 {
+		return "group_members", 0, err
+	}
+	ids := make(bson.A, 0, len(groupIDs))
+	for _, groupID := range groupIDs {
+		hex, _ := groupID.(string)
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return "group_members", 0, err
+		}
+		ids = append(ids, id)
+	}
+
+	deleted, err := members.DeleteMany(ctx, filter)
+	if err != nil {
+		return "group_members", 0, err
+	}
+	_, err = i.adapter.TenantCollection(ctx, "groups").UpdateMany(ctx,
+		adapters.Scope(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}),
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "members", Value: -1}}}})
+	if err != nil {
+		return "group_members", 0, err
+	}
+	return "group_members", int(deleted.DeletedCount), nil
+}
@@ -0,0 +1,142 @@
+// Package groups implement all logic.
+package groups
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+)
+
+func TestGetUserGroups(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("in joined order", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		first, second := primitive.NewObjectID(), primitive.NewObjectID()
+		tt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.group_members", mtest.FirstBatch,
+				bson.D{{Key: "group_id", Value: second.Hex()}, {Key: "user_id", Value: "u1"}, {Key: "joined_at", Value: time.Now()}},
+				bson.D{{Key: "group_id", Value: first.Hex()}, {Key: "user_id", Value: "u1"}, {Key: "joined_at", Value: time.Now()}},
+			),
+			mtest.CreateCursorResponse(0, "test.groups", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: first}, {Key: "name", Value: "admins"}},
+				bson.D{{Key: "_id", Value: second}, {Key: "name", Value: "editors"}},
+			),
+		)
+
+		result, err := uc.GetUserGroups(context.Background(), "u1", entity.Pagination{Page: 1, Limit: 10})
+		if err != nil {
+			tt.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.Groups) != 2 || result.Groups[0].Name != "editors" || result.Groups[1].Name != "admins" {
+			tt.Fatalf("expected the groups in the order joined, got %+v", result.Groups)
+		}
+	})
+}
+
+func TestRemoveUser(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("memberships and counts", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		group := primitive.NewObjectID()
+		tt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "values", Value: bson.A{group.Hex()}}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		collection, removed, err := uc.removeUser(context.Background(), "u1")
+		if err != nil {
+			tt.Fatalf("unexpected error: %v", err)
+		}
+		if collection != "group_members" || removed != 1 {
+			tt.Fatalf("expected 1 membership removed, got %s %d", collection, removed)
+		}
+		var update bson.Raw
+		for event := tt.GetStartedEvent(); event != nil; event = tt.GetStartedEvent() {
+			if event.CommandName == "update" {
+				update = event.Command
+			}
+		}
+		if update == nil {
+			tt.Fatal("the count of the group was not updated")
+		}
+		inc := update.Lookup("updates").Array().Index(0).Value().Document().Lookup("u", "$inc", "members")
+		if inc.Int32() != -1 {
+			tt.Fatalf("expected the members to be decremented, got %v", inc)
+		}
+	})
+}
+
+func TestAddMember(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	standalone := mtest.CreateSuccessResponse(bson.E{Key: "isWritablePrimary", Value: true})
+	commands := func(tt *mtest.T) []string {
+		var names []string
+		for event := tt.GetStartedEvent(); event != nil; event = tt.GetStartedEvent() {
+			names = append(names, event.CommandName)
+		}
+		return names
+	}
+
+	mt.Run("counted after the membership", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		tt.AddMockResponses(
+			standalone,
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+		if _, err := uc.AddMember(context.Background(), primitive.NewObjectID().Hex(), "u1"); err != nil {
+			tt.Fatalf("unexpected error: %v", err)
+		}
+		if names := commands(tt); len(names) != 3 || names[1] != "insert" || names[2] != "update" {
+			tt.Fatalf("expected the membership to be inserted before the count, got %v", names)
+		}
+	})
+
+	mt.Run("added twice", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		tt.AddMockResponses(
+			standalone,
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}),
+		)
+		if _, err := uc.AddMember(context.Background(), primitive.NewObjectID().Hex(), "u1"); !errors.Is(err, ErrMemberExists) {
+			tt.Fatalf("expected ErrMemberExists, got %v", err)
+		}
+		for _, name := range commands(tt) {
+			if name == "update" {
+				tt.Fatal("the members of the group must not be counted again")
+			}
+		}
+	})
+
+	mt.Run("unknown group", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		tt.AddMockResponses(
+			standalone,
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+		if _, err := uc.AddMember(context.Background(), primitive.NewObjectID().Hex(), "u1"); !errors.Is(err, mongo.ErrNoDocuments) {
+			tt.Fatalf("expected ErrNoDocuments, got %v", err)
+		}
+		if names := commands(tt); len(names) != 4 || names[3] != "delete" {
+			tt.Fatalf("expected the membership to be removed again, got %v", names)
+		}
+	})
+}
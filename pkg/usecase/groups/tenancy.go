@@ -0,0 +1,11 @@
+// Package groups implement all logic.
+package groups
+
+import (
+	"context"
+)
+
+// AssignTenant gives the groups stored before tenancy, with their members, to the tenant id.
+func (i *impl) AssignTenant(ctx context.Context, id string) (map[string]int64, error) {
+	return i.adapter.AssignTenant(ctx, id, "groups", "group_members")
+}
@@ -0,0 +1,205 @@
+// Package groups implement all logic.
+package groups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+// ErrGroupExists is returned when the name is already used by another group.
+var ErrGroupExists = errors.New("group with this name already exists")
+
+func (i *impl) GetAll(ctx context.Context, paging entity.Pagination) (result entity.ResponseGetGroups, err error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "groups.GetAll")
+	defer span.End()
+
+	if err = i.tenancy.Check(ctx); err != nil {
+		return result, err
+	}
+	coll := i.adapter.TenantCollection(ctx, "groups")
+
+	cursor, err := coll.Find(ctx, adapters.Scope(ctx, bson.D{}), page(paging).
+		SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return result, err
+	}
+	result.Groups = make([]entity.Group, 0)
+	if err = cursor.All(ctx, &result.Groups); err != nil {
+		return result, err
+	}
+	result.Pagination = paging
+	return result, nil
+}
+
+func (i *impl) Create(ctx context.Context, group entity.Group) (entity.Group, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "groups.Create")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return entity.Group{}, err
+	}
+	coll := i.adapter.TenantCollection(ctx, "groups")
+
+	now := time.Now()
+	group.ID = ""
+	group.TenantID = tenant.FromContext(ctx)
+	group.Members = 0
+	group.CreatedAt = &now
+
+	result, err := coll.InsertOne(ctx, group)
+	if mongo.IsDuplicateKeyError(err) {
+		return entity.Group{}, ErrGroupExists
+	}
+	if err != nil {
+		return entity.Group{}, err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		group.ID = oid.Hex()
+	}
+	return group, nil
+}
+
+func (i *impl) GetByID(ctx context.Context, groupID string) (entity.Group, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "groups.GetByID")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return entity.Group{}, err
+	}
+	filter, err := groupFilter(ctx, groupID)
+	if err != nil {
+		return entity.Group{}, err
+	}
+
+	var group entity.Group
+	err = i.adapter.TenantCollection(ctx, "groups").FindOne(ctx, filter).Decode(&group)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return entity.Group{}, fmt.Errorf("no group with id %v was found: %w", groupID, err)
+	}
+	return group, err
+}
+
+func (i *impl) UpdateByID(ctx context.Context, group entity.Group) (entity.Group, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "groups.UpdateByID")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return entity.Group{}, err
+	}
+	filter, err := groupFilter(ctx, group.ID)
+	if err != nil {
+		return entity.Group{}, err
+	}
+
+	// the members are only counted by the membership writes.
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "name", Value: group.Name},
+			{Key: "description", Value: group.Description},
+		}},
+	}
+	var updated entity.Group
+	err = i.adapter.TenantCollection(ctx, "groups").FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	switch {
+	case mongo.IsDuplicateKeyError(err):
+		return entity.Group{}, ErrGroupExists
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return entity.Group{}, fmt.Errorf("no group with id %v was found: %w", group.ID, err)
+	case err != nil:
+		return entity.Group{}, err
+	}
+	return updated, nil
+}
+
+func (i *impl) DeleteByID(ctx context.Context, groupID string) error {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "groups.DeleteByID")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return err
+	}
+	filter, err := groupFilter(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	// The group and its memberships go together.
+	return i.adapter.WithTransaction(ctx, func(ctx mongo.SessionContext) error {
+		deleted, err := i.adapter.TenantCollection(ctx, "groups").DeleteOne(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if deleted.DeletedCount == 0 {
+			return fmt.Errorf("no group with id %v was found: %w", groupID, mongo.ErrNoDocuments)
+		}
+		_, err = i.adapter.TenantCollection(ctx, "group_members").
+			DeleteMany(ctx, adapters.Scope(ctx, bson.D{{Key: "group_id", Value: groupID}}))
+		return err
+	})
+}
+
+// groupFilter matches the group of the tenant of ctx by its hex id.
+func groupFilter(ctx context.Context, groupID string) (bson.D, error) {
+	id, err := primitive.ObjectIDFromHex(groupID)
+	if err != nil {
+		return nil, err
+	}
+	return adapters.Scope(ctx, bson.D{{Key: "_id", Value: id}}), nil
+}
+
+// page is the skip and limit of the page of a listing.
+func page(paging entity.Pagination) *options.FindOptions {
+	return options.Find().
+		SetSkip(int64((paging.Page - 1) * paging.Limit)).
+		SetLimit(int64(paging.Limit))
+}
+
+// migrate keeps group names unique per tenant and a user member of a group once,
+// and indexes the listings of members.
+func (i *impl) migrate(ctx context.Context) error {
+	_, err := i.adapter.TenantCollection(ctx, "groups").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "tenant_id", Value: 1},
+			{Key: "name", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = i.adapter.TenantCollection(ctx, "group_members").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "group_id", Value: 1},
+				{Key: "user_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "group_id", Value: 1},
+				{Key: "joined_at", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "joined_at", Value: 1},
+			},
+		},
+	})
+	return err
+}
@@ -0,0 +1,72 @@
+// Package idempotency is implements component logic.
+package idempotency
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/shared/fieldcrypt"
+	"github.com/kubuskotak/ymir-test/pkg/usecase"
+)
+
+const (
+	// defaultTTL is how long a response is kept for replay when not configured.
+	defaultTTL = 24 * time.Hour
+	// defaultLease is how long a request holds its key before a retry may take it over.
+	defaultLease = time.Minute
+)
+
+func init() {
+	usecase.Register(usecase.Registration{
+		Name: "idempotency",
+		Inf:  reflect.TypeOf((*T)(nil)).Elem(),
+		New: func() any {
+			return &impl{}
+		},
+	})
+}
+
+// T is the interface implemented by all idempotency Component implementations.
+type T interface {
+	Start(ctx context.Context, key, fingerprint string) (*entity.IdempotencyRecord, error)
+	Complete(ctx context.Context, key, subject string, statusCode int, contentType string, body []byte) error
+	Release(ctx context.Context, key string) error
+	Forget(ctx context.Context, userID string) (string, int, error)
+}
+
+type impl struct {
+	adapter *adapters.Adapter
+	keyring *fieldcrypt.Keyring
+	ttl     time.Duration
+	lease   time.Duration
+}
+
+// Init initializes the execution of a process involved in a idempotency Component usecase.
+func (i *impl) Init(adapter *adapters.Adapter) error {
+	i.adapter = adapter
+	i.ttl = infrastructure.Envs.Idempotency.TTL
+	if i.ttl <= 0 {
+		i.ttl = defaultTTL
+	}
+	i.lease = infrastructure.Envs.Idempotency.Lease
+	if i.lease <= 0 {
+		i.lease = defaultLease
+	}
+	// stored responses may hold personal data, seal them like the users do.
+	crypto := infrastructure.Envs.Crypto
+	keyring, err := fieldcrypt.Load(fieldcrypt.Options{
+		KeyFile:   crypto.KeyFile,
+		Keys:      crypto.Keys,
+		ActiveKey: crypto.ActiveKey,
+		IndexKey:  crypto.IndexKey,
+	})
+	if err != nil {
+		return err
+	}
+	i.keyring = keyring
+	return adapter.UserDataMongo.WhenConnected(i.indexes)
+}
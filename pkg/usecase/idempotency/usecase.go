@@ -0,0 +1,173 @@
+// Package idempotency implement all logic.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+var (
+	// ErrKeyReused is returned when the key was used before with a different request.
+	ErrKeyReused = errors.New("idempotency key was already used with a different request")
+	// ErrInFlight is returned when the first request with the key is still being processed.
+	ErrInFlight = errors.New("a request with this idempotency key is still in progress")
+)
+
+// errIndexOptionsConflict is the code of an index created again with other options.
+const errIndexOptionsConflict = 85
+
+// Scoped prefixes id with the tenant of ctx, tenants share the keys collection so
+// that a key or a subject is only unique within its tenant.
+func Scoped(ctx context.Context, id string) string {
+	if t := tenant.FromContext(ctx); t != "" {
+		return t + "/" + id
+	}
+	return id
+}
+
+// Start claims the key for a request. It returns the completed record to replay
+// when the same request was made before, or nil when the request should run.
+func (i *impl) Start(ctx context.Context, key, fingerprint string) (*entity.IdempotencyRecord, error) {
+	coll := i.adapter.Collection("idempotency_keys")
+
+	_, err := coll.InsertOne(ctx, entity.IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		Status:      entity.IdempotencyProcessing,
+		CreatedAt:   time.Now(),
+	})
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	var record entity.IdempotencyRecord
+	err = coll.FindOne(ctx, bson.D{{Key: "_id", Value: key}}).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// released or expired in between, let the caller retry from scratch.
+		return nil, ErrInFlight
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case record.Fingerprint != fingerprint:
+		return nil, ErrKeyReused
+	case record.Status != entity.IdempotencyCompleted:
+		return nil, i.takeOver(ctx, record)
+	}
+	if record.Body, err = i.keyring.Decrypt(record.Body); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Complete stores the response of the request for later replays, subject is the
+// user the response is about, if any.
+func (i *impl) Complete(ctx context.Context, key, subject string, statusCode int, contentType string, body []byte) error {
+	coll := i.adapter.Collection("idempotency_keys")
+
+	sealed, err := i.keyring.Encrypt(string(body))
+	if err != nil {
+		return err
+	}
+	set := bson.D{
+		{Key: "status", Value: entity.IdempotencyCompleted},
+		{Key: "status_code", Value: statusCode},
+		{Key: "content_type", Value: contentType},
+		{Key: "body", Value: sealed},
+	}
+	if subject != "" {
+		set = append(set, bson.E{Key: "subject", Value: Scoped(ctx, subject)})
+	}
+	_, err = coll.UpdateOne(ctx, bson.D{{Key: "_id", Value: key}}, bson.D{{Key: "$set", Value: set}})
+	return err
+}
+
+// Forget drops the stored responses about an erased user, they may hold its personal data.
+func (i *impl) Forget(ctx context.Context, userID string) (string, int, error) {
+	coll := i.adapter.Collection("idempotency_keys")
+
+	deleted, err := coll.DeleteMany(ctx, bson.D{{Key: "subject", Value: Scoped(ctx, userID)}})
+	if err != nil {
+		return "idempotency_keys", 0, err
+	}
+	return "idempotency_keys", int(deleted.DeletedCount), nil
+}
+
+// Release drops the key so that a failed request can be retried.
+func (i *impl) Release(ctx context.Context, key string) error {
+	coll := i.adapter.Collection("idempotency_keys")
+
+	_, err := coll.DeleteOne(ctx, bson.D{{Key: "_id", Value: key}})
+	return err
+}
+
+// takeOver claims the key of a request still processing once its lease is over, the
+// process running it died before it could complete or release the key. It returns
+// ErrInFlight while the lease holds or when another request took the key first.
+func (i *impl) takeOver(ctx context.Context, record entity.IdempotencyRecord) error {
+	if time.Since(record.CreatedAt) < i.lease {
+		return ErrInFlight
+	}
+	coll := i.adapter.Collection("idempotency_keys")
+
+	taken, err := coll.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: record.Key},
+		{Key: "status", Value: entity.IdempotencyProcessing},
+		{Key: "created_at", Value: record.CreatedAt},
+	}, bson.D{{Key: "$set", Value: bson.D{{Key: "created_at", Value: time.Now()}}}})
+	if err != nil {
+		return err
+	}
+	if taken.ModifiedCount == 0 {
+		return ErrInFlight
+	}
+	return nil
+}
+
+// indexes finds the stored responses of a subject, and expires them after the ttl.
+func (i *impl) indexes(ctx context.Context) error {
+	coll := i.adapter.Collection("idempotency_keys")
+
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "subject", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		return err
+	}
+	return i.expiry(ctx, coll)
+}
+
+// expiry expires the stored responses after the ttl, a changed ttl is applied to the
+// existing index as its options cannot be changed by creating it again.
+func (i *impl) expiry(ctx context.Context, coll *mongo.Collection) error {
+	keys := bson.D{{Key: "created_at", Value: 1}}
+	expireAfter := int32(i.ttl.Seconds())
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetExpireAfterSeconds(expireAfter),
+	})
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) || !cmdErr.HasErrorCode(errIndexOptionsConflict) {
+		return err
+	}
+	return coll.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: coll.Name()},
+		{Key: "index", Value: bson.D{
+			{Key: "keyPattern", Value: keys},
+			{Key: "expireAfterSeconds", Value: expireAfter},
+		}},
+	}).Err()
+}
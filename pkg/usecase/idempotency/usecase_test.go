@@ -0,0 +1,111 @@
+// Package idempotency implement all logic.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+func TestStart(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	processing := func(tt *mtest.T, createdAt time.Time) {
+		tt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}),
+			mtest.CreateCursorResponse(0, "test.idempotency_keys", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: "k1"},
+				{Key: "fingerprint", Value: "f1"},
+				{Key: "status", Value: entity.IdempotencyProcessing},
+				{Key: "created_at", Value: createdAt},
+			}),
+		)
+	}
+
+	mt.Run("held by a running request", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}, lease: time.Minute}
+		processing(tt, time.Now())
+
+		if _, err := uc.Start(context.Background(), "k1", "f1"); !errors.Is(err, ErrInFlight) {
+			tt.Fatalf("expected ErrInFlight, got %v", err)
+		}
+	})
+
+	mt.Run("stale request is taken over", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}, lease: time.Minute}
+		processing(tt, time.Now().Add(-2*time.Minute))
+		tt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+
+		record, err := uc.Start(context.Background(), "k1", "f1")
+		if err != nil || record != nil {
+			tt.Fatalf("expected the key to be taken over, got %v %v", record, err)
+		}
+	})
+
+	mt.Run("stale request taken over by another retry", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}, lease: time.Minute}
+		processing(tt, time.Now().Add(-2*time.Minute))
+		tt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}})
+
+		if _, err := uc.Start(context.Background(), "k1", "f1"); !errors.Is(err, ErrInFlight) {
+			tt.Fatalf("expected ErrInFlight, got %v", err)
+		}
+	})
+}
+
+func TestIndexes(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("changed ttl", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}, ttl: time.Hour}
+		tt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 85, Name: "IndexOptionsConflict", Message: "index already exists with different options"}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		if err := uc.indexes(context.Background()); err != nil {
+			tt.Fatalf("unexpected error: %v", err)
+		}
+		if subject := tt.GetStartedEvent(); subject == nil ||
+			subject.Command.Lookup("indexes", "0", "key", "subject").Int32() != 1 {
+			tt.Fatalf("expected the subject index, got %v", subject)
+		}
+		tt.GetStartedEvent()
+		modified := tt.GetStartedEvent()
+		if modified == nil || modified.CommandName != "collMod" ||
+			modified.Command.Lookup("index", "expireAfterSeconds").Int32() != 3600 {
+			tt.Fatalf("expected the ttl to be modified, got %v", modified)
+		}
+	})
+}
+
+func TestForget(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("responses of the tenant user", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		tt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 2}})
+
+		collection, removed, err := uc.Forget(tenant.WithID(context.Background(), "acme"), "u1")
+		if err != nil || collection != "idempotency_keys" || removed != 2 {
+			tt.Fatalf("expected 2 responses forgotten, got %s %d %v", collection, removed, err)
+		}
+		deleted := tt.GetStartedEvent()
+		if deleted == nil || deleted.CommandName != "delete" ||
+			deleted.Command.Lookup("deletes", "0", "q", "subject").StringValue() != "acme/u1" {
+			tt.Fatalf("expected the responses about acme/u1 to be deleted, got %v", deleted)
+		}
+	})
+}
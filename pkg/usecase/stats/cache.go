@@ -0,0 +1,66 @@
+// Package stats implement all logic.
+package stats
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+)
+
+// cache keeps the stats computed in this process for ttl, by tenant and request. The
+// requests are chosen by the clients, the least recently used stats are dropped once
+// size entries are held.
+type cache struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   list.List // of *cacheEntry, most recently used first
+}
+
+type cacheEntry struct {
+	key   string
+	stats entity.UserStats
+}
+
+func newCache(ttl time.Duration, size int) *cache {
+	return &cache{ttl: ttl, size: size, entries: map[string]*list.Element{}}
+}
+
+// get returns the stats of key generated less than ttl before now.
+func (c *cache) get(key string, now time.Time) (entity.UserStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return entity.UserStats{}, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if now.Sub(entry.stats.GeneratedAt) >= c.ttl {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return entity.UserStats{}, false
+	}
+	c.order.MoveToFront(element)
+	return entry.stats, true
+}
+
+// put stores stats under key and drops the least recently used entries over size.
+func (c *cache) put(key string, stats entity.UserStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheEntry).stats = stats
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, stats: stats})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,71 @@
+// Package stats is implements component logic.
+package stats
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+	"github.com/kubuskotak/ymir-test/pkg/usecase"
+)
+
+const (
+	// defaultCacheTTL is how long stats are cached when not configured.
+	defaultCacheTTL = time.Minute
+	// defaultCacheSize is how many stats are cached when not configured.
+	defaultCacheSize = 1000
+)
+
+func init() {
+	usecase.Register(usecase.Registration{
+		Name: "stats",
+		Inf:  reflect.TypeOf((*T)(nil)).Elem(),
+		New: func() any {
+			return &impl{}
+		},
+	})
+}
+
+// T is the interface implemented by all stats Component implementations.
+type T interface {
+	Users(ctx context.Context, request entity.RequestUserStats) (entity.UserStats, error)
+}
+
+type impl struct {
+	adapter *adapters.Adapter
+	tenancy tenant.Guard       // the databases of the tenants are migrated by the users
+	listing *readpref.ReadPref // read preference of the aggregations, nil reads the connection default
+	domains bool               // the users keep their email domain in clear to be counted
+	cache   *cache
+}
+
+// Init initializes the execution of a process involved in a stats Component usecase.
+func (i *impl) Init(adapter *adapters.Adapter) error {
+	i.adapter = adapter
+	i.tenancy.Required = infrastructure.Envs.Tenancy.Enable
+	i.domains = infrastructure.Envs.Stats.Domains
+	ttl := infrastructure.Envs.Stats.CacheTTL
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	size := infrastructure.Envs.Stats.CacheSize
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	i.cache = newCache(ttl, size)
+
+	// the aggregations scan the users, they are read like the listings.
+	reads := infrastructure.Envs.Reads
+	listing, err := adapters.ReadPreference(reads.Listing, reads.MaxStaleness)
+	if err != nil {
+		return err
+	}
+	i.listing = listing
+	return nil
+}
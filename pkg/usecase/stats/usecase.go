@@ -0,0 +1,199 @@
+// Package stats implement all logic.
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+const (
+	defaultAgeWidth = 10
+	defaultDomains  = 10
+)
+
+var (
+	// ErrInvalidInterval is returned for an interval other than a day or a week.
+	ErrInvalidInterval = errors.New("stats interval must be day or week")
+	// ErrInvalidTimeZone is returned for a time zone missing from the tz database.
+	ErrInvalidTimeZone = errors.New("unknown time zone")
+	// ErrInvalidRange is returned when the dates of the range cannot be parsed.
+	ErrInvalidRange = errors.New("stats range must be dates formatted as YYYY-MM-DD")
+)
+
+// facets is the result of the aggregation, one document with a field per count.
+type facets struct {
+	Total []struct {
+		Count int `bson:"count"`
+	} `bson:"total"`
+	Created []entity.CreatedBucket `bson:"created"`
+	Ages    []entity.AgeBucket     `bson:"ages"`
+	Domains []entity.DomainCount   `bson:"domains"`
+}
+
+// Users counts the users created by day or week, by age and, when their domains are
+// kept, by email domain in a single aggregation. The days start at midnight in the time
+// zone of the request, bucketing dates in the server needs MongoDB 5.0.
+func (i *impl) Users(ctx context.Context, request entity.RequestUserStats) (entity.UserStats, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "stats.Users")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return entity.UserStats{}, err
+	}
+	request, location, err := normalize(request)
+	if err != nil {
+		return entity.UserStats{}, err
+	}
+	match, err := createdRange(request, location)
+	if err != nil {
+		return entity.UserStats{}, err
+	}
+	if !i.domains {
+		request.Domains = 0
+	}
+
+	now := time.Now()
+	key := fmt.Sprintf("%s|%+v", tenant.FromContext(ctx), request)
+	if stats, ok := i.cache.get(key, now); ok {
+		return stats, nil
+	}
+
+	bucket := bson.D{
+		{Key: "date", Value: "$created_at"},
+		{Key: "unit", Value: request.Interval},
+		{Key: "timezone", Value: request.TimeZone},
+	}
+	if request.Interval == entity.StatsIntervalWeek {
+		bucket = append(bucket, bson.E{Key: "startOfWeek", Value: "monday"})
+	}
+	facet := bson.D{
+		{Key: "total", Value: bson.A{
+			bson.D{{Key: "$count", Value: "count"}},
+		}},
+		{Key: "created", Value: bson.A{
+			bson.D{{Key: "$match", Value: bson.D{{Key: "created_at", Value: bson.D{{Key: "$type", Value: "date"}}}}}},
+			bson.D{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: bson.D{{Key: "$dateTrunc", Value: bucket}}},
+				{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		}},
+		{Key: "ages", Value: bson.A{
+			bson.D{{Key: "$match", Value: bson.D{{Key: "age", Value: bson.D{{Key: "$type", Value: "number"}}}}}},
+			bson.D{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: bson.D{{Key: "$subtract", Value: bson.A{
+					"$age", bson.D{{Key: "$mod", Value: bson.A{"$age", request.AgeWidth}}},
+				}}}},
+				{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		}},
+	}
+	if i.domains {
+		facet = append(facet, bson.E{Key: "domains", Value: bson.A{
+			bson.D{{Key: "$match", Value: bson.D{{Key: "email_domain", Value: bson.D{{Key: "$gt", Value: ""}}}}}},
+			bson.D{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$email_domain"},
+				{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+			bson.D{{Key: "$limit", Value: request.Domains}},
+		}})
+	}
+	cursor, err := i.adapter.ReadCollection(ctx, "users", i.listing).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: adapters.Scope(ctx, match)}},
+		{{Key: "$facet", Value: facet}},
+	})
+	if err != nil {
+		return entity.UserStats{}, err
+	}
+	var results []facets
+	if err = cursor.All(ctx, &results); err != nil {
+		return entity.UserStats{}, err
+	}
+
+	stats := entity.UserStats{
+		Interval:    request.Interval,
+		TimeZone:    request.TimeZone,
+		Created:     make([]entity.CreatedBucket, 0),
+		Ages:        make([]entity.AgeBucket, 0),
+		Domains:     make([]entity.DomainCount, 0),
+		GeneratedAt: now,
+	}
+	if len(results) == 1 {
+		result := results[0]
+		if len(result.Total) == 1 {
+			stats.Total = result.Total[0].Count
+		}
+		for _, created := range result.Created {
+			created.Start = created.Start.In(location)
+			stats.Created = append(stats.Created, created)
+		}
+		for _, age := range result.Ages {
+			age.To = age.From + request.AgeWidth
+			stats.Ages = append(stats.Ages, age)
+		}
+		stats.Domains = append(stats.Domains, result.Domains...)
+	}
+	i.cache.put(key, stats)
+	return stats, nil
+}
+
+// normalize fills the defaults of the request and loads its time zone.
+func normalize(request entity.RequestUserStats) (entity.RequestUserStats, *time.Location, error) {
+	switch request.Interval {
+	case "":
+		request.Interval = entity.StatsIntervalDay
+	case entity.StatsIntervalDay, entity.StatsIntervalWeek:
+	default:
+		return request, nil, fmt.Errorf("%w, got %q", ErrInvalidInterval, request.Interval)
+	}
+	if request.TimeZone == "" {
+		request.TimeZone = "UTC"
+	}
+	location, err := time.LoadLocation(request.TimeZone)
+	if err != nil {
+		return request, nil, fmt.Errorf("%w %q", ErrInvalidTimeZone, request.TimeZone)
+	}
+	if request.AgeWidth <= 0 {
+		request.AgeWidth = defaultAgeWidth
+	}
+	if request.Domains <= 0 {
+		request.Domains = defaultDomains
+	}
+	return request, location, nil
+}
+
+// createdRange matches the users created from the start of the day From up to the
+// end of the day To, both in location.
+func createdRange(request entity.RequestUserStats, location *time.Location) (bson.D, error) {
+	var created bson.D
+	if request.From != "" {
+		from, err := time.ParseInLocation(time.DateOnly, request.From, location)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRange, err)
+		}
+		created = append(created, bson.E{Key: "$gte", Value: from})
+	}
+	if request.To != "" {
+		to, err := time.ParseInLocation(time.DateOnly, request.To, location)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRange, err)
+		}
+		created = append(created, bson.E{Key: "$lt", Value: to.AddDate(0, 0, 1)})
+	}
+	if created == nil {
+		return bson.D{}, nil
+	}
+	return bson.D{{Key: "created_at", Value: created}}, nil
+}
@@ -0,0 +1,117 @@
+// Package stats implement all logic.
+package stats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+)
+
+func TestCreatedRange(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Skip("tz database unavailable")
+	}
+	match, err := createdRange(entity.RequestUserStats{From: "2024-01-01", To: "2024-01-31"}, jakarta)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	created := match[0].Value.(bson.D)
+	if from := created[0].Value.(time.Time); !from.Equal(time.Date(2023, 12, 31, 17, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected midnight in jakarta, got %v", from.UTC())
+	}
+	if to := created[1].Value.(time.Time); created[1].Key != "$lt" || !to.Equal(time.Date(2024, 1, 31, 17, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the end of the last day, got %v %v", created[1].Key, to.UTC())
+	}
+	if _, _, err = normalize(entity.RequestUserStats{TimeZone: "Mars/Olympus"}); !errors.Is(err, ErrInvalidTimeZone) {
+		t.Fatalf("expected ErrInvalidTimeZone, got %v", err)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("cached", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}, cache: newCache(time.Minute, 10), domains: true}
+		tt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, bson.D{
+			{Key: "total", Value: bson.A{bson.D{{Key: "count", Value: 3}}}},
+			{Key: "created", Value: bson.A{bson.D{
+				{Key: "_id", Value: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{Key: "count", Value: 3},
+			}}},
+			{Key: "ages", Value: bson.A{
+				bson.D{{Key: "_id", Value: 20}, {Key: "count", Value: 2}},
+				bson.D{{Key: "_id", Value: 30}, {Key: "count", Value: 1}},
+			}},
+			{Key: "domains", Value: bson.A{bson.D{{Key: "_id", Value: "example.com"}, {Key: "count", Value: 3}}}},
+		}))
+
+		request := entity.RequestUserStats{Interval: entity.StatsIntervalWeek}
+		stats, err := uc.Users(context.Background(), request)
+		if err != nil {
+			tt.Fatalf("unexpected error: %v", err)
+		}
+		if stats.Total != 3 || stats.TimeZone != "UTC" || len(stats.Created) != 1 || stats.Domains[0].Domain != "example.com" {
+			tt.Fatalf("unexpected stats %+v", stats)
+		}
+		if len(stats.Ages) != 2 || stats.Ages[1].From != 30 || stats.Ages[1].To != 40 {
+			tt.Fatalf("unexpected ages %+v", stats.Ages)
+		}
+		started := tt.GetStartedEvent()
+		if started == nil || started.CommandName != "aggregate" {
+			tt.Fatal("expected an aggregate command")
+		}
+
+		// no response is mocked, a second aggregation would fail.
+		cached, err := uc.Users(context.Background(), request)
+		if err != nil || !cached.GeneratedAt.Equal(stats.GeneratedAt) {
+			tt.Fatalf("expected the cached stats, got %+v %v", cached, err)
+		}
+	})
+	mt.Run("domains not kept", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}, cache: newCache(time.Minute, 10)}
+		tt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, bson.D{
+			{Key: "total", Value: bson.A{bson.D{{Key: "count", Value: 3}}}},
+		}))
+
+		stats, err := uc.Users(context.Background(), entity.RequestUserStats{Domains: 5})
+		if err != nil || stats.Total != 3 || stats.Domains == nil || len(stats.Domains) != 0 {
+			tt.Fatalf("expected no domains, got %+v %v", stats, err)
+		}
+		facet := tt.GetStartedEvent().Command.Lookup("pipeline").Array().Index(1).Value().Document().Lookup("$facet").Document()
+		if _, err = facet.LookupErr("domains"); err == nil {
+			tt.Fatalf("expected the domains not to be counted, got %v", facet)
+		}
+	})
+}
+
+func TestCache(t *testing.T) {
+	var (
+		now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		c   = newCache(time.Minute, 2)
+	)
+	c.put("a", entity.UserStats{Total: 1, GeneratedAt: now})
+	c.put("b", entity.UserStats{Total: 2, GeneratedAt: now})
+	if _, ok := c.get("a", now); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	// b is the least recently used.
+	c.put("c", entity.UserStats{Total: 3, GeneratedAt: now})
+	if _, ok := c.get("b", now); ok || len(c.entries) != 2 {
+		t.Fatalf("expected b to be dropped, got %d entries", len(c.entries))
+	}
+	if stats, ok := c.get("c", now); !ok || stats.Total != 3 {
+		t.Fatalf("expected c to be cached, got %+v", stats)
+	}
+	if _, ok := c.get("a", now.Add(time.Minute)); ok || len(c.entries) != 1 {
+		t.Fatalf("expected a to expire, got %d entries", len(c.entries))
+	}
+}
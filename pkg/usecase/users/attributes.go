@@ -0,0 +1,508 @@
+// Package users implement all logic.
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+var (
+	// ErrAttributeExists is returned when an attribute of the same name is already defined.
+	ErrAttributeExists = errors.New("attribute is already defined")
+	// ErrInvalidAttribute is returned when an attribute definition has an invalid name or type.
+	ErrInvalidAttribute = errors.New("invalid attribute definition")
+	// ErrAttributeConflict is returned when users already share a value of an attribute defined as unique.
+	ErrAttributeConflict = errors.New("users already share values of the attribute")
+)
+
+var attributeName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// filterOperators maps the operators of an entity.AttributeFilter on their Mongo operator.
+var filterOperators = map[string]string{
+	entity.FilterEqual:              "$eq",
+	entity.FilterNotEqual:           "$ne",
+	entity.FilterGreaterThan:        "$gt",
+	entity.FilterGreaterThanOrEqual: "$gte",
+	entity.FilterLessThan:           "$lt",
+	entity.FilterLessThanOrEqual:    "$lte",
+}
+
+// sortable are the json names of the User fields a listing can be sorted on,
+// the email is sealed and sorts by its ciphertext.
+var sortable = []string{"id", "name", "age", "created_at"}
+
+// ValidAttributeName reports whether name can name a custom attribute.
+func ValidAttributeName(name string) bool {
+	return attributeName.MatchString(name)
+}
+
+// AttributeError is a custom attribute of a user, or a filter or sort of a listing,
+// failing the attribute definitions.
+type AttributeError struct {
+	Field string // attributes.<name> of a user, filters or sort of a listing
+	Rule  string // required, attribute, type, operator, sort or unique
+	Param string // what the rule expects
+}
+
+// AttributeErrors is returned with every failing attribute, filter or sort.
+type AttributeErrors []AttributeError
+
+func (e AttributeErrors) Error() string {
+	failures := make([]string, 0, len(e))
+	for _, err := range e {
+		failure := err.Field + " failed on " + err.Rule
+		if err.Param != "" {
+			failure += " " + err.Param
+		}
+		failures = append(failures, failure)
+	}
+	return "invalid attributes: " + strings.Join(failures, ", ")
+}
+
+func (i *impl) DefineAttribute(ctx context.Context, definition entity.AttributeDefinition) (entity.AttributeDefinition, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.DefineAttribute")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return entity.AttributeDefinition{}, err
+	}
+	if !ValidAttributeName(definition.Name) {
+		return entity.AttributeDefinition{}, fmt.Errorf("%w: name %q must match %s", ErrInvalidAttribute, definition.Name, attributeName)
+	}
+	switch definition.Type {
+	case entity.AttributeTypeString, entity.AttributeTypeNumber, entity.AttributeTypeBoolean, entity.AttributeTypeDate:
+	default:
+		return entity.AttributeDefinition{}, fmt.Errorf("%w: unknown type %q", ErrInvalidAttribute, definition.Type)
+	}
+	coll := i.adapter.TenantCollection(ctx, "user_attributes")
+
+	definition.ID = ""
+	definition.TenantID = tenant.FromContext(ctx)
+	definition.CreatedAt = time.Now()
+	result, err := coll.InsertOne(ctx, definition)
+	if mongo.IsDuplicateKeyError(err) {
+		return entity.AttributeDefinition{}, fmt.Errorf("%w: %s", ErrAttributeExists, definition.Name)
+	}
+	if err != nil {
+		return entity.AttributeDefinition{}, err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		definition.ID = oid.Hex()
+	}
+
+	// a unique attribute is only enforced by its index, keep no definition without it.
+	if err = i.attributeIndex(ctx, definition); err != nil {
+		if _, derr := coll.DeleteOne(ctx, bson.D{{Key: "_id", Value: result.InsertedID}}); derr != nil {
+			return entity.AttributeDefinition{}, errors.Join(err, derr)
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return entity.AttributeDefinition{}, fmt.Errorf("%w: %s", ErrAttributeConflict, definition.Name)
+		}
+		return entity.AttributeDefinition{}, err
+	}
+	return definition, nil
+}
+
+func (i *impl) ListAttributes(ctx context.Context) ([]entity.AttributeDefinition, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.ListAttributes")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return nil, err
+	}
+	cursor, err := i.adapter.TenantCollection(ctx, "user_attributes").Find(ctx, adapters.Scope(ctx, bson.D{}),
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	definitions := make([]entity.AttributeDefinition, 0)
+	if err = cursor.All(ctx, &definitions); err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+func (i *impl) RemoveAttribute(ctx context.Context, name string) error {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.RemoveAttribute")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return err
+	}
+	path := "attributes." + name
+
+	// the definition and the values held by users go together.
+	err := i.adapter.WithTransaction(ctx, func(ctx mongo.SessionContext) error {
+		deleted, err := i.adapter.TenantCollection(ctx, "user_attributes").DeleteOne(ctx, adapters.Scope(ctx, bson.D{{Key: "name", Value: name}}))
+		if err != nil {
+			return err
+		}
+		if deleted.DeletedCount == 0 {
+			return fmt.Errorf("no attribute %s was defined: %w", name, mongo.ErrNoDocuments)
+		}
+		_, err = i.adapter.TenantCollection(ctx, "users").UpdateMany(ctx,
+			adapters.Scope(ctx, bson.D{{Key: path, Value: bson.D{{Key: "$exists", Value: true}}}}),
+			bson.D{{Key: "$unset", Value: bson.D{{Key: path, Value: ""}, {Key: "unique." + name, Value: ""}}}},
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return i.dropAttributeIndexes(ctx, name)
+}
+
+// definitions are the attribute definitions of the tenant of ctx by name.
+func (i *impl) definitions(ctx context.Context) (map[string]entity.AttributeDefinition, error) {
+	cursor, err := i.adapter.TenantCollection(ctx, "user_attributes").Find(ctx, adapters.Scope(ctx, bson.D{}))
+	if err != nil {
+		return nil, err
+	}
+	var list []entity.AttributeDefinition
+	if err = cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	definitions := make(map[string]entity.AttributeDefinition, len(list))
+	for _, definition := range list {
+		definitions[definition.Name] = definition
+	}
+	return definitions, nil
+}
+
+// checkAttributes validates the attributes of a user write against the definitions and
+// converts their values to the stored types. Null values are dropped.
+func checkAttributes(definitions map[string]entity.AttributeDefinition, attributes map[string]any) (map[string]any, error) {
+	var errs AttributeErrors
+	checked := make(map[string]any, len(attributes))
+	for _, name := range sortedKeys(attributes) {
+		definition, ok := definitions[name]
+		if !ok {
+			errs = append(errs, AttributeError{Field: "attributes." + name, Rule: "attribute", Param: known(definitions)})
+			continue
+		}
+		if attributes[name] == nil {
+			continue
+		}
+		value, ok := attributeValue(definition.Type, attributes[name])
+		if !ok {
+			errs = append(errs, AttributeError{Field: "attributes." + name, Rule: "type", Param: definition.Type})
+			continue
+		}
+		checked[name] = value
+	}
+	for _, name := range sortedKeys(definitions) {
+		if _, ok := checked[name]; definitions[name].Required && !ok && attributes[name] == nil {
+			errs = append(errs, AttributeError{Field: "attributes." + name, Rule: "required"})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(checked) == 0 {
+		return nil, nil
+	}
+	return checked, nil
+}
+
+// attributeValue converts a decoded value to the stored value of the type.
+func attributeValue(typ string, value any) (any, bool) {
+	switch typ {
+	case entity.AttributeTypeString:
+		v, ok := value.(string)
+		return v, ok
+	case entity.AttributeTypeNumber:
+		switch v := value.(type) {
+		case float64:
+			return v, true
+		case float32:
+			return float64(v), true
+		case int:
+			return float64(v), true
+		case int32:
+			return float64(v), true
+		case int64:
+			return float64(v), true
+		}
+	case entity.AttributeTypeBoolean:
+		v, ok := value.(bool)
+		return v, ok
+	case entity.AttributeTypeDate:
+		switch v := value.(type) {
+		case time.Time:
+			return v.UTC(), true
+		case primitive.DateTime:
+			return v.Time().UTC(), true
+		case string:
+			return parseDate(v)
+		}
+	}
+	return nil, false
+}
+
+// parseAttribute parses the value of a filter as the type.
+func parseAttribute(typ, value string) (any, bool) {
+	switch typ {
+	case entity.AttributeTypeString:
+		return value, true
+	case entity.AttributeTypeNumber:
+		v, err := strconv.ParseFloat(value, 64)
+		return v, err == nil
+	case entity.AttributeTypeBoolean:
+		v, err := strconv.ParseBool(value)
+		return v, err == nil
+	case entity.AttributeTypeDate:
+		return parseDate(value)
+	}
+	return nil, false
+}
+
+// parseDate accepts RFC 3339 date times and YYYY-MM-DD dates.
+func parseDate(value string) (any, bool) {
+	for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return nil, false
+}
+
+// openAttributes converts the decoded attributes of a stored user to their entity types.
+func openAttributes(attributes map[string]any) map[string]any {
+	for name, value := range attributes {
+		if v, ok := value.(primitive.DateTime); ok {
+			attributes[name] = v.Time().UTC()
+		}
+	}
+	return attributes
+}
+
+// attributeFilter matches the users passing every filter of a listing.
+func attributeFilter(definitions map[string]entity.AttributeDefinition, filters []entity.AttributeFilter) (bson.A, error) {
+	var (
+		errs       AttributeErrors
+		conditions bson.A
+	)
+	for _, filter := range filters {
+		definition, ok := definitions[filter.Name]
+		if !ok {
+			errs = append(errs, AttributeError{Field: "filters", Rule: "attribute", Param: known(definitions)})
+			continue
+		}
+		operator, ok := filterOperators[filter.Operator]
+		if !ok || definition.Type == entity.AttributeTypeBoolean && operator != "$eq" && operator != "$ne" {
+			errs = append(errs, AttributeError{Field: "filters", Rule: "operator", Param: strings.Join(operators(definition.Type), " ")})
+			continue
+		}
+		value, ok := parseAttribute(definition.Type, filter.Value)
+		if !ok {
+			errs = append(errs, AttributeError{Field: "filters", Rule: "type", Param: definition.Type})
+			continue
+		}
+		conditions = append(conditions, bson.D{{Key: "attributes." + filter.Name, Value: bson.D{{Key: operator, Value: value}}}})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return conditions, nil
+}
+
+// sortOrder maps the sort keys of a listing on a Mongo sort, the id breaks ties
+// so that pages do not overlap.
+func sortOrder(definitions map[string]entity.AttributeDefinition, keys []string) (bson.D, error) {
+	var (
+		errs  AttributeErrors
+		order bson.D
+		id    bool
+	)
+	for _, key := range keys {
+		direction := 1
+		if k, ok := strings.CutPrefix(key, "-"); ok {
+			key, direction = k, -1
+		}
+		path := key
+		if name, ok := strings.CutPrefix(key, "attributes."); ok {
+			if _, ok = definitions[name]; !ok {
+				errs = append(errs, AttributeError{Field: "sort", Rule: "attribute", Param: known(definitions)})
+				continue
+			}
+		} else if path, ok = sortableField(key); !ok {
+			errs = append(errs, AttributeError{Field: "sort", Rule: "sort", Param: strings.Join(sortable, " ") + " attributes.<name>"})
+			continue
+		}
+		id = id || path == "_id"
+		order = append(order, bson.E{Key: path, Value: direction})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(order) > 0 && !id {
+		order = append(order, bson.E{Key: "_id", Value: 1})
+	}
+	return order, nil
+}
+
+func sortableField(name string) (string, bool) {
+	for _, field := range sortable {
+		if field == name {
+			return Fields[name], true
+		}
+	}
+	return "", false
+}
+
+// operators are the filter operators of the type.
+func operators(typ string) []string {
+	if typ == entity.AttributeTypeBoolean {
+		return []string{entity.FilterEqual, entity.FilterNotEqual}
+	}
+	return []string{
+		entity.FilterEqual, entity.FilterNotEqual,
+		entity.FilterGreaterThan, entity.FilterGreaterThanOrEqual,
+		entity.FilterLessThan, entity.FilterLessThanOrEqual,
+	}
+}
+
+// known lists the names of the defined attributes.
+func known(definitions map[string]entity.AttributeDefinition) string {
+	return strings.Join(sortedKeys(definitions), " ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// attributeIndexName is the name of the index of an attribute, shared by every tenant
+// defining it so that the indexes of the users collection grow with the names and not
+// with the tenants. A unique attribute is indexed on its copy in unique.<name>, held only
+// by the users of the tenants where it is unique.
+func attributeIndexName(name string, unique bool) string {
+	if unique {
+		return "attribute_" + name + "_unique"
+	}
+	return "attribute_" + name
+}
+
+// attributeIndex indexes a unique or indexed attribute for every tenant defining it.
+func (i *impl) attributeIndex(ctx context.Context, definition entity.AttributeDefinition) error {
+	var models []mongo.IndexModel
+	if definition.Unique {
+		path := "unique." + definition.Name
+		models = append(models, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "tenant_id", Value: 1},
+				{Key: path, Value: 1},
+			},
+			// users without a value must not collide on a unique attribute.
+			Options: options.Index().SetName(attributeIndexName(definition.Name, true)).SetUnique(true).
+				SetPartialFilterExpression(bson.D{{Key: path, Value: bson.D{{Key: "$exists", Value: true}}}}),
+		})
+	}
+	if definition.Indexed {
+		models = append(models, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "tenant_id", Value: 1},
+				{Key: "attributes." + definition.Name, Value: 1},
+			},
+			Options: options.Index().SetName(attributeIndexName(definition.Name, false)),
+		})
+	}
+	if len(models) == 0 {
+		return nil
+	}
+	_, err := i.adapter.TenantCollection(ctx, "users").Indexes().CreateMany(ctx, models)
+	return err
+}
+
+// dropAttributeIndexes drops the indexes of an attribute no tenant needs anymore.
+func (i *impl) dropAttributeIndexes(ctx context.Context, name string) error {
+	indexes := i.adapter.TenantCollection(ctx, "users").Indexes()
+	specs, err := indexes.ListSpecifications(ctx)
+	if err != nil {
+		return err
+	}
+	for _, unique := range []bool{true, false} {
+		index := attributeIndexName(name, unique)
+		if !adapters.HasIndex(specs, index) {
+			continue
+		}
+		flag := "indexed"
+		if unique {
+			flag = "unique"
+		}
+		// every tenant of the collection, not only the tenant of ctx.
+		n, err := i.adapter.TenantCollection(ctx, "user_attributes").CountDocuments(ctx,
+			bson.D{{Key: "name", Value: name}, {Key: flag, Value: true}}, options.Count().SetLimit(1))
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err = indexes.DropOne(ctx, index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uniqueAttributes are the copies of the checked attributes unique in the tenant, nil without any.
+func uniqueAttributes(definitions map[string]entity.AttributeDefinition, attributes map[string]any) map[string]any {
+	var unique map[string]any
+	for name, value := range attributes {
+		if !definitions[name].Unique {
+			continue
+		}
+		if unique == nil {
+			unique = map[string]any{}
+		}
+		unique[name] = value
+	}
+	return unique
+}
+
+// attributeIndexes keeps attribute names unique per tenant.
+func (i *impl) attributeIndexes(ctx context.Context) error {
+	_, err := i.adapter.TenantCollection(ctx, "user_attributes").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "tenant_id", Value: 1},
+			{Key: "name", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// duplicateError maps a duplicate key on the index of a unique attribute into its
+// AttributeError, any other duplicate is the blind index of the email.
+func duplicateError(err error, definitions map[string]entity.AttributeDefinition) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	for name, definition := range definitions {
+		if definition.Unique && strings.Contains(err.Error(), "index: "+attributeIndexName(name, true)+" ") {
+			return AttributeErrors{{Field: "attributes." + name, Rule: "unique"}}
+		}
+	}
+	return emailError(err)
+}
@@ -0,0 +1,110 @@
+// Package users implement all logic.
+package users
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+)
+
+var testDefinitions = map[string]entity.AttributeDefinition{
+	"tier":     {Name: "tier", Type: entity.AttributeTypeString, Required: true, Unique: true},
+	"score":    {Name: "score", Type: entity.AttributeTypeNumber},
+	"verified": {Name: "verified", Type: entity.AttributeTypeBoolean},
+	"joined":   {Name: "joined", Type: entity.AttributeTypeDate},
+}
+
+func TestCheckAttributes(t *testing.T) {
+	_, err := checkAttributes(testDefinitions, map[string]any{
+		"tier": "gold", "score": 10.5, "verified": true, "joined": "2023-07-01", "score_x": nil,
+	})
+	var errs AttributeErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "attributes.score_x" || errs[0].Rule != "attribute" {
+		t.Fatalf("expected the unknown attribute to fail, got %v", err)
+	}
+
+	checked, err := checkAttributes(testDefinitions, map[string]any{
+		"tier": "gold", "score": 10, "verified": nil, "joined": "2023-07-01",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if checked["score"] != 10.0 || checked["joined"] != time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC) {
+		t.Fatalf("expected values of the stored types, got %v", checked)
+	}
+	if _, ok := checked["verified"]; ok {
+		t.Fatalf("expected null values to be dropped, got %v", checked)
+	}
+
+	_, err = checkAttributes(testDefinitions, map[string]any{"score": "ten"})
+	if !errors.As(err, &errs) || len(errs) != 2 ||
+		errs[0] != (AttributeError{Field: "attributes.score", Rule: "type", Param: "number"}) ||
+		errs[1] != (AttributeError{Field: "attributes.tier", Rule: "required"}) {
+		t.Fatalf("expected the type and the required attribute to fail, got %v", err)
+	}
+}
+
+func TestAttributeListing(t *testing.T) {
+	conditions, err := attributeFilter(testDefinitions, []entity.AttributeFilter{
+		{Name: "score", Operator: entity.FilterGreaterThanOrEqual, Value: "10"},
+		{Name: "verified", Operator: entity.FilterEqual, Value: "true"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := bson.D{{Key: "attributes.score", Value: bson.D{{Key: "$gte", Value: 10.0}}}}
+	if len(conditions) != 2 || conditions[0].(bson.D)[0].Key != want[0].Key ||
+		conditions[0].(bson.D)[0].Value.(bson.D)[0] != want[0].Value.(bson.D)[0] {
+		t.Fatalf("expected %v first, got %v", want, conditions)
+	}
+	_, err = attributeFilter(testDefinitions, []entity.AttributeFilter{
+		{Name: "verified", Operator: entity.FilterGreaterThan, Value: "true"},
+		{Name: "level", Operator: entity.FilterEqual, Value: "1"},
+	})
+	var errs AttributeErrors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Rule != "operator" || errs[1].Rule != "attribute" {
+		t.Fatalf("expected the operator and the attribute to fail, got %v", err)
+	}
+
+	order, err := sortOrder(testDefinitions, []string{"-attributes.score", "name"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(order) != 3 || order[0] != (bson.E{Key: "attributes.score", Value: -1}) || order[2].Key != "_id" {
+		t.Fatalf("expected a descending score then name and id, got %v", order)
+	}
+	if _, err = sortOrder(testDefinitions, []string{"email"}); !errors.As(err, &errs) || errs[0].Rule != "sort" {
+		t.Fatalf("expected email not to be sortable, got %v", err)
+	}
+}
+
+func TestDuplicateError(t *testing.T) {
+	duplicate := func(index string) error {
+		return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+			Code:    11000,
+			Message: "E11000 duplicate key error collection: test.users index: " + index + " dup key: { : \"gold\" }",
+		}}}
+	}
+	var errs AttributeErrors
+	if err := duplicateError(duplicate("attribute_tier_unique"), testDefinitions); !errors.As(err, &errs) || errs[0].Rule != "unique" {
+		t.Fatalf("expected the unique attribute to fail, got %v", err)
+	}
+	if err := duplicateError(duplicate("tenant_id_1_email_index_1"), testDefinitions); !errors.Is(err, ErrEmailExists) {
+		t.Fatalf("expected ErrEmailExists, got %v", err)
+	}
+}
+
+func TestUniqueAttributes(t *testing.T) {
+	unique := uniqueAttributes(testDefinitions, map[string]any{"tier": "gold", "score": 10.0})
+	if len(unique) != 1 || unique["tier"] != "gold" {
+		t.Fatalf("expected only the unique tier, got %v", unique)
+	}
+	if unique = uniqueAttributes(testDefinitions, map[string]any{"score": 10.0}); unique != nil {
+		t.Fatalf("expected no unique attributes, got %v", unique)
+	}
+}
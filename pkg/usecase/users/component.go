@@ -3,10 +3,17 @@ package users
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	"github.com/rs/zerolog/log"
+
 	"github.com/kubuskotak/ymir-test/pkg/adapters"
 	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/infrastructure"
+	"github.com/kubuskotak/ymir-test/pkg/shared/fieldcrypt"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
 	"github.com/kubuskotak/ymir-test/pkg/usecase"
 )
 
@@ -24,17 +31,127 @@ func init() {
 type T interface {
 	GetAll(ctx context.Context, paging entity.RequestGetUsers) (entity.ResponseGetUsers, error)
 	Create(ctx context.Context, user entity.User) (entity.User, error)
-	GetByID(ctx context.Context, userID string) (entity.User, error)
+	GetByID(ctx context.Context, userID string, fields ...string) (entity.User, error)
+	GetByIDs(ctx context.Context, userIDs []string) ([]entity.User, error)
 	DeleteByID(ctx context.Context, userID string) error
 	UpdateByID(ctx context.Context, user entity.User) (entity.User, error)
+	GetRevision(ctx context.Context, userID string, revision int) (entity.UserRevision, error)
+	Revert(ctx context.Context, userID string, revision int) (entity.User, error)
+	GetByEmail(ctx context.Context, email string) (entity.User, error)
+	ReEncrypt(ctx context.Context) (entity.ReEncryptResult, error)
+	Export(ctx context.Context, userID string) (entity.UserExport, error)
+	Erase(ctx context.Context, userID string) (entity.ErasureReceipt, error)
+	DefineAttribute(ctx context.Context, definition entity.AttributeDefinition) (entity.AttributeDefinition, error)
+	ListAttributes(ctx context.Context) ([]entity.AttributeDefinition, error)
+	RemoveAttribute(ctx context.Context, name string) error
+	Search(ctx context.Context, request entity.RequestSearchUsers) (entity.ResponseSearchUsers, error)
+	Reindex(ctx context.Context) (int, error)
+	AssignTenant(ctx context.Context, id string) (map[string]int64, error)
+	OnDelete(cascade Cascade)
+	OnExport(exporter Exporter)
+	OnErase(purge Cascade)
+	Migrated(ctx context.Context) error
 }
 
+// Cascade removes what another component holds about a user being deleted or erased,
+// it returns the collection it removed from and how many documents.
+type Cascade func(ctx context.Context, userID string) (collection string, removed int, err error)
+
+// Exporter reads what another component holds about a user being exported or erased,
+// it returns the collection it read from and the documents.
+type Exporter func(ctx context.Context, userID string) (collection string, documents any, err error)
+
 type impl struct {
-	adapter *adapters.Adapter
+	adapter   *adapters.Adapter
+	keyring   *fieldcrypt.Keyring
+	reads     *readRouting
+	tenancy   tenant.Guard
+	domains   bool // the email domains are kept in clear for the stats
+	cascades  []Cascade
+	exporters []Exporter
+	purges    []Cascade
 }
 
 // Init initializes the execution of a process involved in a users Component usecase.
 func (i *impl) Init(adapter *adapters.Adapter) error {
 	i.adapter = adapter
+	crypto := infrastructure.Envs.Crypto
+	keyring, err := fieldcrypt.Load(fieldcrypt.Options{
+		KeyFile:   crypto.KeyFile,
+		Keys:      crypto.Keys,
+		ActiveKey: crypto.ActiveKey,
+		IndexKey:  crypto.IndexKey,
+	})
+	if err != nil {
+		return err
+	}
+	if !keyring.Enabled() {
+		log.Warn().Msg("field encryption keys are not configured, user emails are stored in plaintext")
+	}
+	i.keyring = keyring
+	i.domains = infrastructure.Envs.Stats.Domains
+	reads := infrastructure.Envs.Reads
+	if i.reads, err = newReadRouting(reads.Listing, reads.MaxStaleness, reads.Causal, reads.CausalWindow); err != nil {
+		return err
+	}
+	tenants := infrastructure.Envs.Tenancy
+	i.tenancy.Required = tenants.Enable
+	if tenants.Enable && tenants.DatabasePerTenant {
+		i.tenancy.Migrate = i.migrate
+	}
+	// deferred until Mongo is up when the service starts degraded.
+	return adapter.UserDataMongo.WhenConnected(i.migrate)
+}
+
+// OnDelete runs cascade in the transaction deleting a user, and when a user is erased.
+// Cascades are registered while the service starts, before any request is served.
+func (i *impl) OnDelete(cascade Cascade) {
+	i.cascades = append(i.cascades, cascade)
+}
+
+// OnExport adds the documents of exporter to the export of a user, and to the digest
+// of its erasure receipt.
+func (i *impl) OnExport(exporter Exporter) {
+	i.exporters = append(i.exporters, exporter)
+}
+
+// OnErase runs purge before the transaction erasing a user, for data kept outside of
+// the collections the transaction spans.
+func (i *impl) OnErase(purge Cascade) {
+	i.purges = append(i.purges, purge)
+}
+
+// migrate creates the indexes of the database of the tenant of ctx.
+func (i *impl) migrate(ctx context.Context) error {
+	if err := i.emailIndexes(ctx); err != nil {
+		return err
+	}
+	if err := i.revisionIndexes(ctx); err != nil {
+		return err
+	}
+	if err := i.attributeIndexes(ctx); err != nil {
+		return err
+	}
+	return i.searchIndexes(ctx)
+}
+
+// Migrated reports an error when an index created by Init is missing.
+func (i *impl) Migrated(ctx context.Context) error {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.Migrated")
+	defer span.End()
+
+	for coll, index := range map[string]string{
+		"users":           "tenant_id_1_email_index_1",
+		"user_revisions":  "user_id_1_revision_1",
+		"user_attributes": "tenant_id_1_name_1",
+	} {
+		specs, err := i.adapter.TenantCollection(ctx, coll).Indexes().ListSpecifications(ctx)
+		if err != nil {
+			return err
+		}
+		if !adapters.HasIndex(specs, index) {
+			return fmt.Errorf("index %s of %s is missing", index, coll)
+		}
+	}
 	return nil
 }
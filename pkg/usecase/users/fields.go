@@ -0,0 +1,60 @@
+// Package users implement all logic.
+package users
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+)
+
+// ErrUnknownField is returned when a projection names a field entity.User does not have.
+var ErrUnknownField = errors.New("unknown user field")
+
+// Fields maps the json names of the entity.User fields clients can select on their bson names.
+var Fields = func() map[string]string {
+	fields := map[string]string{}
+	t := reflect.TypeOf(entity.User{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name], _, _ = strings.Cut(f.Tag.Get("bson"), ",")
+	}
+	return fields
+}()
+
+// projection maps json field names on a Mongo projection, nil selects every field
+// but the search tokens. The id is only returned when it is selected.
+func projection(fields []string) (bson.D, error) {
+	if len(fields) == 0 {
+		return bson.D{{Key: "search", Value: 0}}, nil
+	}
+	var (
+		p  bson.D
+		id bool
+	)
+	seen := map[string]bool{}
+	for _, field := range fields {
+		name, ok := Fields[field]
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownField, field)
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		id = id || name == "_id"
+		p = append(p, bson.E{Key: name, Value: 1})
+	}
+	if !id {
+		p = append(p, bson.E{Key: "_id", Value: 0})
+	}
+	return p, nil
+}
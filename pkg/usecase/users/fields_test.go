@@ -0,0 +1,71 @@
+// Package users implement all logic.
+package users
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+)
+
+func TestProjection(t *testing.T) {
+	p, err := projection([]string{"name", "created_at", "name"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := bson.D{{Key: "name", Value: 1}, {Key: "created_at", Value: 1}, {Key: "_id", Value: 0}}
+	if len(p) != len(want) {
+		t.Fatalf("expected %v, got %v", want, p)
+	}
+	for i := range want {
+		if p[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, p)
+		}
+	}
+	if p, _ = projection([]string{"id"}); len(p) != 1 || p[0].Key != "_id" {
+		t.Fatalf("expected only the id, got %v", p)
+	}
+	if _, err = projection([]string{"email_index"}); !errors.Is(err, ErrUnknownField) {
+		t.Fatalf("expected ErrUnknownField, got %v", err)
+	}
+}
+
+func TestGetAllProjection(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("pushed down", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		tt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: "64a7f1f2c2a4b1e0d4b3c2a1"},
+			{Key: "name", Value: "john"},
+		}))
+
+		result, err := uc.GetAll(context.Background(), entity.RequestGetUsers{
+			Pagination: entity.Pagination{Page: 1, Limit: 10},
+			Fields:     []string{"id", "name"},
+		})
+		if err != nil {
+			tt.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.Users) != 1 || result.Users[0].Name != "john" || result.Users[0].CreatedAt != nil {
+			tt.Fatalf("unexpected users: %+v", result.Users)
+		}
+		started := tt.GetStartedEvent()
+		if started == nil || started.CommandName != "find" {
+			tt.Fatal("expected a find command")
+		}
+		project := started.Command.Lookup("projection").Document()
+		if _, err = project.LookupErr("name"); err != nil {
+			tt.Fatalf("expected name in the projection, got %v", project)
+		}
+		if _, err = project.LookupErr("email"); err == nil {
+			tt.Fatalf("expected email to be left out, got %v", project)
+		}
+	})
+}
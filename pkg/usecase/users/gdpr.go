@@ -0,0 +1,207 @@
+// Package users implement all logic.
+package users
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/shared/metrics"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+// ErrUserErased is returned when the personal data of the user was erased.
+var ErrUserErased = errors.New("personal data of this user was erased")
+
+func (i *impl) Export(ctx context.Context, userID string) (entity.UserExport, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.Export")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return entity.UserExport{}, err
+	}
+	return i.export(ctx, userID, i.listingCollection)
+}
+
+// export reads the personal data of the user from the collections given by collection.
+func (i *impl) export(ctx context.Context, userID string, collection func(ctx context.Context, name string) *mongo.Collection) (entity.UserExport, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return entity.UserExport{}, err
+	}
+
+	export := entity.UserExport{UserID: userID, Revisions: make([]entity.UserRevision, 0)}
+	var user entity.User
+	err = collection(ctx, "users").FindOne(ctx, adapters.Scope(ctx, bson.D{{Key: "_id", Value: id}})).Decode(&user)
+	switch {
+	case err == nil:
+		if user, err = i.open(user); err != nil {
+			return entity.UserExport{}, err
+		}
+		export.Profile = &user
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return entity.UserExport{}, err
+	}
+
+	cursor, err := collection(ctx, "user_revisions").Find(ctx, adapters.Scope(ctx, bson.D{{Key: "user_id", Value: userID}}),
+		options.Find().SetSort(bson.D{{Key: "revision", Value: 1}}))
+	if err != nil {
+		return entity.UserExport{}, err
+	}
+	defer func(c context.Context) {
+		_ = cursor.Close(c)
+	}(ctx)
+
+	for cursor.Next(ctx) {
+		var rev entity.UserRevision
+		if err = cursor.Decode(&rev); err != nil {
+			return entity.UserExport{}, err
+		}
+		if rev.Document, err = i.open(rev.Document); err != nil {
+			return entity.UserExport{}, err
+		}
+		export.Revisions = append(export.Revisions, rev)
+	}
+	if err = cursor.Err(); err != nil {
+		return entity.UserExport{}, err
+	}
+
+	for _, exporter := range i.exporters {
+		collection, documents, err := exporter(ctx, userID)
+		if err != nil {
+			return entity.UserExport{}, err
+		}
+		if export.Related == nil {
+			export.Related = make(map[string]any)
+		}
+		export.Related[collection] = documents
+	}
+
+	if export.Profile == nil && len(export.Revisions) == 0 {
+		return entity.UserExport{}, fmt.Errorf("no document with id %v was found: %w", userID, mongo.ErrNoDocuments)
+	}
+	export.ExportedAt = time.Now()
+	return export, nil
+}
+
+func (i *impl) Erase(ctx context.Context, userID string) (entity.ErasureReceipt, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.Erase")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return entity.ErasureReceipt{}, err
+	}
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return entity.ErasureReceipt{}, err
+	}
+
+	ctx, done, err := i.writeSession(ctx)
+	if err != nil {
+		return entity.ErasureReceipt{}, err
+	}
+	defer done("")
+
+	// Purged data lives outside of the transaction and holds no more than copies of
+	// responses, it goes first so that no copy outlives a committed erasure.
+	purged := make(map[string]int)
+	for _, purge := range i.purges {
+		collection, removed, err := purge(ctx, userID)
+		if err != nil {
+			return entity.ErasureReceipt{}, err
+		}
+		purged[collection] += removed
+	}
+
+	// The digest, the deletes, the anonymized revisions and the receipt commit together,
+	// a failed step must not leave personal data behind without a receipt.
+	var receipt entity.ErasureReceipt
+	err = i.adapter.WithTransaction(ctx, func(ctx mongo.SessionContext) error {
+		// the digest must cover the latest state, read it from the primary.
+		export, err := i.export(ctx, userID, i.adapter.TenantCollection)
+		if err != nil {
+			return err
+		}
+		// the receipt keeps the time of the hashed bundle, at the precision it is stored
+		// with, so that the digest can be recomputed from the same data.
+		export.ExportedAt = export.ExportedAt.UTC().Truncate(time.Millisecond)
+		bundle, err := json.Marshal(export)
+		if err != nil {
+			return err
+		}
+		digest := sha256.Sum256(bundle)
+
+		receipt = entity.ErasureReceipt{
+			TenantID:    tenant.FromContext(ctx),
+			UserID:      userID,
+			Collections: make(map[string]int, len(purged)),
+			Digest:      hex.EncodeToString(digest[:]),
+			ExportedAt:  export.ExportedAt,
+		}
+
+		deleted, err := i.adapter.TenantCollection(ctx, "users").
+			DeleteOne(ctx, adapters.Scope(ctx, bson.D{{Key: "_id", Value: id}}))
+		if err != nil {
+			return err
+		}
+		receipt.Collections["users"] = int(deleted.DeletedCount)
+		for collection, removed := range purged {
+			receipt.Collections[collection] += removed
+		}
+
+		for _, cascade := range i.cascades {
+			collection, removed, err := cascade(ctx, userID)
+			if err != nil {
+				return err
+			}
+			receipt.Collections[collection] += removed
+		}
+
+		// Revisions stay as the audit trail, only the personal snapshot is dropped.
+		anonymized, err := i.adapter.TenantCollection(ctx, "user_revisions").UpdateMany(ctx,
+			adapters.Scope(ctx, bson.D{{Key: "user_id", Value: userID}}),
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "document", Value: bson.D{{Key: "_id", Value: userID}}},
+			}}},
+		)
+		if err != nil {
+			return err
+		}
+		receipt.Collections["user_revisions"] = int(anonymized.ModifiedCount)
+
+		receipt.ErasedAt = time.Now()
+		result, err := i.adapter.TenantCollection(ctx, "erasure_receipts").InsertOne(ctx, receipt)
+		if err != nil {
+			return err
+		}
+		if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+			receipt.ID = oid.Hex()
+		}
+		return nil
+	})
+	if err != nil {
+		return entity.ErasureReceipt{}, err
+	}
+	metrics.UsersDeleted.WithLabelValues("erase").Add(float64(receipt.Collections["users"]))
+	done(userID)
+	return receipt, nil
+}
+
+// erased reports whether an erasure receipt exists for the user.
+func (i *impl) erased(ctx context.Context, userID string) (bool, error) {
+	n, err := i.adapter.TenantCollection(ctx, "erasure_receipts").
+		CountDocuments(ctx, adapters.Scope(ctx, bson.D{{Key: "user_id", Value: userID}}), options.Count().SetLimit(1))
+	return n > 0, err
+}
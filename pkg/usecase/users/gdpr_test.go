@@ -0,0 +1,195 @@
+// Package users implement all logic.
+package users
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+)
+
+const testUserID = "64a7f1f2c2a4b1e0d4b3c2a1"
+
+func TestExport(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("profile and revisions", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		uc.OnExport(func(_ context.Context, userID string) (string, any, error) {
+			return "group_members", []entity.GroupMember{{GroupID: "g1", UserID: userID}}, nil
+		})
+		addUserData(tt)
+
+		export, err := uc.Export(context.Background(), testUserID)
+		if err != nil {
+			tt.Fatalf("unexpected error: %v", err)
+		}
+		if export.Profile == nil || export.Profile.Name != "john" || len(export.Revisions) != 2 ||
+			export.Revisions[1].Document.Name != "john" || export.ExportedAt.IsZero() {
+			tt.Fatalf("unexpected export %+v", export)
+		}
+		if members, _ := export.Related["group_members"].([]entity.GroupMember); len(members) != 1 || members[0].GroupID != "g1" {
+			tt.Fatalf("expected the memberships to be exported, got %+v", export.Related)
+		}
+	})
+
+	mt.Run("unknown user", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		tt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.user_revisions", mtest.FirstBatch),
+		)
+
+		if _, err := uc.Export(context.Background(), testUserID); !errors.Is(err, mongo.ErrNoDocuments) {
+			tt.Fatalf("expected ErrNoDocuments, got %v", err)
+		}
+	})
+}
+
+func TestErase(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("receipt", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		uc.OnDelete(func(context.Context, string) (string, int, error) {
+			return "group_members", 2, nil
+		})
+		var purgedInTransaction bool
+		uc.OnErase(func(ctx context.Context, _ string) (string, int, error) {
+			purgedInTransaction = adapters.InTransaction(ctx)
+			return "idempotency_keys", 3, nil
+		})
+		tt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "setName", Value: "rs0"}))
+		addUserData(tt)
+		tt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 2}, {Key: "nModified", Value: 2}},
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		receipt, err := uc.Erase(context.Background(), testUserID)
+		if err != nil {
+			tt.Fatalf("unexpected error: %v", err)
+		}
+		if purgedInTransaction {
+			tt.Fatal("expected the purge to run before the transaction")
+		}
+		want := map[string]int{"users": 1, "group_members": 2, "user_revisions": 2, "idempotency_keys": 3}
+		if len(receipt.Collections) != len(want) || receipt.ID == "" || receipt.ErasedAt.IsZero() {
+			tt.Fatalf("unexpected receipt %+v", receipt)
+		}
+		for collection, n := range want {
+			if receipt.Collections[collection] != n {
+				tt.Fatalf("expected %d documents of %s, got %+v", n, collection, receipt.Collections)
+			}
+		}
+		var anonymized, inserted bson.Raw
+		var committed bool
+		for event := tt.GetStartedEvent(); event != nil; event = tt.GetStartedEvent() {
+			switch event.CommandName {
+			case "find":
+				if _, err := event.Command.LookupErr("startTransaction"); err != nil && event.Command.Lookup("find").StringValue() == "users" {
+					tt.Fatal("expected the erasure to start a transaction with its first read")
+				}
+			case "commitTransaction":
+				committed = true
+			case "update":
+				anonymized = event.Command.Lookup("updates").Array().Index(0).Value().Document()
+			case "insert":
+				inserted = event.Command.Lookup("documents").Array().Index(0).Value().Document()
+			}
+		}
+		if !committed {
+			tt.Fatal("expected the erasure to commit a transaction")
+		}
+		if document := anonymized.Lookup("u", "$set", "document").Document(); document.Lookup("_id").StringValue() != testUserID {
+			tt.Fatalf("expected the snapshots to keep only the id, got %v", document)
+		}
+		if inserted.Lookup("digest").StringValue() != receipt.Digest ||
+			!inserted.Lookup("exported_at").Time().Equal(receipt.ExportedAt) {
+			tt.Fatalf("expected the receipt to be stored, got %v", inserted)
+		}
+
+		// the same data exported at the time of the receipt has its digest.
+		addUserData(tt)
+		export, err := uc.Export(context.Background(), testUserID)
+		if err != nil {
+			tt.Fatalf("unexpected error: %v", err)
+		}
+		export.ExportedAt = inserted.Lookup("exported_at").Time().UTC()
+		bundle, err := json.Marshal(export)
+		if err != nil {
+			tt.Fatal(err)
+		}
+		if digest := sha256.Sum256(bundle); hex.EncodeToString(digest[:]) != receipt.Digest {
+			tt.Fatalf("expected the digest to be recomputed from the stored receipt")
+		}
+	})
+
+	mt.Run("revert of an erased user", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		tt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "setName", Value: "rs0"}),
+			mtest.CreateCursorResponse(0, "test.erasure_receipts", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: 1}, {Key: "n", Value: 1}}),
+		)
+
+		if _, err := uc.Revert(context.Background(), testUserID, 1); !errors.Is(err, ErrUserErased) {
+			tt.Fatalf("expected ErrUserErased, got %v", err)
+		}
+		tt.GetStartedEvent()
+		// an erasure committing after the check must conflict with the restore.
+		counted := tt.GetStartedEvent()
+		if counted == nil || counted.CommandName != "aggregate" {
+			tt.Fatalf("expected the erasure to be checked, got %v", counted)
+		}
+		if _, err := counted.Command.LookupErr("startTransaction"); err != nil {
+			tt.Fatal("expected the erasure to be checked in the transaction")
+		}
+	})
+}
+
+// addUserData mocks the reads of an export: the user then two of its revisions.
+func addUserData(tt *mtest.T) {
+	id, _ := primitive.ObjectIDFromHex(testUserID)
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tt.AddMockResponses(
+		mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: id},
+			{Key: "name", Value: "john"},
+			{Key: "email", Value: "john@example.com"},
+			{Key: "age", Value: 30},
+			{Key: "created_at", Value: created},
+		}),
+		mtest.CreateCursorResponse(0, "test.user_revisions", mtest.FirstBatch,
+			bson.D{
+				{Key: "user_id", Value: testUserID},
+				{Key: "revision", Value: 1},
+				{Key: "action", Value: entity.RevisionActionCreate},
+				{Key: "document", Value: bson.D{{Key: "name", Value: "jon"}}},
+				{Key: "created_at", Value: created},
+			},
+			bson.D{
+				{Key: "user_id", Value: testUserID},
+				{Key: "revision", Value: 2},
+				{Key: "action", Value: entity.RevisionActionUpdate},
+				{Key: "document", Value: bson.D{{Key: "name", Value: "john"}}},
+				{Key: "created_at", Value: created.Add(time.Hour)},
+			},
+		),
+	)
+}
@@ -0,0 +1,175 @@
+// Package users implement all logic.
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+)
+
+// ErrEmailExists is returned when the email is already used by another user.
+var ErrEmailExists = errors.New("user with this email already exists")
+
+// seal encrypts the personal fields of the user and sets the blind index, the email
+// domain when the stats count them and the search tokens, which are taken from the
+// plain fields.
+func (i *impl) seal(user entity.User) (entity.User, error) {
+	user.Search = i.searchTokens(user)
+	user.EmailDomain = ""
+	if i.domains {
+		user.EmailDomain = emailDomain(user.Email)
+	}
+	if user.Email == "" {
+		return user, nil
+	}
+	email, err := i.keyring.Encrypt(user.Email)
+	if err != nil {
+		return entity.User{}, err
+	}
+	user.EmailIndex = i.keyring.BlindIndex(user.Email)
+	user.Email = email
+	return user, nil
+}
+
+// open decrypts the personal fields of a stored user.
+func (i *impl) open(user entity.User) (entity.User, error) {
+	email, err := i.keyring.Decrypt(user.Email)
+	if err != nil {
+		return entity.User{}, fmt.Errorf("failed to decrypt email of user %v: %w", user.ID, err)
+	}
+	user.Email = email
+	user.EmailIndex = ""
+	user.EmailDomain = ""
+	user.Search = nil
+	user.Attributes = openAttributes(user.Attributes)
+	user.Unique = nil
+	return user, nil
+}
+
+// emailDomain is the lowercase domain of an email, empty when it has none.
+func emailDomain(email string) string {
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[at+1:]))
+}
+
+func (i *impl) GetByEmail(ctx context.Context, email string) (entity.User, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.GetByEmail")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return entity.User{}, err
+	}
+	coll := i.adapter.TenantCollection(ctx, "users")
+
+	filter := adapters.Scope(ctx, bson.D{{Key: "email_index", Value: i.keyring.BlindIndex(email)}})
+
+	var user entity.User
+	err := coll.FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		return entity.User{}, err
+	}
+	return i.open(user)
+}
+
+func (i *impl) ReEncrypt(ctx context.Context) (entity.ReEncryptResult, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.ReEncrypt")
+	defer span.End()
+
+	var (
+		result entity.ReEncryptResult
+		err    error
+	)
+	if !i.keyring.Enabled() {
+		return result, errors.New("field encryption keys are not configured")
+	}
+	// without a tenant in ctx every tenant of the shared database is re-encrypted.
+	if result.Users, err = i.reEncryptCollection(ctx, "users", "email", "email_index"); err != nil {
+		return result, err
+	}
+	if result.Revisions, err = i.reEncryptCollection(ctx, "user_revisions", "document.email", "document.email_index"); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// reEncryptCollection re-seals the email at path with the active key and refreshes its blind index.
+func (i *impl) reEncryptCollection(ctx context.Context, name, path, indexPath string) (updated int, err error) {
+	coll := i.adapter.TenantCollection(ctx, name)
+
+	cursor, err := coll.Find(ctx, adapters.Scope(ctx, bson.D{{Key: path, Value: bson.D{{Key: "$exists", Value: true}}}}),
+		options.Find().SetProjection(bson.D{{Key: path, Value: 1}}))
+	if err != nil {
+		return 0, err
+	}
+	defer func(c context.Context) {
+		_ = cursor.Close(c)
+	}(ctx)
+
+	for cursor.Next(ctx) {
+		var (
+			id      = cursor.Current.Lookup("_id")
+			current string
+		)
+		if err = cursor.Current.Lookup(strings.Split(path, ".")...).Unmarshal(&current); err != nil {
+			return updated, err
+		}
+		if !i.keyring.NeedsRotation(current) {
+			continue
+		}
+		plain, err := i.keyring.Decrypt(current)
+		if err != nil {
+			return updated, fmt.Errorf("%s %v: %w", name, id, err)
+		}
+		sealed, err := i.keyring.Encrypt(plain)
+		if err != nil {
+			return updated, err
+		}
+		_, err = coll.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: path, Value: sealed},
+				{Key: indexPath, Value: i.keyring.BlindIndex(plain)},
+			}},
+		})
+		if err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, cursor.Err()
+}
+
+// emailIndexes keeps the blind index unique per tenant, documents stored before encryption are skipped.
+func (i *impl) emailIndexes(ctx context.Context) error {
+	coll := i.adapter.TenantCollection(ctx, "users")
+
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "tenant_id", Value: 1},
+			{Key: "email_index", Value: 1},
+		},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.D{
+			{Key: "email_index", Value: bson.D{{Key: "$exists", Value: true}}},
+		}),
+	})
+	return err
+}
+
+// emailError maps a duplicate blind index into ErrEmailExists.
+func emailError(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrEmailExists
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+// Package users implement all logic.
+package users
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/shared/causal"
+)
+
+// defaultCausalWindow is how long a write is tracked when no window is configured.
+const defaultCausalWindow = 5 * time.Minute
+
+// readRouting sends listings to the configured read preference and keeps reads
+// by id causally consistent with the last write of the user in this process, or
+// with the write token of ctx the client got from any process, see causal.Header.
+// A nil readRouting reads everything from the connection default.
+type readRouting struct {
+	listing *readpref.ReadPref
+	causal  bool
+	window  time.Duration
+
+	mu     sync.Mutex
+	writes map[string]writeToken
+	pruned time.Time
+}
+
+// writeToken is the session state after a write, a session advanced to it
+// reads that write even from a secondary.
+type writeToken struct {
+	cluster   bson.Raw
+	operation *primitive.Timestamp
+	at        time.Time
+}
+
+func newReadRouting(listing string, maxStaleness time.Duration, causal bool, window time.Duration) (*readRouting, error) {
+	r := &readRouting{causal: causal, window: window, writes: map[string]writeToken{}}
+	if r.window <= 0 {
+		r.window = defaultCausalWindow
+	}
+	var err error
+	if r.listing, err = adapters.ReadPreference(listing, maxStaleness); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// listingCollection is the collection of the tenant of ctx read with the listing preference.
+func (i *impl) listingCollection(ctx context.Context, name string) *mongo.Collection {
+	if i.reads == nil {
+		return i.adapter.TenantCollection(ctx, name)
+	}
+	return i.adapter.ReadCollection(ctx, name, i.reads.listing)
+}
+
+// writeSession starts a causally consistent session for a write when causal reads are on.
+// The first call of done ends the session and tracks the write of the user, unless the
+// id is empty. Deferring done("") ends the session of a failed write. The session
+// only works on the client of users, the collections written in it must be among
+// adapters.SessionCollections, which cannot move to another connection.
+func (i *impl) writeSession(ctx context.Context) (context.Context, func(userID string), error) {
+	if i.reads == nil || !i.reads.causal {
+		return ctx, func(string) {}, nil
+	}
+	sess, err := i.adapter.TenantCollection(ctx, "users").Database().Client().
+		StartSession(options.Session().SetCausalConsistency(true))
+	if err != nil {
+		return ctx, nil, err
+	}
+	var ended bool
+	return mongo.NewSessionContext(ctx, sess), func(userID string) {
+		if ended {
+			return
+		}
+		ended = true
+		if userID != "" {
+			i.reads.track(userID, writeToken{cluster: sess.ClusterTime(), operation: sess.OperationTime(), at: time.Now()})
+			if operation := sess.OperationTime(); operation != nil {
+				causal.Record(ctx, *operation)
+			}
+		}
+		sess.EndSession(ctx)
+	}, nil
+}
+
+// readYourWrites runs fn on the listing collection of users, in a session advanced
+// past the last tracked write of the user or the write token of ctx, whichever is
+// later. Without causal reads fn reads the default.
+func (i *impl) readYourWrites(ctx context.Context, userID string, fn func(ctx context.Context, coll *mongo.Collection) error) error {
+	if i.reads == nil || !i.reads.causal {
+		return fn(ctx, i.adapter.TenantCollection(ctx, "users"))
+	}
+	coll := i.listingCollection(ctx, "users")
+	token, ok := i.reads.token(userID)
+	if after, found := causal.FromContext(ctx); found && (!ok || after.After(*token.operation)) {
+		// the cluster time is signed by the servers, the client only holds the operation time.
+		token, ok = writeToken{operation: &after}, true
+	}
+	if !ok {
+		return fn(ctx, coll)
+	}
+	sess, err := coll.Database().Client().StartSession(options.Session().SetCausalConsistency(true))
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(ctx)
+	if token.cluster != nil {
+		if err = sess.AdvanceClusterTime(token.cluster); err != nil {
+			return err
+		}
+	}
+	if err = sess.AdvanceOperationTime(token.operation); err != nil {
+		return err
+	}
+	return mongo.WithSession(ctx, sess, func(sc mongo.SessionContext) error {
+		return fn(sc, coll)
+	})
+}
+
+func (r *readRouting) track(userID string, token writeToken) {
+	if token.cluster == nil || token.operation == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writes[userID] = token
+	if time.Since(r.pruned) < r.window {
+		return
+	}
+	for id, t := range r.writes {
+		if time.Since(t.at) > r.window {
+			delete(r.writes, id)
+		}
+	}
+	r.pruned = time.Now()
+}
+
+func (r *readRouting) token(userID string) (writeToken, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.writes[userID]
+	if !ok || time.Since(token.at) > r.window {
+		return writeToken{}, false
+	}
+	return token, true
+}
@@ -0,0 +1,75 @@
+// Package users implement all logic.
+package users
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/shared/causal"
+)
+
+func TestReadRouting(t *testing.T) {
+	if _, err := newReadRouting("anywhere", 0, false, 0); err == nil {
+		t.Fatal("expected error for an unknown read preference")
+	}
+
+	r, err := newReadRouting("secondaryPreferred", 90*time.Second, true, time.Minute)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if staleness, _ := r.listing.MaxStaleness(); r.listing.Mode() != readpref.SecondaryPreferredMode || staleness != 90*time.Second {
+		t.Fatalf("unexpected listing preference %v", r.listing)
+	}
+
+	cluster, _ := bson.Marshal(bson.D{{Key: "clusterTime", Value: primitive.Timestamp{T: 10}}})
+	r.track("a", writeToken{cluster: cluster, operation: &primitive.Timestamp{T: 10}, at: time.Now()})
+	r.track("b", writeToken{cluster: cluster, operation: &primitive.Timestamp{T: 9}, at: time.Now().Add(-2 * time.Minute)})
+	r.track("c", writeToken{at: time.Now()}) // unacknowledged writes carry no times
+
+	if token, ok := r.token("a"); !ok || token.operation.T != 10 {
+		t.Fatalf("expected the write of a to be tracked, got %+v", token)
+	}
+	if _, ok := r.token("b"); ok {
+		t.Fatal("writes older than the window must not be used")
+	}
+	if _, ok := r.token("c"); ok {
+		t.Fatal("writes without session times must not be tracked")
+	}
+}
+
+func TestReadAfterClientToken(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("token of another replica", func(tt *mtest.T) {
+		r, err := newReadRouting("", 0, true, time.Minute)
+		if err != nil {
+			tt.Fatalf("new: %v", err)
+		}
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}, reads: r}
+		tt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "name", Value: "john"},
+		}))
+
+		ctx := causal.WithToken(context.Background(), primitive.Timestamp{T: 1697712345, I: 3})
+		if _, err = uc.GetByID(ctx, primitive.NewObjectID().Hex()); err != nil {
+			tt.Fatalf("unexpected error: %v", err)
+		}
+		started := tt.GetStartedEvent()
+		after, err := started.Command.LookupErr("readConcern", "afterClusterTime")
+		if err != nil {
+			tt.Fatalf("expected the read to wait for the token, got %v", started.Command)
+		}
+		if ts, i := after.Timestamp(); ts != 1697712345 || i != 3 {
+			tt.Fatalf("expected afterClusterTime 1697712345.3, got %v", after)
+		}
+	})
+}
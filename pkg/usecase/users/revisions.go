@@ -0,0 +1,183 @@
+// Package users implement all logic.
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
+)
+
+// maxRevisionAttempts bounds the retries when two writers race for the same revision number.
+const maxRevisionAttempts = 3
+
+func (i *impl) GetRevision(ctx context.Context, userID string, revision int) (entity.UserRevision, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.GetRevision")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return entity.UserRevision{}, err
+	}
+	coll := i.adapter.TenantCollection(ctx, "user_revisions")
+
+	filter := adapters.Scope(ctx, bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "revision", Value: revision},
+	})
+
+	var result entity.UserRevision
+	err := coll.FindOne(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return entity.UserRevision{}, fmt.Errorf("no revision %d was found for user %v: %w", revision, userID, err)
+	}
+	if err != nil {
+		return entity.UserRevision{}, err
+	}
+	if result.Document, err = i.open(result.Document); err != nil {
+		return entity.UserRevision{}, err
+	}
+	return result, nil
+}
+
+func (i *impl) Revert(ctx context.Context, userID string, revision int) (entity.User, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.Revert")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return entity.User{}, err
+	}
+	coll := i.adapter.TenantCollection(ctx, "users")
+
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	ctx, done, err := i.writeSession(ctx)
+	if err != nil {
+		return entity.User{}, err
+	}
+	defer done("")
+
+	// The erasure check, the restored user and its revision commit together, an erasure
+	// committing in between makes the restore conflict and retry.
+	var user entity.User
+	err = i.adapter.WithTransaction(ctx, func(ctx mongo.SessionContext) error {
+		// snapshots of an erased user are anonymized and must not be restored.
+		erased, err := i.erased(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if erased {
+			return ErrUserErased
+		}
+
+		rev, err := i.GetRevision(ctx, userID, revision)
+		if err != nil {
+			return err
+		}
+
+		definitions, err := i.definitions(ctx)
+		if err != nil {
+			return err
+		}
+
+		// the attributes may have been defined differently since the snapshot was taken.
+		if rev.Document.Attributes, err = checkAttributes(definitions, rev.Document.Attributes); err != nil {
+			return err
+		}
+		rev.Document.Unique = uniqueAttributes(definitions, rev.Document.Attributes)
+
+		// The snapshot keeps the hex id, the stored document must keep its ObjectID.
+		document, err := i.seal(rev.Document)
+		if err != nil {
+			return err
+		}
+		document.ID = ""
+		document.TenantID = tenant.FromContext(ctx)
+
+		filter := adapters.Scope(ctx, bson.D{{Key: "_id", Value: id}})
+		// Upsert so that a deleted user can be brought back as well.
+		_, err = coll.ReplaceOne(ctx, filter, document, options.Replace().SetUpsert(true))
+		if err != nil {
+			return duplicateError(err, definitions)
+		}
+
+		if err = coll.FindOne(ctx, filter).Decode(&user); err != nil {
+			return err
+		}
+		if user, err = i.open(user); err != nil {
+			return err
+		}
+		return i.recordRevision(ctx, entity.RevisionActionRevert, user)
+	})
+	if err != nil {
+		return entity.User{}, err
+	}
+	done(userID)
+	return user, nil
+}
+
+// recordRevision stores a full snapshot of the user as the next revision.
+func (i *impl) recordRevision(ctx context.Context, action string, user entity.User) error {
+	coll := i.adapter.TenantCollection(ctx, "user_revisions")
+
+	// snapshots hold the same personal data as the user, keep them sealed.
+	document, err := i.seal(user)
+	if err != nil {
+		return err
+	}
+	document.EmailDomain = ""
+	document.Search = nil
+	for attempt := 0; attempt < maxRevisionAttempts; attempt++ {
+		var last entity.UserRevision
+		err = coll.FindOne(ctx,
+			adapters.Scope(ctx, bson.D{{Key: "user_id", Value: user.ID}}),
+			options.FindOne().SetSort(bson.D{{Key: "revision", Value: -1}}),
+		).Decode(&last)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+
+		_, err = coll.InsertOne(ctx, entity.UserRevision{
+			TenantID:  tenant.FromContext(ctx),
+			UserID:    user.ID,
+			Revision:  last.Revision + 1,
+			Action:    action,
+			Document:  document,
+			CreatedAt: time.Now(),
+		})
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		// the server aborted the transaction, it is retried as a whole.
+		if adapters.InTransaction(ctx) {
+			return fmt.Errorf("revision %d of user %v is taken: %w", last.Revision+1, user.ID, adapters.ErrTransactionRace)
+		}
+	}
+	return fmt.Errorf("failed to record revision for user %v: %w", user.ID, err)
+}
+
+// revisionIndexes keeps revision numbers unique per user.
+func (i *impl) revisionIndexes(ctx context.Context) error {
+	coll := i.adapter.TenantCollection(ctx, "user_revisions")
+
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "user_id", Value: 1},
+			{Key: "revision", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
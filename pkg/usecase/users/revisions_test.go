@@ -0,0 +1,114 @@
+// Package users implement all logic.
+package users
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+)
+
+func TestGetRevision(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("found", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		tt.AddMockResponses(mtest.CreateCursorResponse(0, "test.user_revisions", mtest.FirstBatch, bson.D{
+			{Key: "user_id", Value: "64a7f1f2c2a4b1e0d4b3c2a1"},
+			{Key: "revision", Value: 2},
+			{Key: "action", Value: entity.RevisionActionUpdate},
+			{Key: "document", Value: bson.D{{Key: "name", Value: "john"}}},
+		}))
+
+		rev, err := uc.GetRevision(context.Background(), "64a7f1f2c2a4b1e0d4b3c2a1", 2)
+		if err != nil {
+			tt.Fatalf("unexpected error: %v", err)
+		}
+		if rev.Revision != 2 || rev.Document.Name != "john" {
+			tt.Fatalf("unexpected revision: %+v", rev)
+		}
+	})
+
+	mt.Run("not found", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		tt.AddMockResponses(mtest.CreateCursorResponse(0, "test.user_revisions", mtest.FirstBatch))
+
+		if _, err := uc.GetRevision(context.Background(), "64a7f1f2c2a4b1e0d4b3c2a1", 9); err == nil {
+			tt.Fatal("expected error for missing revision")
+		}
+	})
+}
+
+func TestRecordRevision(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("next number", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		tt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.user_revisions", mtest.FirstBatch, bson.D{
+				{Key: "user_id", Value: "64a7f1f2c2a4b1e0d4b3c2a1"},
+				{Key: "revision", Value: 4},
+			}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		err := uc.recordRevision(context.Background(), entity.RevisionActionUpdate,
+			entity.User{ID: "64a7f1f2c2a4b1e0d4b3c2a1", Name: "john"})
+		if err != nil {
+			tt.Fatalf("unexpected error: %v", err)
+		}
+		inserted := tt.GetStartedEvent()
+		for inserted != nil && inserted.CommandName != "insert" {
+			inserted = tt.GetStartedEvent()
+		}
+		if inserted == nil {
+			tt.Fatal("revision was not inserted")
+		}
+		doc := inserted.Command.Lookup("documents").Array().Index(0).Value().Document()
+		if got := doc.Lookup("revision").Int32(); got != 5 {
+			tt.Fatalf("expected revision 5, got %d", got)
+		}
+	})
+}
+
+func TestRevert(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("attributes no longer defined", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		tt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "isWritablePrimary", Value: true}),
+			mtest.CreateCursorResponse(0, "test.erasure_receipts", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.user_revisions", mtest.FirstBatch, bson.D{
+				{Key: "user_id", Value: "64a7f1f2c2a4b1e0d4b3c2a1"},
+				{Key: "revision", Value: 1},
+				{Key: "document", Value: bson.D{
+					{Key: "name", Value: "john"},
+					{Key: "attributes", Value: bson.D{{Key: "tier", Value: "gold"}}},
+				}},
+			}),
+			mtest.CreateCursorResponse(0, "test.user_attributes", mtest.FirstBatch, bson.D{
+				{Key: "name", Value: "tier"}, {Key: "type", Value: entity.AttributeTypeNumber},
+			}),
+		)
+
+		_, err := uc.Revert(context.Background(), "64a7f1f2c2a4b1e0d4b3c2a1", 1)
+		var errs AttributeErrors
+		if !errors.As(err, &errs) || errs[0] != (AttributeError{Field: "attributes.tier", Rule: "type", Param: "number"}) {
+			tt.Fatalf("expected the reverted tier to fail its definition, got %v", err)
+		}
+		for event := tt.GetStartedEvent(); event != nil; event = tt.GetStartedEvent() {
+			if event.CommandName == "update" {
+				tt.Fatal("expected the user not to be replaced")
+			}
+		}
+	})
+}
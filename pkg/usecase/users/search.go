@@ -0,0 +1,371 @@
+// Package users implement all logic.
+package users
+
+import (
+	"context"
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+)
+
+const (
+	// maxSearchWords bounds the words of a query, the rest is ignored.
+	maxSearchWords = 10
+	// minSimilarity is the share of the trigrams of the query a fuzzy match must hold,
+	// the default similarity threshold of pg_trgm.
+	minSimilarity = 0.3
+	// blindTokenLength shortens blinded search tokens, they only have to tell words apart.
+	blindTokenLength = 16
+)
+
+// ErrEmptyQuery is returned when a search query has no words.
+var ErrEmptyQuery = errors.New("search query has no words")
+
+func (i *impl) Search(ctx context.Context, request entity.RequestSearchUsers) (result entity.ResponseSearchUsers, err error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.Search")
+	defer span.End()
+
+	if err = i.tenancy.Check(ctx); err != nil {
+		return result, err
+	}
+	query := unique(words(request.Query))
+	if len(query) == 0 {
+		return result, ErrEmptyQuery
+	}
+	if len(query) > maxSearchWords {
+		query = query[:maxSearchWords]
+	}
+	coll := i.listingCollection(ctx, "users")
+	result.Pagination = request.Pagination
+	skip := int64((request.Page - 1) * request.Limit)
+
+	match := entity.SearchMatchText
+	text := adapters.Scope(ctx, bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: i.textQuery(query)}}}})
+	cursor, err := coll.Find(ctx, text, options.Find().
+		SetSkip(skip).
+		SetLimit(int64(request.Limit)).
+		SetProjection(bson.D{{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}}, {Key: "search", Value: 0}}).
+		SetSort(bson.D{{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return result, err
+	}
+	results := make([]entity.UserSearchResult, 0)
+	if err = cursor.All(ctx, &results); err != nil {
+		return result, err
+	}
+
+	// typos only fall back on similar words when no user holds the words themselves.
+	if len(results) == 0 {
+		var matched int64
+		if request.Page > 1 {
+			if matched, err = coll.CountDocuments(ctx, text, options.Count().SetLimit(1)); err != nil {
+				return result, err
+			}
+		}
+		if matched == 0 {
+			match = entity.SearchMatchFuzzy
+			if results, err = i.fuzzySearch(ctx, coll, query, skip, int64(request.Limit)); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	for index := range results {
+		found := &results[index]
+		if found.User, err = i.open(found.User); err != nil {
+			return result, err
+		}
+		found.Match = match
+		found.Highlights = highlights(found.User, query, match == entity.SearchMatchFuzzy)
+	}
+	result.Results = results
+	return result, nil
+}
+
+// fuzzySearch finds the users holding the most trigrams of the query words.
+func (i *impl) fuzzySearch(ctx context.Context, coll *mongo.Collection, query []string, skip, limit int64) ([]entity.UserSearchResult, error) {
+	var plain []string
+	for _, word := range query {
+		plain = append(plain, trigrams(word)...)
+	}
+	plain = unique(plain)
+	// a trigram of the query counts once, held in clear by the name or blinded by the email.
+	grams, forms := bson.A{}, bson.A{}
+	for _, gram := range plain {
+		form := bson.A{gram}
+		if i.keyring.Enabled() {
+			form = append(form, i.blind("gram", gram))
+		}
+		grams = append(grams, form...)
+		forms = append(forms, form)
+	}
+
+	held := bson.D{{Key: "$filter", Value: bson.D{
+		{Key: "input", Value: forms},
+		{Key: "cond", Value: bson.D{{Key: "$gt", Value: bson.A{
+			bson.D{{Key: "$size", Value: bson.D{{Key: "$setIntersection", Value: bson.A{"$$this", "$search.grams"}}}}}, 0,
+		}}}},
+	}}}
+	score := bson.D{{Key: "$divide", Value: bson.A{bson.D{{Key: "$size", Value: held}}, len(plain)}}}
+	cursor, err := coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: adapters.Scope(ctx, bson.D{{Key: "search.grams", Value: bson.D{{Key: "$in", Value: grams}}}})}},
+		{{Key: "$addFields", Value: bson.D{
+			{Key: "score", Value: score},
+			{Key: "grams", Value: bson.D{{Key: "$size", Value: "$search.grams"}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "score", Value: bson.D{{Key: "$gte", Value: minSimilarity}}}}}},
+		// among the users holding as much of the query, the fewest other trigrams is the
+		// closest match: an exact name comes before a longer one holding it.
+		{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "grams", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$skip", Value: skip}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$project", Value: bson.D{{Key: "search", Value: 0}, {Key: "grams", Value: 0}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]entity.UserSearchResult, 0)
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (i *impl) Reindex(ctx context.Context) (int, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.Reindex")
+	defer span.End()
+
+	// without a tenant in ctx every tenant of the shared database is reindexed.
+	coll := i.adapter.TenantCollection(ctx, "users")
+	cursor, err := coll.Find(ctx, adapters.Scope(ctx, bson.D{}), options.Find().SetProjection(bson.D{{Key: "search", Value: 0}}))
+	if err != nil {
+		return 0, err
+	}
+	defer func(c context.Context) {
+		_ = cursor.Close(c)
+	}(ctx)
+
+	var updated int
+	for cursor.Next(ctx) {
+		var user entity.User
+		if err = cursor.Decode(&user); err != nil {
+			return updated, err
+		}
+		if user, err = i.open(user); err != nil {
+			return updated, err
+		}
+		update := bson.D{{Key: "$set", Value: bson.D{
+			{Key: "search", Value: i.searchTokens(user)},
+			{Key: "email_domain", Value: emailDomain(user.Email)},
+		}}}
+		// the domains stored before the stats stopped counting them are dropped.
+		if !i.domains {
+			update = bson.D{
+				{Key: "$set", Value: bson.D{{Key: "search", Value: i.searchTokens(user)}}},
+				{Key: "$unset", Value: bson.D{{Key: "email_domain", Value: ""}}},
+			}
+		}
+		_, err = coll.UpdateOne(ctx, bson.D{{Key: "_id", Value: cursor.Current.Lookup("_id")}}, update)
+		if err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, cursor.Err()
+}
+
+// searchTokens are the tokens the user is found by: the words of the email and of the
+// string attributes for the text index, which indexes the name itself, and the trigrams
+// of every word. The tokens of the email are blinded like its index.
+func (i *impl) searchTokens(user entity.User) *entity.UserSearch {
+	terms, grams := map[string]bool{}, map[string]bool{}
+	add := func(value string, blinded bool) {
+		for _, word := range words(value) {
+			if blinded {
+				terms[i.blind("term", word)] = true
+			} else {
+				terms[word] = true
+			}
+			for _, gram := range trigrams(word) {
+				if blinded {
+					gram = i.blind("gram", gram)
+				}
+				grams[gram] = true
+			}
+		}
+	}
+	add(user.Email, i.keyring.Enabled())
+	for _, name := range sortedKeys(user.Attributes) {
+		if value, ok := user.Attributes[name].(string); ok {
+			add(value, false)
+		}
+	}
+	search := &entity.UserSearch{Terms: sortedKeys(terms), Grams: sortedKeys(grams)}
+	for _, word := range words(user.Name) {
+		for _, gram := range trigrams(word) {
+			if !grams[gram] {
+				grams[gram] = true
+				search.Grams = append(search.Grams, gram)
+			}
+		}
+	}
+	return search
+}
+
+// textQuery is the $search of the words, each with its blinded token of the email.
+func (i *impl) textQuery(query []string) string {
+	tokens := make([]string, 0, 2*len(query))
+	for _, word := range query {
+		tokens = append(tokens, word)
+		if i.keyring.Enabled() {
+			tokens = append(tokens, i.blind("term", word))
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// blind hides a search token of the email.
+func (i *impl) blind(kind, token string) string {
+	return i.keyring.BlindIndex(kind + ":" + token + ":")[:blindTokenLength]
+}
+
+// highlights are the fields of the user holding words of the query, html escaped with
+// the matching words in <em>. Fuzzy matches highlight the words similar to the query.
+func highlights(user entity.User, query []string, fuzzy bool) map[string]string {
+	matches := func(word string) bool {
+		for _, q := range query {
+			if word == q || fuzzy && similarity(word, q) >= minSimilarity {
+				return true
+			}
+		}
+		return false
+	}
+	fields := map[string]string{"name": user.Name, "email": user.Email}
+	for name, value := range user.Attributes {
+		if s, ok := value.(string); ok {
+			fields["attributes."+name] = s
+		}
+	}
+	found := map[string]string{}
+	for field, value := range fields {
+		if highlighted, ok := highlight(value, matches); ok {
+			found[field] = highlighted
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	return found
+}
+
+// highlight escapes value and wraps its words matching in <em>.
+func highlight(value string, matches func(word string) bool) (string, bool) {
+	var (
+		b       strings.Builder
+		matched bool
+		start   = -1
+	)
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := value[start:end]
+		if matches(strings.ToLower(word)) {
+			matched = true
+			b.WriteString("<em>" + html.EscapeString(word) + "</em>")
+		} else {
+			b.WriteString(html.EscapeString(word))
+		}
+		start = -1
+	}
+	for index, r := range value {
+		if isWordRune(r) {
+			if start < 0 {
+				start = index
+			}
+			continue
+		}
+		flush(index)
+		b.WriteString(html.EscapeString(string(r)))
+	}
+	flush(len(value))
+	return b.String(), matched
+}
+
+// similarity is the share of the trigrams of the query word the word holds.
+func similarity(word, query string) float64 {
+	held := map[string]bool{}
+	for _, gram := range trigrams(word) {
+		held[gram] = true
+	}
+	grams := unique(trigrams(query))
+	var shared int
+	for _, gram := range grams {
+		if held[gram] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(grams))
+}
+
+// words splits a value into its lowercase words of letters and digits.
+func words(value string) []string {
+	return strings.FieldsFunc(strings.ToLower(value), func(r rune) bool { return !isWordRune(r) })
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// trigrams of a word, padded like pg_trgm so that short words and first letters count.
+func trigrams(word string) []string {
+	runes := []rune("  " + word + " ")
+	grams := make([]string, 0, len(runes)-2)
+	for index := 0; index+3 <= len(runes); index++ {
+		grams = append(grams, string(runes[index:index+3]))
+	}
+	return grams
+}
+
+// unique drops the repeated values, keeping the first.
+func unique(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	kept := values[:0:0]
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
+
+// searchIndexes create the text index of the users and the index of their trigrams.
+func (i *impl) searchIndexes(ctx context.Context) error {
+	_, err := i.adapter.TenantCollection(ctx, "users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "name", Value: "text"},
+				{Key: "search.terms", Value: "text"},
+			},
+			// blinded tokens must not be stemmed, names are rarely words of a language.
+			Options: options.Index().SetName("search_text").
+				SetDefaultLanguage("none").
+				SetWeights(bson.D{{Key: "name", Value: 3}, {Key: "search.terms", Value: 1}}),
+		},
+		{
+			Keys:    bson.D{{Key: "search.grams", Value: 1}},
+			Options: options.Index().SetName("search_grams"),
+		},
+	})
+	return err
+}
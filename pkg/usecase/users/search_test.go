@@ -0,0 +1,155 @@
+// Package users implement all logic.
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
+	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/shared/fieldcrypt"
+)
+
+func TestSearchTokens(t *testing.T) {
+	if got := strings.Join(trigrams("jo"), "|"); got != "  j| jo|jo " {
+		t.Fatalf("unexpected trigrams %q", got)
+	}
+	if got := strings.Join(words("John O'Neil, jr."), "|"); got != "john|o|neil|jr" {
+		t.Fatalf("unexpected words %q", got)
+	}
+
+	uc := &impl{}
+	search := uc.searchTokens(entity.User{
+		Name:       "Ann",
+		Email:      "ann@example.com",
+		Attributes: map[string]any{"team": "Blue", "score": 3.0},
+	})
+	if got := strings.Join(search.Terms, "|"); got != "ann|blue|com|example" {
+		t.Fatalf("unexpected terms %q", got)
+	}
+	for _, gram := range []string{"  a", "nn ", " bl", "ple"} {
+		found := false
+		for _, held := range search.Grams {
+			found = found || held == gram
+		}
+		if !found {
+			t.Fatalf("expected gram %q in %v", gram, search.Grams)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	found := highlights(entity.User{
+		Name:       "Jon <Smith>",
+		Email:      "jon@example.com",
+		Attributes: map[string]any{"team": "red"},
+	}, []string{"john"}, true)
+	if len(found) != 2 {
+		t.Fatalf("expected name and email, got %v", found)
+	}
+	if found["name"] != "<em>Jon</em> &lt;Smith&gt;" {
+		t.Fatalf("unexpected name %q", found["name"])
+	}
+	if found["email"] != "<em>jon</em>@example.com" {
+		t.Fatalf("unexpected email %q", found["email"])
+	}
+	if found = highlights(entity.User{Name: "Jon"}, []string{"john"}, false); found != nil {
+		t.Fatalf("expected exact matches only, got %v", found)
+	}
+}
+
+func TestSearchFallback(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("typo", func(tt *mtest.T) {
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}}
+		tt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: "64a7f1f2c2a4b1e0d4b3c2a1"},
+				{Key: "name", Value: "John Doe"},
+				{Key: "score", Value: 0.4},
+			}),
+		)
+
+		result, err := uc.Search(context.Background(), entity.RequestSearchUsers{
+			Query:      "jonh",
+			Pagination: entity.Pagination{Page: 1, Limit: 10},
+		})
+		if err != nil {
+			tt.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.Results) != 1 {
+			tt.Fatalf("expected one result, got %+v", result.Results)
+		}
+		found := result.Results[0]
+		if found.Match != entity.SearchMatchFuzzy || found.Score != 0.4 || found.Highlights["name"] != "<em>John</em> Doe" {
+			tt.Fatalf("unexpected result %+v", found)
+		}
+		if started := tt.GetStartedEvent(); started == nil || started.CommandName != "find" {
+			tt.Fatal("expected the text search first")
+		}
+		if started := tt.GetStartedEvent(); started == nil || started.CommandName != "aggregate" {
+			tt.Fatal("expected the trigram fallback")
+		}
+	})
+
+	mt.Run("exact name first", func(tt *mtest.T) {
+		keyring, err := fieldcrypt.Load(fieldcrypt.Options{
+			Keys:      "k1:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)),
+			ActiveKey: "k1",
+			IndexKey:  base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32)),
+		})
+		if err != nil {
+			tt.Fatal(err)
+		}
+		uc := &impl{adapter: &adapters.Adapter{PersistUsers: tt.DB}, keyring: keyring}
+		// both users hold the query in clear by their name and blinded by their email.
+		exact := uc.searchTokens(entity.User{Name: "Ann", Email: "ann@mail.io"})
+		partial := uc.searchTokens(entity.User{Name: "Annabelle Johansson", Email: "annabelle.johansson@mail.io"})
+		tt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch),
+		)
+
+		if _, err = uc.Search(context.Background(), entity.RequestSearchUsers{
+			Query:      "ann",
+			Pagination: entity.Pagination{Page: 1, Limit: 10},
+		}); err != nil {
+			tt.Fatalf("unexpected error: %v", err)
+		}
+		tt.GetStartedEvent()
+		pipeline := tt.GetStartedEvent().Command.Lookup("pipeline").Array()
+		divide := pipeline.Index(1).Value().Document().Lookup("$addFields", "score", "$divide").Array()
+		forms, _ := divide.Index(0).Value().Document().Lookup("$size", "$filter", "input").Array().Values()
+		// score evaluates the score of the pipeline on the trigrams held by a user.
+		score := func(search *entity.UserSearch) float64 {
+			held := map[string]bool{}
+			for _, gram := range search.Grams {
+				held[gram] = true
+			}
+			var shared int
+			for _, form := range forms {
+				grams, _ := form.Array().Values()
+				for _, gram := range grams {
+					if held[gram.StringValue()] {
+						shared++
+						break
+					}
+				}
+			}
+			return float64(shared) / float64(divide.Index(1).Value().Int32())
+		}
+		// counted once per form, the partial match held in both forms no longer reaches 1.
+		if score(exact) != 1 || score(partial) >= score(exact) {
+			tt.Fatalf("expected the exact name to rank first, got %v and %v", score(exact), score(partial))
+		}
+	})
+}
@@ -0,0 +1,12 @@
+// Package users implement all logic.
+package users
+
+import (
+	"context"
+)
+
+// AssignTenant gives the users stored before tenancy, and what is held about them, to the
+// tenant id. It stops at an email or an attribute name already taken in the tenant.
+func (i *impl) AssignTenant(ctx context.Context, id string) (map[string]int64, error) {
+	return i.adapter.AssignTenant(ctx, id, "user_attributes", "users", "user_revisions", "erasure_receipts")
+}
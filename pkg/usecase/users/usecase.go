@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"time"
 
+	pkgTracer "github.com/kubuskotak/asgard/tracer"
+	"github.com/kubuskotak/ymir-test/pkg/adapters"
 	"github.com/kubuskotak/ymir-test/pkg/entity"
+	"github.com/kubuskotak/ymir-test/pkg/shared/metrics"
+	"github.com/kubuskotak/ymir-test/pkg/shared/tenant"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -14,7 +18,35 @@ import (
 )
 
 func (i *impl) GetAll(ctx context.Context, request entity.RequestGetUsers) (result entity.ResponseGetUsers, err error) {
-	coll := i.adapter.PersistUsers.Collection("users")
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.GetAll")
+	defer span.End()
+
+	if err = i.tenancy.Check(ctx); err != nil {
+		return result, err
+	}
+	project, err := projection(request.Fields)
+	if err != nil {
+		return result, err
+	}
+	filter := adapters.Scope(ctx, bson.D{})
+	var order bson.D
+	if len(request.Filters) > 0 || len(request.Sort) > 0 {
+		definitions, err := i.definitions(ctx)
+		if err != nil {
+			return result, err
+		}
+		conditions, err := attributeFilter(definitions, request.Filters)
+		if err != nil {
+			return result, err
+		}
+		if len(conditions) > 0 {
+			filter = append(filter, bson.E{Key: "$and", Value: conditions})
+		}
+		if order, err = sortOrder(definitions, request.Sort); err != nil {
+			return result, err
+		}
+	}
+	coll := i.listingCollection(ctx, "users")
 
 	skip := (request.Page - 1) * request.Limit
 
@@ -22,12 +54,18 @@ func (i *impl) GetAll(ctx context.Context, request entity.RequestGetUsers) (resu
 	findOptions := options.Find()
 	findOptions.SetSkip(int64(skip))
 	findOptions.SetLimit(int64(request.Limit))
+	if project != nil {
+		findOptions.SetProjection(project)
+	}
+	if order != nil {
+		findOptions.SetSort(order)
+	}
 
 	// pagination
 	result.Limit = request.Limit
 	result.Page = request.Page
 	var cursor *mongo.Cursor
-	cursor, err = coll.Find(ctx, bson.M{}, findOptions)
+	cursor, err = coll.Find(ctx, filter, findOptions)
 	if err != nil {
 		return result, err
 	}
@@ -43,114 +81,298 @@ func (i *impl) GetAll(ctx context.Context, request entity.RequestGetUsers) (resu
 		if err != nil {
 			return result, err
 		}
+		if document, err = i.open(document); err != nil {
+			return result, err
+		}
 		documents = append(documents, document)
 	}
 
 	result.Users = documents
+	if request.Count {
+		if result.Total, err = coll.CountDocuments(ctx, filter); err != nil {
+			return result, err
+		}
+	}
 	return result, nil
 }
 
 func (i *impl) Create(ctx context.Context, user entity.User) (entity.User, error) {
-	coll := i.adapter.PersistUsers.Collection("users")
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.Create")
+	defer span.End()
 
-	user.CreatedAt = time.Now()
+	if err := i.tenancy.Check(ctx); err != nil {
+		return entity.User{}, err
+	}
+	coll := i.adapter.TenantCollection(ctx, "users")
 
-	result, err := coll.InsertOne(ctx, user)
+	ctx, done, err := i.writeSession(ctx)
 	if err != nil {
 		return entity.User{}, err
 	}
+	defer done("")
+
+	definitions, err := i.definitions(ctx)
+	if err != nil {
+		return entity.User{}, err
+	}
+	if user.Attributes, err = checkAttributes(definitions, user.Attributes); err != nil {
+		return entity.User{}, err
+	}
+	user.Unique = uniqueAttributes(definitions, user.Attributes)
+
+	user.TenantID = tenant.FromContext(ctx)
+	now := time.Now()
+	user.CreatedAt = &now
+
+	user, err = i.seal(user)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	result, err := coll.InsertOne(ctx, user)
+	if err != nil {
+		return entity.User{}, duplicateError(err, definitions)
+	}
+	metrics.UsersCreated.Inc()
 
 	// Retrieve the created document using the _id from the InsertOneResult
 	var createdUser entity.User
-	err = coll.FindOne(ctx, bson.M{"_id": result.InsertedID}).Decode(&createdUser)
+	err = coll.FindOne(ctx, adapters.Scope(ctx, bson.D{{Key: "_id", Value: result.InsertedID}})).Decode(&createdUser)
 	if err != nil {
 		return entity.User{}, err
 	}
+	if createdUser, err = i.open(createdUser); err != nil {
+		return entity.User{}, err
+	}
+
+	if err = i.recordRevision(ctx, entity.RevisionActionCreate, createdUser); err != nil {
+		return entity.User{}, err
+	}
+	done(createdUser.ID)
 
 	return createdUser, nil
 }
 
-func (i *impl) GetByID(ctx context.Context, userID string) (entity.User, error) {
-	coll := i.adapter.PersistUsers.Collection("users")
+func (i *impl) GetByID(ctx context.Context, userID string, fields ...string) (entity.User, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.GetByID")
+	defer span.End()
+
 	var createdUser entity.User
 
+	if err := i.tenancy.Check(ctx); err != nil {
+		return entity.User{}, err
+	}
 	id, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return entity.User{}, err
 	}
 
-	filter := bson.D{{Key: "_id", Value: id}}
+	filter := adapters.Scope(ctx, bson.D{{Key: "_id", Value: id}})
+	findOptions := options.FindOne()
+	project, err := projection(fields)
+	if err != nil {
+		return entity.User{}, err
+	}
+	if project != nil {
+		findOptions.SetProjection(project)
+	}
 
-	err = coll.FindOne(ctx, filter).Decode(&createdUser)
+	err = i.readYourWrites(ctx, userID, func(ctx context.Context, coll *mongo.Collection) error {
+		return coll.FindOne(ctx, filter, findOptions).Decode(&createdUser)
+	})
 	if err != nil {
 		return entity.User{}, err
 	}
-	return createdUser, nil
+	return i.open(createdUser)
+}
+
+func (i *impl) GetByIDs(ctx context.Context, userIDs []string) ([]entity.User, error) {
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.GetByIDs")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return nil, err
+	}
+	ids := make(bson.A, 0, len(userIDs))
+	for _, userID := range userIDs {
+		id, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	project, _ := projection(nil)
+
+	// the users missing from the database are left out.
+	cursor, err := i.adapter.TenantCollection(ctx, "users").Find(ctx,
+		adapters.Scope(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}),
+		options.Find().SetProjection(project))
+	if err != nil {
+		return nil, err
+	}
+	found := make([]entity.User, 0, len(ids))
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	for index := range found {
+		if found[index], err = i.open(found[index]); err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
 }
 
 func (i *impl) UpdateByID(ctx context.Context, user entity.User) (entity.User, error) {
-	coll := i.adapter.PersistUsers.Collection("users")
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.UpdateByID")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return entity.User{}, err
+	}
+	coll := i.adapter.TenantCollection(ctx, "users")
 
 	id, err := primitive.ObjectIDFromHex(user.ID)
 	if err != nil {
 		return entity.User{}, err
 	}
 
-	filter := bson.D{{Key: "_id", Value: id}}
-
-	// Attempt to find the document
-	var result bson.M
-	err = coll.FindOne(ctx, filter).Decode(&result)
+	ctx, done, err := i.writeSession(ctx)
 	if err != nil {
 		return entity.User{}, err
 	}
+	defer done("")
 
-	// The updates
-	update := bson.D{
-		{Key: "$set", Value: bson.D{
-			{Key: "name", Value: user.Name},
-			{Key: "email", Value: user.Email},
-			{Key: "age", Value: user.Age},
-			// Add more fields here if needed
-		}},
+	definitions, err := i.definitions(ctx)
+	if err != nil {
+		return entity.User{}, err
 	}
+	if user.Attributes, err = checkAttributes(definitions, user.Attributes); err != nil {
+		return entity.User{}, err
+	}
+	user.Unique = uniqueAttributes(definitions, user.Attributes)
 
-	_, err = coll.UpdateOne(ctx, filter, update)
+	sealed, err := i.seal(user)
 	if err != nil {
 		return entity.User{}, err
 	}
 
-	// Query the updated user data
-	err = coll.FindOne(ctx, filter).Decode(&user)
+	filter := adapters.Scope(ctx, bson.D{{Key: "_id", Value: id}})
+
+	// The updates
+	set := bson.D{
+		{Key: "name", Value: sealed.Name},
+		{Key: "email", Value: sealed.Email},
+		{Key: "email_index", Value: sealed.EmailIndex},
+		{Key: "age", Value: sealed.Age},
+		{Key: "search", Value: sealed.Search},
+		// Add more fields here if needed
+	}
+	// the domain, the attributes and their unique copies are replaced as a whole.
+	var unset bson.D
+	if sealed.EmailDomain != "" {
+		set = append(set, bson.E{Key: "email_domain", Value: sealed.EmailDomain})
+	} else {
+		unset = append(unset, bson.E{Key: "email_domain", Value: ""})
+	}
+	if sealed.Attributes != nil {
+		set = append(set, bson.E{Key: "attributes", Value: sealed.Attributes})
+	} else {
+		unset = append(unset, bson.E{Key: "attributes", Value: ""})
+	}
+	if sealed.Unique != nil {
+		set = append(set, bson.E{Key: "unique", Value: sealed.Unique})
+	} else {
+		unset = append(unset, bson.E{Key: "unique", Value: ""})
+	}
+	update := bson.D{{Key: "$set", Value: set}}
+	if len(unset) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+
+	// The read, the update and its revision commit together.
+	var updated entity.User
+	err = i.adapter.WithTransaction(ctx, func(ctx mongo.SessionContext) error {
+		// Attempt to find the document
+		var result bson.M
+		if err := coll.FindOne(ctx, filter).Decode(&result); err != nil {
+			return err
+		}
+
+		if _, err := coll.UpdateOne(ctx, filter, update); err != nil {
+			return duplicateError(err, definitions)
+		}
+
+		// Query the updated user data
+		if err := coll.FindOne(ctx, filter).Decode(&updated); err != nil {
+			return err
+		}
+		var err error
+		if updated, err = i.open(updated); err != nil {
+			return err
+		}
+		return i.recordRevision(ctx, entity.RevisionActionUpdate, updated)
+	})
 	if err != nil {
 		return entity.User{}, err
 	}
+	done(updated.ID)
 
-	return user, nil
+	return updated, nil
 }
 
 func (i *impl) DeleteByID(ctx context.Context, userID string) error {
-	coll := i.adapter.PersistUsers.Collection("users")
+	ctx, span, _ := pkgTracer.StartSpanLogTrace(ctx, "users.DeleteByID")
+	defer span.End()
+
+	if err := i.tenancy.Check(ctx); err != nil {
+		return err
+	}
+	coll := i.adapter.TenantCollection(ctx, "users")
 
 	id, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return err
 	}
 
-	filter := bson.D{{Key: "_id", Value: id}}
-
-	// Attempt to find the document
-	var result bson.M
-	err = coll.FindOne(ctx, filter).Decode(&result)
+	ctx, done, err := i.writeSession(ctx)
 	if err != nil {
-		return fmt.Errorf("no document with id %v was found", userID)
+		return err
 	}
+	defer done("")
 
-	// If document is found, attempt to delete
-	_, err = coll.DeleteOne(ctx, filter)
+	filter := adapters.Scope(ctx, bson.D{{Key: "_id", Value: id}})
+
+	// The read, the delete and the last revision commit together.
+	err = i.adapter.WithTransaction(ctx, func(ctx mongo.SessionContext) error {
+		// Attempt to find the document
+		var result entity.User
+		err := coll.FindOne(ctx, filter).Decode(&result)
+		if err != nil {
+			return fmt.Errorf("no document with id %v was found: %w", userID, err)
+		}
+
+		// If document is found, attempt to delete
+		if _, err = coll.DeleteOne(ctx, filter); err != nil {
+			return err
+		}
+
+		for _, cascade := range i.cascades {
+			if _, _, err = cascade(ctx, userID); err != nil {
+				return err
+			}
+		}
+
+		if result, err = i.open(result); err != nil {
+			return err
+		}
+
+		// Keep the last state so the user can be reverted after deletion.
+		return i.recordRevision(ctx, entity.RevisionActionDelete, result)
+	})
 	if err != nil {
 		return err
 	}
-
+	metrics.UsersDeleted.WithLabelValues("delete").Inc()
+	done(userID)
 	return nil
 }